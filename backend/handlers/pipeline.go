@@ -144,6 +144,32 @@ func UpsertPipeline(services *service.Services) gin.HandlerFunc {
 			context.JSON(err.Status(), gin.H{
 				"error": err.Error(),
 			})
+			return
+		}
+
+		if req.Queue != "" && !service.IsRunQueue(req.Queue) {
+			errMessage := fmt.Sprintf("Unknown queue %s, use one of: %v\n", req.Queue, service.RunQueues)
+			log.Printf(errMessage)
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		diagnostics := services.StepService.ValidatePipeline(req.Definition, req.Parameters, user.ID)
+
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Severity == "error" {
+				errMessage := fmt.Sprintf("Failed to save pipeline %s: %s\n", req.Name, diagnostic.Message)
+				log.Printf(errMessage)
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error":       err.Message,
+					"diagnostics": diagnostics,
+				})
+				return
+			}
 		}
 
 		if req.ID != 0 {
@@ -158,6 +184,8 @@ func UpsertPipeline(services *service.Services) gin.HandlerFunc {
 			}
 
 			pipeline.Definition = req.Definition
+			pipeline.Parameters = req.Parameters
+			pipeline.Queue = req.Queue
 			err = services.PipelineService.Update(pipeline)
 
 			if err != nil {
@@ -169,7 +197,7 @@ func UpsertPipeline(services *service.Services) gin.HandlerFunc {
 				return
 			}
 		} else {
-			serviceError := services.PipelineService.Create(user.ID, req.Name, req.Definition)
+			serviceError := services.PipelineService.Create(user.ID, req.Name, req.Definition, req.Parameters, req.Queue)
 
 			if serviceError != nil {
 				log.Print(serviceError.Error())
@@ -181,7 +209,94 @@ func UpsertPipeline(services *service.Services) gin.HandlerFunc {
 			}
 		}
 
-		context.JSON(http.StatusOK, gin.H{})
+		context.JSON(http.StatusOK, gin.H{
+			"diagnostics": diagnostics,
+		})
+	}
+}
+
+func ValidatePipeline(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		pipelineId := context.Param("id")
+
+		id, parseError := strconv.ParseUint(pipelineId, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		pipeline, getError := services.PipelineService.Get(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if pipeline.User.ID != user.ID {
+			errMessage := fmt.Sprintf("Failed to validate pipeline %d with user: %v\n", pipeline.ID, user.ID)
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		// An unsaved definition can be sent in the body to validate it before saving.
+		definition := pipeline.Definition
+		parameters := pipeline.Parameters
+
+		if context.Request.ContentLength > 0 {
+			var req model.PipelineReq
+
+			if ok := util.BindData(context, &req); !ok {
+				return
+			}
+
+			if req.Definition != "" {
+				definition = req.Definition
+				parameters = req.Parameters
+			}
+		}
+
+		diagnostics := services.StepService.ValidatePipeline(definition, parameters, user.ID)
+		valid := true
+
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Severity == "error" {
+				valid = false
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"valid":       valid,
+			"diagnostics": diagnostics,
+		})
 	}
 }
 
@@ -347,7 +462,7 @@ func DeletePipeline(services *service.Services) gin.HandlerFunc {
 		}
 
 		if pipeline.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -419,7 +534,7 @@ func DeletePipelineSchedule(services *service.Services, I18n *i18n.Localizer) gi
 		}
 
 		if pipeline.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", pipeline.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", pipeline.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -459,3 +574,74 @@ func getLastRun(services *service.Services, pipelineID uint) time.Time {
 
 	return lastRun
 }
+
+// GetPipelineTestResults returns the test results of every run of a pipeline, oldest first, to track how the tests
+// of the pipeline evolve over its runs.
+func GetPipelineTestResults(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		pipelineId := context.Param("id")
+
+		id, parseError := strconv.ParseUint(pipelineId, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		pipeline, getError := services.PipelineService.Get(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if pipeline.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewAuthorization("")
+			context.JSON(err.Status(), gin.H{
+				"error": errorMessage,
+			})
+			return
+		}
+
+		testResults, getError := services.RunService.FindPipelineTestResults(pipeline.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"testResults": testResults,
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"di/model"
+	"di/service"
+	"di/util"
+	"di/util/errors"
+	goerrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// runWorkDir returns the work dir of the run, where its steps write their files.
+func runWorkDir(run *model.Run, I18n *i18n.Localizer) (string, *errors.Error) {
+	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
+
+	if !exists {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "env.variable.find.failed",
+			TemplateData: map[string]interface{}{
+				"Name": "PIPELINES_WORK_DIR",
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return "", errors.NewInternal(errMessage)
+	}
+
+	return filepath.Join(pipelinesWorkDir, fmt.Sprint(run.PipelineID), fmt.Sprint(run.ID)), nil
+}
+
+// findRunWorkDirPath resolves the path query parameter within the work dir of the run given by the id parameter,
+// once the user is checked to own the pipeline of the run, and returns the run, its work dir and the resolved path.
+// On failure the error response is already written.
+func findRunWorkDirPath(context *gin.Context, services *service.Services, action string, I18n *i18n.Localizer) (*model.Run, string, string, bool) {
+	runID, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+	if parseError != nil {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "sys.parsing.string.uint",
+			TemplateData: map[string]interface{}{
+				"Reason": parseError.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		err := errors.NewInternal(errMessage)
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return nil, "", "", false
+	}
+
+	user, err := getUser(context)
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Error(),
+		})
+		return nil, "", "", false
+	}
+
+	run, serviceError := services.RunService.Get(uint(runID))
+
+	if serviceError != nil {
+		log.Printf(serviceError.Error())
+		err := errors.NewNotFound(serviceError.Error())
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return nil, "", "", false
+	}
+
+	if run.Pipeline.User.ID != user.ID {
+		errorMessage := fmt.Sprintf("Failed to %s of pipeline %d with user: %v\n", action, run.Pipeline.ID, user.Username)
+		log.Printf(errorMessage)
+		err := errors.NewInternal(errorMessage)
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return nil, "", "", false
+	}
+
+	workDir, err := runWorkDir(run, I18n)
+
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return nil, "", "", false
+	}
+
+	path, resolveError := util.ResolvePath(workDir, context.Query("path"))
+
+	if resolveError != nil {
+		localizeConfig := &i18n.LocalizeConfig{
+			MessageID: "run.handler.artifacts.path.not-found",
+			TemplateData: map[string]interface{}{
+				"Path":  context.Query("path"),
+				"RunID": run.ID,
+			},
+			PluralCount: 1,
+		}
+
+		if goerrors.Is(resolveError, util.ErrPathOutsideRoot) {
+			localizeConfig.MessageID = "run.handler.artifacts.path.invalid"
+		}
+
+		errMessage := I18n.MustLocalize(localizeConfig)
+		log.Printf(errMessage)
+
+		err := errors.NewNotFound(errMessage)
+
+		if goerrors.Is(resolveError, util.ErrPathOutsideRoot) {
+			err = errors.NewBadRequest(errMessage)
+		}
+
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return nil, "", "", false
+	}
+
+	return run, workDir, path, true
+}
+
+// GetRunArtifactFiles lists the files of the work dir of a run, or of the subtree of it given by path, as a tree.
+// Depth limits how many levels of directories are listed, all of them by default.
+func GetRunArtifactFiles(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		depth, err := queryInt(context, "depth", -1, I18n)
+
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		_, workDir, path, ok := findRunWorkDirPath(context, services, "list files", I18n)
+
+		if !ok {
+			return
+		}
+
+		tree, listError := util.ListFiles(workDir, context.Query("path"), int(depth))
+
+		if listError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "os.cmd.read.file.failed",
+				TemplateData: map[string]interface{}{
+					"Path":   path,
+					"Reason": listError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"files": tree,
+		})
+	}
+}
+
+// DownloadRunArtifactFile serves a file of the work dir of a run, as an attachment when download is set.
+func DownloadRunArtifactFile(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		_, _, path, ok := findRunWorkDirPath(context, services, "download files", I18n)
+
+		if !ok {
+			return
+		}
+
+		if info, statError := os.Stat(path); statError != nil || info.IsDir() {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.artifacts.file.not-file",
+				TemplateData: map[string]interface{}{
+					"Path": context.Query("path"),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if download, _ := strconv.ParseBool(context.Query("download")); download {
+			context.FileAttachment(path, filepath.Base(path))
+			return
+		}
+
+		context.Header("Content-Type", util.FileMimeType(path))
+		context.File(path)
+	}
+}
+
+// DownloadRunArtifactArchive streams the work dir of a run, or the subtree of it given by path, as a tar.gz archive,
+// or as a zip archive when format is zip.
+func DownloadRunArtifactArchive(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		format := context.DefaultQuery("format", "tar.gz")
+
+		if format != "tar.gz" && format != "zip" {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.artifacts.archive.format.invalid",
+				TemplateData: map[string]interface{}{
+					"Format": format,
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		run, _, path, ok := findRunWorkDirPath(context, services, "download files", I18n)
+
+		if !ok {
+			return
+		}
+
+		fileName := fmt.Sprintf("run%d", run.ID)
+
+		if name := filepath.Base(path); name != fmt.Sprint(run.ID) {
+			fileName += "_" + name
+		}
+
+		contentType := "application/gzip"
+		writeArchive := util.WriteTarGz
+
+		if format == "zip" {
+			contentType = "application/zip"
+			writeArchive = util.WriteZip
+		}
+
+		context.Header("Content-Type", contentType)
+		context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", fileName, format))
+		context.Status(http.StatusOK)
+
+		// The archive is streamed as it is written, so an error past this point can only cut it short
+		if err := writeArchive(context.Writer, path); err != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.artifacts.archive.failed",
+				TemplateData: map[string]interface{}{
+					"Path":   path,
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+		}
+	}
+}
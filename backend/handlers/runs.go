@@ -1,17 +1,19 @@
 package handlers
 
 import (
-	"bytes"
 	"di/model"
 	"di/service"
 	"di/util"
 	"di/util/errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -143,7 +145,7 @@ func CreateRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc
 		}
 
 		if pipeline.User.ID != user.ID {
-			errorMessage := fmt.Sprint("Failed to create run for pipeline %d with user: %v\n", pipeline.ID, user.Username)
+			errorMessage := fmt.Sprintf("Failed to create run for pipeline %d with user: %v\n", pipeline.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
@@ -152,7 +154,7 @@ func CreateRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc
 			return
 		}
 
-		_, serviceError = services.RunService.Create(*pipeline)
+		run, serviceError := services.RunService.Create(*pipeline, req)
 
 		if serviceError != nil {
 			log.Printf(serviceError.Error())
@@ -164,7 +166,7 @@ func CreateRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc
 		}
 
 		if req.Execute {
-			serviceError := services.RunService.Execute(pipeline.ID)
+			serviceError := services.RunService.Execute(run.ID)
 
 			if serviceError != nil {
 				log.Printf(serviceError.Error())
@@ -222,7 +224,7 @@ func ResumeRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc
 		}
 
 		if run.Pipeline.User.ID != user.ID {
-			errorMessage := fmt.Sprint("Failed to execute pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			errorMessage := fmt.Sprintf("Failed to execute pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
@@ -246,7 +248,7 @@ func ResumeRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc
 	}
 }
 
-func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+func CancelRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
 		id := context.Param("runID")
@@ -274,6 +276,7 @@ func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 			context.JSON(err.Status(), gin.H{
 				"error": err.Error(),
 			})
+			return
 		}
 
 		run, serviceError := services.RunService.Get(uint(runID))
@@ -288,7 +291,7 @@ func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 		}
 
 		if run.Pipeline.User.ID != user.ID {
-			errorMessage := fmt.Sprint("Failed to execute pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			errorMessage := fmt.Sprintf("Failed to cancel run of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
@@ -297,7 +300,7 @@ func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 			return
 		}
 
-		serviceError = services.RunService.Execute(run.ID)
+		serviceError = services.RunService.Cancel(run.ID)
 
 		if serviceError != nil {
 			log.Printf(serviceError.Error())
@@ -312,10 +315,10 @@ func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 	}
 }
 
-func GetLogTail(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+func RerunRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
-		id := context.Param("id")
+		id := context.Param("runID")
 
 		runID, parseError := strconv.ParseUint(id, 10, 64)
 
@@ -335,24 +338,13 @@ func GetLogTail(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 			return
 		}
 
-		run, serviceError := services.RunService.Get(uint(runID))
-
-		if serviceError != nil {
-			log.Printf(serviceError.Error())
-			err := errors.NewInternal(serviceError.Error())
-			context.JSON(err.Status(), gin.H{
-				"error": err.Message,
-			})
-			return
-		}
-
-		runLogsDir, exists := os.LookupEnv("RUN_LOGS_DIR")
+		stepID, parseError := strconv.Atoi(context.Param("stepID"))
 
-		if !exists {
+		if parseError != nil {
 			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "env.variable.find.failed",
+				MessageID: "sys.parsing.string.int",
 				TemplateData: map[string]interface{}{
-					"Name": "RUN_LOGS_DIR",
+					"Reason": parseError.Error(),
 				},
 				PluralCount: 1,
 			})
@@ -364,58 +356,54 @@ func GetLogTail(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 			return
 		}
 
-		logFileName, exists := os.LookupEnv("RUN_LOG_FILE_NAME")
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
 
-		if !exists {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "env.variable.find.failed",
-				TemplateData: map[string]interface{}{
-					"Name": "RUN_LOG_FILE_NAME",
-				},
-				PluralCount: 1,
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
 			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to re-run pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		runLogDir := runLogsDir + "/pipelines/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/"
-		var logFileTailStdout bytes.Buffer
-		cmd := exec.Command("tail", "-25", runLogDir+logFileName)
-		cmd.Stdout = &logFileTailStdout
-		err := cmd.Run()
+		serviceError = services.RunService.Rerun(run.ID, stepID)
 
-		if err != nil {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "os.cmd.read.file.failed",
-				TemplateData: map[string]interface{}{
-					"Path":   runLogDir + logFileName,
-					"Reason": err.Error(),
-				},
-				PluralCount: 1,
-			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{
-			"log":        logFileTailStdout.String(),
-			"logFileURL": "/logs/pipelines/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/" + logFileName,
-		})
+		context.JSON(http.StatusOK, gin.H{})
 	}
 }
 
-func FindRunResulstById(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+func ExecuteRun(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
-		id := context.Param("id")
+		id := context.Param("runID")
 
 		runID, parseError := strconv.ParseUint(id, 10, 64)
 
@@ -435,6 +423,13 @@ func FindRunResulstById(services *service.Services, I18n *i18n.Localizer) gin.Ha
 			return
 		}
 
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+		}
+
 		run, serviceError := services.RunService.Get(uint(runID))
 
 		if serviceError != nil {
@@ -446,43 +441,148 @@ func FindRunResulstById(services *service.Services, I18n *i18n.Localizer) gin.Ha
 			return
 		}
 
-		runStepStatuses, getError := services.RunService.FindRunStepStatusesByRun(run.ID)
-
-		if getError != nil {
-			log.Printf(getError.Error())
-			err := errors.NewInternal(getError.Error())
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to execute pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		humanFeedbackQueries, getError := services.RunService.FindHumanFeedbackQueriesByRunID(run.ID)
+		serviceError = services.RunService.Execute(run.ID)
 
-		if getError != nil {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "run.handler.feedback.find.fail",
-				TemplateData: map[string]interface{}{
-					"ID":     runStepStatuses[0].StepID,
-					"Reason": getError.Error(),
-				},
-				PluralCount: 1,
-			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		runLogsDir, exists := os.LookupEnv("RUN_LOGS_DIR")
+		context.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// runLogTailLines is the number of lines of the run log returned when no cursor is given.
+const runLogTailLines = 25
+
+// runLogMaxLines caps the number of lines of a page of the run log.
+const runLogMaxLines = 1000
+
+// runLogFollowInterval is how often a followed run log is checked for new lines.
+const runLogFollowInterval = time.Second
+
+// runLogFile returns the path of the log file of a run and the URL of the run results API it is downloaded from.
+func runLogFile(run *model.Run, I18n *i18n.Localizer) (string, string, *errors.Error) {
+	runLogsDir, exists := os.LookupEnv("RUN_LOGS_DIR")
+
+	if !exists {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "env.variable.find.failed",
+			TemplateData: map[string]interface{}{
+				"Name": "RUN_LOGS_DIR",
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return "", "", errors.NewInternal(errMessage)
+	}
+
+	logFileName, exists := os.LookupEnv("RUN_LOG_FILE_NAME")
+
+	if !exists {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "env.variable.find.failed",
+			TemplateData: map[string]interface{}{
+				"Name": "RUN_LOG_FILE_NAME",
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return "", "", errors.NewInternal(errMessage)
+	}
+
+	runLogPath := "/pipelines/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/" + logFileName
+
+	return runLogsDir + runLogPath, fmt.Sprintf("/api/runresults/%d/log?download=true", run.ID), nil
+}
+
+// readRunLog reads a page of a run log, wrapping the read error in a localized message.
+func readRunLog(path string, offset int64, before int64, limit int, stepID string, I18n *i18n.Localizer) (util.LogPage, *errors.Error) {
+	page, err := util.ReadLog(path, offset, before, limit, stepID)
+
+	return page, runLogReadError(path, err, I18n)
+}
+
+// readRunLogAfter reads the page of a run log following the previous one, wrapping the read error in a localized
+// message.
+func readRunLogAfter(path string, previous util.LogPage, limit int, stepID string, I18n *i18n.Localizer) (util.LogPage, *errors.Error) {
+	page, err := util.ReadLogAfter(path, previous, limit, stepID)
+
+	return page, runLogReadError(path, err, I18n)
+}
+
+// runLogReadError wraps the error reading a run log in a localized message, returning nil when there is none.
+func runLogReadError(path string, err error, I18n *i18n.Localizer) *errors.Error {
+	if err != nil {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "os.cmd.read.file.failed",
+			TemplateData: map[string]interface{}{
+				"Path":   path,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return errors.NewInternal(errMessage)
+	}
+
+	return nil
+}
+
+// queryInt parses an optional integer query parameter, returning defaultValue when it is not set.
+func queryInt(context *gin.Context, name string, defaultValue int64, I18n *i18n.Localizer) (int64, *errors.Error) {
+	value := context.Query(name)
+
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, parseError := strconv.ParseInt(value, 10, 64)
+
+	if parseError != nil {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "sys.parsing.string.int",
+			TemplateData: map[string]interface{}{
+				"Reason": parseError.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return 0, errors.NewBadRequest(errMessage)
+	}
+
+	return parsed, nil
+}
+
+// GetLogTail returns a page of the run log. Without a cursor it returns the last lines, offset reads the lines from a
+// byte offset on and before the lines ending before a byte offset. With follow set the page is sent as a server-sent
+// event and the lines written afterwards are streamed until the run finishes.
+func GetLogTail(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
 
-		if !exists {
+		if parseError != nil {
 			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "env.variable.find.failed",
+				MessageID: "sys.parsing.string.uint",
 				TemplateData: map[string]interface{}{
-					"Name": "RUN_LOGS_DIR",
+					"Reason": parseError.Error(),
 				},
 				PluralCount: 1,
 			})
@@ -494,58 +594,50 @@ func FindRunResulstById(services *service.Services, I18n *i18n.Localizer) gin.Ha
 			return
 		}
 
-		logFileName, exists := os.LookupEnv("RUN_LOG_FILE_NAME")
-
-		if !exists {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "env.variable.find.failed",
-				TemplateData: map[string]interface{}{
-					"Name": "RUN_LOG_FILE_NAME",
-				},
-				PluralCount: 1,
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
 			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		runLogDir := runLogsDir + "/pipelines/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/"
-		var logFileTailStdout bytes.Buffer
-		cmd := exec.Command("tail", "-25", runLogDir+logFileName)
-		cmd.Stdout = &logFileTailStdout
-		err := cmd.Run()
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get log of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
 
+		logFilePath, logFileURL, err := runLogFile(run, I18n)
 		if err != nil {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "os.cmd.read.file.failed",
-				TemplateData: map[string]interface{}{
-					"Path":   runLogDir + logFileName,
-					"Reason": err.Error(),
-				},
-				PluralCount: 1,
-			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{
-			"run":                  run,
-			"runStepStatuses":      runStepStatuses,
-			"humanFeedbackQueries": humanFeedbackQueries,
-			"log":                  logFileTailStdout.String(),
-			"logFileURL":           "/logs/pipelines/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/" + logFileName,
-		})
+		serveRunLog(context, services, run, logFilePath, logFileURL, context.Query("stepId"), I18n)
 	}
 }
 
-func FindRunFeedbackQueriesByRunId(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+// GetStepLog returns a page of the log of a single step, whose lines are tagged with their time and stream. It takes
+// the same query parameters as GetLogTail.
+func GetStepLog(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
 		id := context.Param("id")
@@ -568,6 +660,32 @@ func FindRunFeedbackQueriesByRunId(services *service.Services, I18n *i18n.Locali
 			return
 		}
 
+		stepID, parseError := strconv.Atoi(context.Param("stepId"))
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.int",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
 		run, serviceError := services.RunService.Get(uint(runID))
 
 		if serviceError != nil {
@@ -579,77 +697,393 @@ func FindRunFeedbackQueriesByRunId(services *service.Services, I18n *i18n.Locali
 			return
 		}
 
-		if run.RunStatusID != 5 {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "run.handler.feedback.status.error",
-				TemplateData: map[string]interface{}{
-					"ID": run.ID,
-				},
-				PluralCount: 1,
-			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get step log of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		runStepStatuses, getError := services.RunService.FindRunStepStatusesByRun(run.ID)
-
-		if getError != nil {
-			log.Printf(getError.Error())
-			err := errors.NewInternal(getError.Error())
+		logFilePath, _, err := runLogFile(run, I18n)
+		if err != nil {
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		runStepStatuses = util.Filter(runStepStatuses, func(runStepStatus model.RunStepStatus) bool {
-			return runStepStatus.RunStatusID == 5
-		})
+		stepLogPath := util.StepLogPath(logFilePath, stepID)
 
-		if len(runStepStatuses) == 0 {
+		if _, statError := os.Stat(stepLogPath); os.IsNotExist(statError) {
 			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "run.handler.feedback.status.error",
+				MessageID: "run.handler.step.log.not-found",
 				TemplateData: map[string]interface{}{
-					"ID": run.ID,
+					"ID":    stepID,
+					"RunID": run.ID,
 				},
 				PluralCount: 1,
 			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+			err := errors.NewNotFound(errMessage)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		humanFeedbackQueries, err := services.RunService.FindHumanFeedbackQueriesByStepID(run.ID, uint(runStepStatuses[0].StepID))
+		stepLogURL := fmt.Sprintf("/api/runresults/%d/steps/%d/log?download=true", run.ID, stepID)
 
-		if err != nil {
-			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "run.handler.feedback.find.fail",
-				TemplateData: map[string]interface{}{
-					"ID":     runStepStatuses[0].StepID,
-					"Reason": err.Error(),
-				},
-				PluralCount: 1,
-			})
-			log.Printf(errMessage)
-			err := errors.NewInternal(errMessage)
+		serveRunLog(context, services, run, stepLogPath, stepLogURL, "", I18n)
+	}
+}
+
+// serveRunLog writes a page of a log of the run read with the offset, before and limit query parameters, or streams
+// it with follow set, keeping only the lines of stepID when it is not empty. With download set the whole log file is
+// sent instead, since the logs dir is not served publicly.
+func serveRunLog(context *gin.Context, services *service.Services, run *model.Run, logFilePath string, logFileURL string, stepID string, I18n *i18n.Localizer) {
+	if download, _ := strconv.ParseBool(context.Query("download")); download {
+		if _, statError := os.Stat(logFilePath); statError != nil {
+			err := runLogReadError(logFilePath, statError, I18n)
 			context.JSON(err.Status(), gin.H{
 				"error": err.Message,
 			})
 			return
 		}
 
-		humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
-			return humanFeedbackQuery.QueryStatusID != 3
+		context.FileAttachment(logFilePath, fmt.Sprintf("run%d_%s", run.ID, filepath.Base(logFilePath)))
+		return
+	}
+
+	offset, err := queryInt(context, "offset", -1, I18n)
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
 		})
+		return
+	}
 
-		var completeFeedbackResponse []model.HumanFeedbackQueryResponse
+	before, err := queryInt(context, "before", -1, I18n)
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return
+	}
+
+	limit, err := queryInt(context, "limit", runLogTailLines, I18n)
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return
+	}
+
+	if limit <= 0 || limit > runLogMaxLines {
+		limit = runLogMaxLines
+	}
+
+	if context.Query("follow") != "true" {
+		page, err := readRunLog(logFilePath, offset, before, int(limit), stepID, I18n)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"log":        page.Text(),
+			"lines":      page.Lines,
+			"offset":     page.Offset,
+			"nextOffset": page.NextOffset,
+			"hasMore":    page.HasMore,
+			"size":       page.Size,
+			"logFileURL": logFileURL,
+		})
+		return
+	}
+
+	// Subscribing before the first read makes sure the lines written before the run finishes are all sent
+	events, serviceError := services.RunService.SubscribeRunEvents(context.Request.Context(), run.ID)
+
+	if serviceError != nil {
+		log.Printf(serviceError.Error())
+		err := errors.NewInternal(serviceError.Error())
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return
+	}
+
+	run, serviceError = services.RunService.Get(run.ID)
+
+	if serviceError != nil {
+		log.Printf(serviceError.Error())
+		err := errors.NewInternal(serviceError.Error())
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return
+	}
+
+	page, err := readRunLog(logFilePath, offset, before, int(limit), stepID, I18n)
+	if err != nil {
+		context.JSON(err.Status(), gin.H{
+			"error": err.Message,
+		})
+		return
+	}
+
+	context.Header("Cache-Control", "no-cache")
+	context.SSEvent("log", page)
+
+	finished := run.RunStatus.IsFinal
+	lastSent := time.Now()
+	ticker := time.NewTicker(runLogFollowInterval)
+	defer ticker.Stop()
+
+	context.Stream(func(w io.Writer) bool {
+		if !finished {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+
+				finished = event.Type == model.RunEventRunFinished
+			case <-ticker.C:
+			}
+		}
+
+		// The previous page carries the offset and step to go on from, so only the new lines are read
+		next, err := readRunLogAfter(logFilePath, page, runLogMaxLines, stepID, I18n)
+		if err != nil {
+			context.SSEvent("error", gin.H{
+				"error": err.Message,
+			})
+			return false
+		}
+
+		page = next
+
+		if len(page.Lines) > 0 {
+			context.SSEvent("log", page)
+			lastSent = time.Now()
+		} else if time.Since(lastSent) >= runEventsKeepAlive {
+			context.SSEvent("ping", gin.H{})
+			lastSent = time.Now()
+		}
+
+		if finished && !page.HasMore {
+			context.SSEvent("eof", gin.H{
+				"nextOffset": page.NextOffset,
+			})
+			return false
+		}
+
+		return true
+	})
+}
+
+func FindRunResulstById(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		runStepStatuses, getError := services.RunService.FindRunStepStatusesByRun(run.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		humanFeedbackQueries, getError := services.RunService.FindHumanFeedbackQueriesByRunID(run.ID)
+
+		if getError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.feedback.find.fail",
+				TemplateData: map[string]interface{}{
+					"ID":     runStepStatuses[0].StepID,
+					"Reason": getError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		logFilePath, logFileURL, err := runLogFile(run, I18n)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		page, err := readRunLog(logFilePath, -1, -1, runLogTailLines, "", I18n)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"run":                  run,
+			"runStepStatuses":      runStepStatuses,
+			"humanFeedbackQueries": humanFeedbackQueries,
+			"log":                  page.Text(),
+			"logOffset":            page.Offset,
+			"logNextOffset":        page.NextOffset,
+			"logFileURL":           logFileURL,
+		})
+	}
+}
+
+func FindRunFeedbackQueriesByRunId(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.RunStatusID != 5 {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.feedback.status.error",
+				TemplateData: map[string]interface{}{
+					"ID": run.ID,
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		runStepStatuses, getError := services.RunService.FindRunStepStatusesByRun(run.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		runStepStatuses = util.Filter(runStepStatuses, func(runStepStatus model.RunStepStatus) bool {
+			return runStepStatus.RunStatusID == 5
+		})
+
+		if len(runStepStatuses) == 0 {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.feedback.status.error",
+				TemplateData: map[string]interface{}{
+					"ID": run.ID,
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		humanFeedbackQueries, err := services.RunService.FindHumanFeedbackQueriesByStepID(run.ID, uint(runStepStatuses[0].StepID))
+
+		if err != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.handler.feedback.find.fail",
+				TemplateData: map[string]interface{}{
+					"ID":     runStepStatuses[0].StepID,
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
+			return humanFeedbackQuery.QueryStatusID != 3
+		})
+
+		var completeFeedbackResponse []model.HumanFeedbackQueryResponse
 
 		for _, humanFeedbackQuery := range humanFeedbackQueries {
 			feedbackRects, err := services.RunService.FindHumanFeedbackRectsByHumanFeedbackQueryID(humanFeedbackQuery.ID)
@@ -697,7 +1131,7 @@ func FindRunFeedbackQueriesByRunId(services *service.Services, I18n *i18n.Locali
 				return
 			}
 
-			imageURL := "/work/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/epochs/" + fmt.Sprint(humanFeedbackQuery.Epoch) + "/"
+			imageURL := "/api/runresults/" + fmt.Sprint(run.ID) + "/artifacts/file?path=epochs/" + fmt.Sprint(humanFeedbackQuery.Epoch) + "/"
 			imageURL = imageURL + "query_" + fmt.Sprint(humanFeedbackQuery.QueryID) + "_image.png"
 
 			completeFeedbackResponse = append(completeFeedbackResponse,
@@ -840,7 +1274,7 @@ func FindRunFeedbackQueryById(services *service.Services, I18n *i18n.Localizer)
 			return
 		}
 
-		imageURL := "/work/" + fmt.Sprint(run.PipelineID) + "/" + fmt.Sprint(run.ID) + "/epochs/" + fmt.Sprint(humanFeedbackQuery.Epoch) + "/"
+		imageURL := "/api/runresults/" + fmt.Sprint(run.ID) + "/artifacts/file?path=epochs/" + fmt.Sprint(humanFeedbackQuery.Epoch) + "/"
 		imageURL = imageURL + "query_" + fmt.Sprint(humanFeedbackQuery.QueryID) + "_image.png"
 
 		completeFeedbackResponse = model.HumanFeedbackQueryResponse{
@@ -1035,3 +1469,750 @@ func SubmitRunFeedback(services *service.Services, I18n *i18n.Localizer) gin.Han
 		context.JSON(http.StatusOK, gin.H{})
 	}
 }
+
+func FindRunArtifactsByRunId(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get artifacts of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		runArtifacts, serviceError := services.RunService.FindRunArtifactsByRun(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if stepID := context.Query("stepId"); stepID != "" {
+			runArtifacts = util.Filter(runArtifacts, func(runArtifact model.RunArtifact) bool {
+				return fmt.Sprint(runArtifact.StepID) == stepID
+			})
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"artifacts": runArtifacts,
+		})
+	}
+}
+
+func CreateSweep(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		pipelineID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		var req model.SweepReq
+
+		if ok := util.BindData(context, &req); !ok {
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		pipeline, serviceError := services.PipelineService.Get(uint(pipelineID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to create sweep for pipeline %d with user: %v\n", pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Sweep(*pipeline, req)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"run": run,
+		})
+	}
+}
+
+func GetSweep(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("runID")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get sweep of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		trials, serviceError := services.RunService.FindSweepTrials(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		var best *model.Run
+
+		for i := range trials {
+			if trials[i].ID == run.BestRunID {
+				best = &trials[i]
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"run":    run,
+			"trials": trials,
+			"best":   best,
+		})
+	}
+}
+
+// runEventsKeepAlive is the interval of the ping events that keep idle event streams open through proxies.
+const runEventsKeepAlive = 30 * time.Second
+
+func GetRunEvents(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get events of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		events, serviceError := services.RunService.SubscribeRunEvents(context.Request.Context(), run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		// The current state is loaded after subscribing, so no change is lost in between
+		run, serviceError = services.RunService.Get(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		runStepStatuses, serviceError := services.RunService.FindRunStepStatusesByRun(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.Header("Cache-Control", "no-cache")
+		context.SSEvent("snapshot", gin.H{
+			"run":   run,
+			"steps": runStepStatuses,
+		})
+
+		if run.RunStatus.IsFinal {
+			return
+		}
+
+		context.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+
+				context.SSEvent(event.Type, event)
+
+				return event.Type != model.RunEventRunFinished
+			case <-time.After(runEventsKeepAlive):
+				context.SSEvent("ping", gin.H{})
+
+				return true
+			}
+		})
+	}
+}
+
+// CreateRunEventsToken issues a short-lived token that an EventSource passes as the token query parameter of
+// GET /api/run/:id/events, since it can't send the Authorization header
+func CreateRunEventsToken(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to create events token of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		token, serviceError := services.TokenService.NewRunEventsToken(user, run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"token": token,
+		})
+	}
+}
+
+func GetRunTimeline(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get timeline of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		timeline, serviceError := services.RunService.Timeline(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"timeline": timeline,
+		})
+	}
+}
+
+// CompareRuns diffs the runs of a pipeline given as a comma separated list of IDs in the ids query parameter.
+func CompareRuns(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		var runs []model.Run
+
+		for _, id := range strings.Split(context.Query("ids"), ",") {
+			if strings.TrimSpace(id) == "" {
+				continue
+			}
+
+			runID, parseError := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+
+			if parseError != nil {
+				errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "sys.parsing.string.uint",
+					TemplateData: map[string]interface{}{
+						"Reason": parseError.Error(),
+					},
+					PluralCount: 1,
+				})
+				log.Printf(errMessage)
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
+			run, serviceError := services.RunService.Get(uint(runID))
+
+			if serviceError != nil {
+				log.Printf(serviceError.Error())
+				err := errors.NewInternal(serviceError.Error())
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
+			if run.Pipeline.User.ID != user.ID {
+				errorMessage := fmt.Sprintf("Failed to compare runs of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+				log.Printf(errorMessage)
+				err := errors.NewInternal(errorMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
+			if len(runs) > 0 && run.PipelineID != runs[0].PipelineID {
+				errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "run.handler.compare.pipeline.mismatch",
+					TemplateData: map[string]interface{}{
+						"ID":         run.ID,
+						"PipelineID": runs[0].PipelineID,
+					},
+					PluralCount: 1,
+				})
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
+			runs = append(runs, *run)
+		}
+
+		if len(runs) < 2 {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID:   "run.handler.compare.ids.missing",
+				PluralCount: 1,
+			})
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		comparison, serviceError := services.RunService.Compare(runs)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"comparison": comparison,
+		})
+	}
+}
+
+func GetRunMetrics(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get metrics of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		series, serviceError := services.RunService.FindRunMetricSeries(run.ID)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		stepID := context.Query("stepId")
+		name := context.Query("name")
+
+		series = util.Filter(series, func(series model.RunMetricSeries) bool {
+			return (stepID == "" || fmt.Sprint(series.StepID) == stepID) && (name == "" || series.Name == name)
+		})
+
+		context.JSON(http.StatusOK, gin.H{
+			"metrics": series,
+		})
+	}
+}
+
+// GetRunTestResults returns the test results of the steps of a run, optionally of one step only. The predictions of
+// every sample are left out unless predictions is set.
+func GetRunTestResults(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id := context.Param("id")
+
+		runID, parseError := strconv.ParseUint(id, 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		run, serviceError := services.RunService.Get(uint(runID))
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if run.Pipeline.User.ID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get test results of pipeline %d with user: %v\n", run.Pipeline.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		withPredictions, _ := strconv.ParseBool(context.Query("predictions"))
+		testResults, serviceError := services.RunService.FindRunTestResults(run.ID, withPredictions)
+
+		if serviceError != nil {
+			log.Printf(serviceError.Error())
+			err := errors.NewInternal(serviceError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if stepID := context.Query("stepId"); stepID != "" {
+			testResults = util.Filter(testResults, func(testResult model.StepTestResults) bool {
+				return fmt.Sprint(testResult.StepID) == stepID
+			})
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"testResults": testResults,
+		})
+	}
+}
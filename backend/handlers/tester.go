@@ -278,7 +278,7 @@ func UpdateTainer(services *service.Services) gin.HandlerFunc {
 		}
 
 		if tester.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -330,7 +330,7 @@ func DeleteTester(services *service.Services, I18n *i18n.Localizer) gin.HandlerF
 		}
 
 		if tester.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -40,10 +40,11 @@ func GetTrainedModels(services *service.Services) gin.HandlerFunc {
 
 		fileUploadDir := os.Getenv("FILE_UPLOAD_DIR")
 
+		// The files are downloaded through DownloadTrainedFile, the path relative to the upload dir is what the
+		// trained step reads the file from
 		for index, model := range trained {
 			if model.Path != "" {
-				path := "/files/" + strings.Split(model.Path, fileUploadDir)[1]
-				trained[index].Path = path
+				trained[index].Path = strings.TrimPrefix(model.Path, fileUploadDir)
 			}
 		}
 
@@ -93,7 +94,7 @@ func GetTrained(services *service.Services, I18n *i18n.Localizer) gin.HandlerFun
 	}
 }
 
-func CreateTrained(services *service.Services) gin.HandlerFunc {
+func CreateTrained(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
 		var req model.TrainerReq
@@ -120,6 +121,22 @@ func CreateTrained(services *service.Services) gin.HandlerFunc {
 				return
 			}
 
+			if model.RegisteredModelID != 0 {
+				errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "trained.handler.version.immutable",
+					TemplateData: map[string]interface{}{
+						"ID":      model.ID,
+						"Version": model.Version,
+					},
+					PluralCount: 1,
+				})
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
 			model.Path = req.Path
 			err = services.TrainedService.Update(model)
 
@@ -200,6 +217,22 @@ func UploadTrainedScript(services *service.Services, I18n *i18n.Localizer) gin.H
 			return
 		}
 
+		if model.RegisteredModelID != 0 {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.version.immutable",
+				TemplateData: map[string]interface{}{
+					"ID":      model.ID,
+					"Version": model.Version,
+				},
+				PluralCount: 1,
+			})
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
 		modelUploadDir := fileUploadDir + "trained/" + modelId + "/"
 		if err := os.MkdirAll(modelUploadDir, os.ModePerm); err != nil {
 			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
@@ -250,6 +283,78 @@ func UploadTrainedScript(services *service.Services, I18n *i18n.Localizer) gin.H
 	}
 }
 
+// DownloadTrainedFile sends the file of a trained model to its owner. The upload dir of the trained models is not
+// served publicly, so this is the only way to download the weights.
+func DownloadTrainedFile(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		model, getError := services.TrainedService.Get(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if model.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to download trained model %d with user: %v\n", model.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if info, statError := os.Stat(model.Path); model.Path == "" || statError != nil || info.IsDir() {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.file.missing",
+				TemplateData: map[string]interface{}{
+					"ID": model.ID,
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewNotFound(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.FileAttachment(model.Path, filepath.Base(model.Path))
+	}
+}
+
 func UpdateTrained(services *service.Services) gin.HandlerFunc {
 	return func(context *gin.Context) {
 
@@ -278,7 +383,7 @@ func UpdateTrained(services *service.Services) gin.HandlerFunc {
 		}
 
 		if model.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -316,6 +421,7 @@ func DeleteTrained(services *service.Services, I18n *i18n.Localizer) gin.Handler
 			context.JSON(err.Status(), gin.H{
 				"error": err.Error(),
 			})
+			return
 		}
 
 		model, getError := services.TrainedService.Get(req.ID)
@@ -330,7 +436,7 @@ func DeleteTrained(services *service.Services, I18n *i18n.Localizer) gin.Handler
 		}
 
 		if model.UserID != user.ID {
-			errorMessage := fmt.Sprint("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
+			errorMessage := fmt.Sprintf("Pipeline with id %v does not belong to user %v\n", req.ID, user.Username)
 			log.Printf(errorMessage)
 			err := errors.NewAuthorization("")
 			context.JSON(err.Status(), gin.H{
@@ -339,6 +445,23 @@ func DeleteTrained(services *service.Services, I18n *i18n.Localizer) gin.Handler
 			return
 		}
 
+		// The versions runs registered are immutable, so that the lineage of a model can always be traced
+		if model.RegisteredModelID != 0 {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.version.immutable",
+				TemplateData: map[string]interface{}{
+					"ID":      model.ID,
+					"Version": model.Version,
+				},
+				PluralCount: 1,
+			})
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
 		deleteError := services.TrainedService.Delete(req.ID)
 
 		if deleteError != nil {
@@ -353,3 +476,482 @@ func DeleteTrained(services *service.Services, I18n *i18n.Localizer) gin.Handler
 		context.JSON(http.StatusOK, gin.H{})
 	}
 }
+
+func GetRegisteredModels(services *service.Services) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		registeredModels, getError := services.TrainedService.GetRegisteredModels(user.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"models": registeredModels,
+		})
+	}
+}
+
+func GetRegisteredModel(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		registeredModel, getError := services.TrainedService.GetRegisteredModel(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if registeredModel.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get registered model %d with user: %v\n", registeredModel.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		versions, getError := services.TrainedService.GetVersions(registeredModel.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		registeredModel.Versions = versions
+
+		context.JSON(http.StatusOK, gin.H{
+			"model": registeredModel,
+		})
+	}
+}
+
+// GetTrainedLineage traces a Trained model back to what produced it. Models uploaded by hand have no lineage, and
+// the parts of it deleted since are left out.
+func GetTrainedLineage(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		trained, getError := services.TrainedService.Get(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if trained.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get lineage of trained model %d with user: %v\n", trained.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		lineage := model.TrainedLineage{Trained: *trained}
+
+		if trained.RegisteredModelID != 0 {
+			lineage.RegisteredModel, _ = services.TrainedService.GetRegisteredModel(trained.RegisteredModelID)
+		}
+
+		if trained.RunID != 0 {
+			lineage.Run, _ = services.RunService.Get(trained.RunID)
+		}
+
+		if trained.PipelineID != 0 {
+			lineage.Pipeline, _ = services.PipelineService.Get(trained.PipelineID)
+		}
+
+		if trained.DatasetID != 0 {
+			lineage.Dataset, _ = services.DatasetService.Get(trained.DatasetID)
+		}
+
+		if trained.TrainerID != 0 {
+			lineage.Trainer, _ = services.TrainerService.Get(trained.TrainerID)
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"lineage": lineage,
+		})
+	}
+}
+
+// UpdateTrainedStage moves a registered model version to another stage. Promotions to production must meet the
+// metric thresholds of the registered model, and archive the version that was in production.
+func UpdateTrainedStage(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		var req model.TrainedStageReq
+
+		if ok := util.BindData(context, &req); !ok {
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		trained, getError := services.TrainedService.Get(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if trained.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to update stage of trained model %d with user: %v\n", trained.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		var errMessage string
+
+		switch {
+		case !util.StringArrayContains(model.TrainedStages, req.Stage):
+			errMessage = I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.stage.invalid",
+				TemplateData: map[string]interface{}{
+					"Stage":  req.Stage,
+					"Stages": strings.Join(model.TrainedStages, ", "),
+				},
+				PluralCount: 1,
+			})
+		case trained.RegisteredModelID == 0:
+			errMessage = I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.stage.unregistered",
+				TemplateData: map[string]interface{}{
+					"ID": trained.ID,
+				},
+				PluralCount: 1,
+			})
+		case trained.Stage == req.Stage:
+			errMessage = I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "trained.handler.stage.unchanged",
+				TemplateData: map[string]interface{}{
+					"ID":    trained.ID,
+					"Stage": req.Stage,
+				},
+				PluralCount: 1,
+			})
+		}
+
+		if errMessage != "" {
+			err := errors.NewBadRequest(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if req.Stage == model.TrainedStageProduction {
+			unmet, checkError := services.TrainedService.UnmetThresholds(trained)
+
+			if checkError != nil {
+				log.Printf(checkError.Error())
+				err := errors.NewInternal(checkError.Error())
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+
+			if len(unmet) > 0 {
+				errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "trained.handler.stage.thresholds.unmet",
+					TemplateData: map[string]interface{}{
+						"ID":         trained.ID,
+						"Thresholds": strings.Join(unmet, ", "),
+					},
+					PluralCount: 1,
+				})
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+		}
+
+		transitions, updateError := services.TrainedService.TransitionStage(trained, req.Stage, user.ID, req.Comment)
+
+		if updateError != nil {
+			log.Printf(updateError.Error())
+			err := errors.NewInternal(updateError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"trained":     trained,
+			"transitions": transitions,
+		})
+	}
+}
+
+func GetStageTransitions(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		registeredModel, getError := services.TrainedService.GetRegisteredModel(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if registeredModel.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to get stage transitions of registered model %d with user: %v\n", registeredModel.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		transitions, getError := services.TrainedService.GetStageTransitions(registeredModel.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewInternal(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"transitions": transitions,
+		})
+	}
+}
+
+// UpdateModelThresholds replaces the metric thresholds versions of a registered model must meet to be promoted to
+// production. An empty list removes them.
+func UpdateModelThresholds(services *service.Services, I18n *i18n.Localizer) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		id, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			err := errors.NewInternal(errMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		var req model.MetricThresholdsReq
+
+		if ok := util.BindData(context, &req); !ok {
+			return
+		}
+
+		for _, threshold := range req.Thresholds {
+			if threshold.Metric == "" || !util.StringArrayContains(model.MetricThresholdOperators, threshold.Operator) {
+				errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "trained.handler.threshold.invalid",
+					TemplateData: map[string]interface{}{
+						"Metric":    threshold.Metric,
+						"Operators": strings.Join(model.MetricThresholdOperators, ", "),
+					},
+					PluralCount: 1,
+				})
+				err := errors.NewBadRequest(errMessage)
+				context.JSON(err.Status(), gin.H{
+					"error": err.Message,
+				})
+				return
+			}
+		}
+
+		user, err := getUser(context)
+		if err != nil {
+			context.JSON(err.Status(), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		registeredModel, getError := services.TrainedService.GetRegisteredModel(uint(id))
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			err := errors.NewNotFound(getError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if registeredModel.UserID != user.ID {
+			errorMessage := fmt.Sprintf("Failed to update thresholds of registered model %d with user: %v\n", registeredModel.ID, user.Username)
+			log.Printf(errorMessage)
+			err := errors.NewInternal(errorMessage)
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		if updateError := services.TrainedService.SetThresholds(registeredModel, req.Thresholds); updateError != nil {
+			log.Printf(updateError.Error())
+			err := errors.NewInternal(updateError.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err.Message,
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"model": registeredModel,
+		})
+	}
+}
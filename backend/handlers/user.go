@@ -139,7 +139,7 @@ func LogIn(services *service.Services) gin.HandlerFunc {
 			log.Printf("Failed to create tokens for user: %v\n", err.Error())
 
 			context.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprint("Failed to create tokens for user: %v\n", err.Error()),
+				"error": fmt.Sprintf("Failed to create tokens for user: %v\n", err.Error()),
 			})
 
 			return
@@ -186,7 +186,7 @@ func SignUp(services *service.Services) gin.HandlerFunc {
 			log.Printf("Failed to create tokens for user: %v\n", err.Error())
 
 			context.JSON(http.StatusInternalServerError, gin.H{
-				"error": fmt.Sprint("Failed to create tokens for user: %v\n", err.Error()),
+				"error": fmt.Sprintf("Failed to create tokens for user: %v\n", err.Error()),
 			})
 			return
 		}
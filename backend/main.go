@@ -38,17 +38,21 @@ func main() {
 	client := util.GetAsynqClient()
 	defer client.Close()
 
+	inspector := util.GetAsynqInspector()
+	defer inspector.Close()
+
 	i18n := util.GetI18nLocalizer()
 
 	pipelineService := service.NewPipelineService(dbConnection, client, i18n)
 	pipelineService.SyncAsyncTasks()
-	stepTypeService := service.NewNodeService(i18n)
-	trainedService := service.NewTrainedService(dbConnection, client, i18n)
-	runService := service.NewRunService(dbConnection, client, i18n, &pipelineService, &stepTypeService, &trainedService)
-	taskService := service.NewTaskService(i18n, &stepTypeService, &runService)
 	datasetService := service.NewDatasetService(dbConnection, client, i18n)
 	trainerService := service.NewTrainerService(dbConnection, client, i18n)
 	testerService := service.NewTesterService(dbConnection, client, i18n)
+	trainedService := service.NewTrainedService(dbConnection, client, i18n)
+	stepTypeService := service.NewNodeService(i18n, &datasetService, &trainerService, &testerService, &trainedService)
+	runService := service.NewRunService(dbConnection, client, inspector, redisClient, i18n, &pipelineService, &stepTypeService, &trainedService)
+	taskService := service.NewTaskService(i18n, &stepTypeService, &runService)
+	runService.StartRunReconciler()
 
 	services := &service.Services{
 		UserService:     service.NewUserService(dbConnection, i18n),
@@ -59,6 +63,7 @@ func main() {
 		TrainerService:  trainerService,
 		TesterService:   testerService,
 		TrainedService:  trainedService,
+		StepService:     stepTypeService,
 	}
 
 	r := setupRouter(services, i18n)
@@ -80,15 +85,13 @@ func setupRouter(services *service.Services, I18n *i18n.Localizer) *gin.Engine {
 	router.Static("/public", "../client/public")
 	router.Static("/assets", "../client/src/assets")
 
-	workDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
-
-	if !exists {
+	// The work dir is served by the run artifacts API only, once the ownership of the run is checked
+	if _, exists := os.LookupEnv("PIPELINES_WORK_DIR"); !exists {
 		panic("PIPELINES_WORK_DIR is not defined")
 	}
 
-	logsDir, exists := os.LookupEnv("RUN_LOGS_DIR")
-
-	if !exists {
+	// The logs dir is served by the run results API only, once the ownership of the run is checked
+	if _, exists := os.LookupEnv("RUN_LOGS_DIR"); !exists {
 		panic("RUN_LOGS_DIR is not defined")
 	}
 
@@ -98,9 +101,8 @@ func setupRouter(services *service.Services, I18n *i18n.Localizer) *gin.Engine {
 		panic("FILE_UPLOAD_DIR is not defined")
 	}
 
-	router.StaticFS("/work", http.Dir(workDir))
-	router.StaticFS("/logs", http.Dir(logsDir))
-	router.StaticFS("/files", http.Dir(filesDir))
+	// The trained models are downloaded through the trained API, once the ownership of the model is checked
+	router.StaticFS("/files", util.HiddenDirsFileSystem{FileSystem: http.Dir(filesDir), Dirs: []string{"trained"}})
 
 	router.LoadHTMLFiles("../client/index.html")
 
@@ -121,6 +123,8 @@ func setupRouter(services *service.Services, I18n *i18n.Localizer) *gin.Engine {
 	pipelineAPI.GET("/:id", middleware.Auth(services.TokenService, I18n), handlers.GetPipeline(services, I18n))
 	pipelineAPI.GET("/:id/schedule", middleware.Auth(services.TokenService, I18n), handlers.GetPipelineSchedule(services, I18n))
 	pipelineAPI.POST("/:id/schedule", middleware.Auth(services.TokenService, I18n), handlers.CreatePipelineSchedule(services, I18n))
+	pipelineAPI.GET("/:id/test-results", middleware.Auth(services.TokenService, I18n), handlers.GetPipelineTestResults(services, I18n))
+	pipelineAPI.POST("/:id/validate", middleware.Auth(services.TokenService, I18n), handlers.ValidatePipeline(services, I18n))
 	pipelineAPI.POST("/:id/file", middleware.Auth(services.TokenService, I18n), handlers.UploadPipelineFile(services, I18n))
 	pipelineAPI.POST("/:id", middleware.Auth(services.TokenService, I18n), handlers.UpsertPipeline(services))
 	pipelineAPI.DELETE("", middleware.Auth(services.TokenService, I18n), handlers.DeletePipeline(services))
@@ -128,14 +132,29 @@ func setupRouter(services *service.Services, I18n *i18n.Localizer) *gin.Engine {
 
 	runAPI := router.Group("/api/run")
 	runAPI.GET("", middleware.Auth(services.TokenService, I18n), handlers.GetRuns(services))
+	runAPI.GET("/compare", middleware.Auth(services.TokenService, I18n), handlers.CompareRuns(services, I18n))
 	runAPI.GET("/:id", middleware.Auth(services.TokenService, I18n), handlers.FindRunsByPipeline(services, I18n))
 	runAPI.POST("/:id", middleware.Auth(services.TokenService, I18n), handlers.CreateRun(services, I18n))
 	runAPI.POST("/execute/:runID", middleware.Auth(services.TokenService, I18n), handlers.ExecuteRun(services, I18n))
 	runAPI.POST("/resume/:runID", middleware.Auth(services.TokenService, I18n), handlers.ResumeRun(services, I18n))
+	runAPI.POST("/cancel/:runID", middleware.Auth(services.TokenService, I18n), handlers.CancelRun(services, I18n))
+	runAPI.POST("/rerun/:runID/:stepID", middleware.Auth(services.TokenService, I18n), handlers.RerunRun(services, I18n))
+	runAPI.POST("/sweep/:id", middleware.Auth(services.TokenService, I18n), handlers.CreateSweep(services, I18n))
+	runAPI.GET("/:id/events", middleware.RunEventsAuth(services.TokenService, I18n), handlers.GetRunEvents(services, I18n))
+	runAPI.POST("/:id/events/token", middleware.Auth(services.TokenService, I18n), handlers.CreateRunEventsToken(services, I18n))
+	runAPI.GET("/sweep/:runID", middleware.Auth(services.TokenService, I18n), handlers.GetSweep(services, I18n))
 
 	runResultsAPI := router.Group("/api/runresults")
 	runResultsAPI.GET("/:id", middleware.Auth(services.TokenService, I18n), handlers.FindRunResulstById(services, I18n))
 	runResultsAPI.GET("/:id/log", middleware.Auth(services.TokenService, I18n), handlers.GetLogTail(services, I18n))
+	runResultsAPI.GET("/:id/steps/:stepId/log", middleware.Auth(services.TokenService, I18n), handlers.GetStepLog(services, I18n))
+	runResultsAPI.GET("/:id/outputs", middleware.Auth(services.TokenService, I18n), handlers.FindRunArtifactsByRunId(services, I18n))
+	runResultsAPI.GET("/:id/artifacts", middleware.Auth(services.TokenService, I18n), handlers.GetRunArtifactFiles(services, I18n))
+	runResultsAPI.GET("/:id/artifacts/file", middleware.Auth(services.TokenService, I18n), handlers.DownloadRunArtifactFile(services, I18n))
+	runResultsAPI.GET("/:id/artifacts/archive", middleware.Auth(services.TokenService, I18n), handlers.DownloadRunArtifactArchive(services, I18n))
+	runResultsAPI.GET("/:id/timeline", middleware.Auth(services.TokenService, I18n), handlers.GetRunTimeline(services, I18n))
+	runResultsAPI.GET("/:id/metrics", middleware.Auth(services.TokenService, I18n), handlers.GetRunMetrics(services, I18n))
+	runResultsAPI.GET("/:id/test-results", middleware.Auth(services.TokenService, I18n), handlers.GetRunTestResults(services, I18n))
 
 	feedbackAPI := router.Group("/api/feedback")
 	feedbackAPI.GET("/:id", middleware.Auth(services.TokenService, I18n), handlers.FindRunFeedbackQueriesByRunId(services, I18n))
@@ -165,8 +184,15 @@ func setupRouter(services *service.Services, I18n *i18n.Localizer) *gin.Engine {
 
 	trainedAPI := router.Group("/api/trained")
 	trainedAPI.GET("", middleware.Auth(services.TokenService, I18n), handlers.GetTrainedModels(services))
-	trainedAPI.POST("", middleware.Auth(services.TokenService, I18n), handlers.CreateTrained(services))
+	trainedAPI.POST("", middleware.Auth(services.TokenService, I18n), handlers.CreateTrained(services, I18n))
+	trainedAPI.GET("/registry", middleware.Auth(services.TokenService, I18n), handlers.GetRegisteredModels(services))
+	trainedAPI.GET("/registry/:id", middleware.Auth(services.TokenService, I18n), handlers.GetRegisteredModel(services, I18n))
+	trainedAPI.GET("/registry/:id/transitions", middleware.Auth(services.TokenService, I18n), handlers.GetStageTransitions(services, I18n))
+	trainedAPI.PUT("/registry/:id/thresholds", middleware.Auth(services.TokenService, I18n), handlers.UpdateModelThresholds(services, I18n))
 	trainedAPI.GET("/:id", middleware.Auth(services.TokenService, I18n), handlers.GetTrained(services, I18n))
+	trainedAPI.GET("/:id/lineage", middleware.Auth(services.TokenService, I18n), handlers.GetTrainedLineage(services, I18n))
+	trainedAPI.POST("/:id/stage", middleware.Auth(services.TokenService, I18n), handlers.UpdateTrainedStage(services, I18n))
+	trainedAPI.GET("/:id/file", middleware.Auth(services.TokenService, I18n), handlers.DownloadTrainedFile(services, I18n))
 	trainedAPI.POST("/:id/file", middleware.Auth(services.TokenService, I18n), handlers.UploadTrainedScript(services, I18n))
 	trainedAPI.DELETE("", middleware.Auth(services.TokenService, I18n), handlers.DeleteTrained(services, I18n))
 
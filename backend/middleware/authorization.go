@@ -3,6 +3,7 @@ package middleware
 import (
 	"di/service"
 	"di/util/errors"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -92,3 +93,52 @@ func Auth(tokenService service.TokenService, I18n *i18n.Localizer) gin.HandlerFu
 		context.Next()
 	}
 }
+
+// RunEventsAuth authenticates the subscriptions to the events of a run. An EventSource can't send the Authorization
+// header, so it passes a run events token in the token query parameter instead; any other client uses Auth.
+func RunEventsAuth(tokenService service.TokenService, I18n *i18n.Localizer) gin.HandlerFunc {
+	auth := Auth(tokenService, I18n)
+
+	return func(context *gin.Context) {
+		token := context.Query("token")
+
+		if token == "" {
+			auth(context)
+			return
+		}
+
+		runID, parseError := strconv.ParseUint(context.Param("id"), 10, 64)
+
+		if parseError != nil {
+			errorMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sys.parsing.string.uint",
+				TemplateData: map[string]interface{}{
+					"Reason": parseError.Error(),
+				},
+				PluralCount: 1,
+			})
+			err := errors.NewBadRequest(errorMessage)
+
+			context.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			context.Abort()
+			return
+		}
+
+		user, err := tokenService.ValidateRunEventsToken(token, uint(runID))
+
+		if err != nil {
+			err := errors.NewAuthorization(err.Error())
+			context.JSON(err.Status(), gin.H{
+				"error": err,
+			})
+			context.Abort()
+			return
+		}
+
+		context.Set("user", user)
+
+		context.Next()
+	}
+}
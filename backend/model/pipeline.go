@@ -13,6 +13,8 @@ type Pipeline struct {
 	User       User
 	Name       string    `json:"name"`
 	Definition string    `json:"definition"`
+	Parameters string    `json:"parameters"`
+	Queue      string    `json:"queue"`
 	LastRun    time.Time `gorm:"-:all"`
 }
 
@@ -28,6 +30,14 @@ type CreatePipelineReq struct {
 	User       string `json:"user"`
 	Name       string `json:"name"`
 	Definition string `json:"definition"`
+	Parameters string `json:"parameters"`
+}
+
+// PipelineParameter is a typed parameter a pipeline declares. Step configs refer to it as ${params.name}.
+type PipelineParameter struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"` // string, int, float or bool
+	Default interface{} `json:"default"`
 }
 
 type PipelineReq struct {
@@ -35,6 +45,15 @@ type PipelineReq struct {
 	User       string `json:"user"`
 	Name       string `json:"name"`
 	Definition string `json:"definition"`
+	Parameters string `json:"parameters"`
+	Queue      string `json:"queue"`
+}
+
+type PipelineDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	StepID   string `json:"stepId"`
+	Message  string `json:"message"`
 }
 
 type PipelineScheduleReq struct {
@@ -146,6 +165,12 @@ type StepDataConfig struct {
 	Optimizer        null.String `json:"optimizer"`
 	// Custom
 	CustomArguments null.String `json:"customArguments"`
+	// Execution
+	Timeout      null.Int    `json:"timeout"`
+	MaxRetries   null.Int    `json:"maxRetries"`
+	RetryBackoff null.Int    `json:"retryBackoff"`
+	OnFailure    null.String `json:"onFailure"`
+	Cache        bool        `json:"cache"`
 	// Dataset
 	DatasetID   uint   `json:"datasetID"`
 	DatasetName string `json:"datasetName"`
@@ -163,6 +188,9 @@ type StepDataConfig struct {
 	TrainedID   uint   `json:"trainedID"`
 	TrainedName string `json:"trainedName"`
 	TrainedPath string `json:"trainedPath"`
+	// ModelName refers to the latest version in ModelStage, production when empty, of a registered model instead
+	ModelName  string `json:"modelName"`
+	ModelStage string `json:"modelStage"`
 }
 
 type StepData struct {
@@ -3,6 +3,7 @@ package model
 import (
 	"time"
 
+	"gopkg.in/guregu/null.v4"
 	"gorm.io/gorm"
 )
 
@@ -15,26 +16,85 @@ type RunStatus struct {
 
 type Run struct {
 	gorm.Model
-	PipelineID          uint `json:"pipelineId"`
-	Pipeline            Pipeline
-	RunStatusID         uint
-	RunStatus           RunStatus
-	ErrorMessage        string
-	Definition          string
-	StepWaitingFeedback int
-	LastRun             time.Time
+	PipelineID   uint `json:"pipelineId"`
+	Pipeline     Pipeline
+	RunStatusID  uint
+	RunStatus    RunStatus
+	ErrorMessage string
+	// Definition is the definition the run executes, stored with its parameters and model references resolved once
+	// the run starts
+	Definition string
+	// StepsWaitingFeedback are the ids of the steps the run waits for feedback for
+	StepsWaitingFeedback []int `gorm:"serializer:json"`
+	MaxParallelSteps     uint
+	TaskID               string
+	TaskQueue            string
+	Parameters           string
+	// Sweeps
+	ParentRunID uint `gorm:"index"`
+	Sweep       string
+	SweepConfig string
+	SweepScore  null.Float
+	BestRunID   uint
+	LastRun     time.Time
+	// Timing, DurationMs leaves out the time spent waiting for feedback
+	StartedAt  null.Time
+	ResumedAt  null.Time
+	FinishedAt null.Time
+	DurationMs int64
 }
 
 type RunStepStatus struct {
 	gorm.Model
-	Name         string
-	StepID       int
-	RunID        uint
-	Run          Run
-	RunStatusID  uint
-	RunStatus    RunStatus
-	ErrorMessage string
-	LastRun      time.Time
+	Name            string
+	StepID          int
+	RunID           uint
+	Run             Run
+	RunStatusID     uint
+	RunStatus       RunStatus
+	ErrorMessage    string
+	LastRun         time.Time
+	OutputHash      string
+	CachedFromRunID uint
+	Attempts        []RunStepAttempt
+	// Timing and resource usage of the processes the step ran, DurationMs leaves out the time spent waiting for feedback
+	StartedAt   null.Time
+	ResumedAt   null.Time
+	FinishedAt  null.Time
+	DurationMs  int64
+	CPUUserMs   int64
+	CPUSystemMs int64
+	MaxRSS      int64
+	ExitCode    null.Int
+}
+
+type RunArtifact struct {
+	gorm.Model
+	RunID    uint `gorm:"index"`
+	StepID   int
+	StepName string
+	Name     string
+	Path     string
+	Size     int64
+	Checksum string
+}
+
+type StepCacheEntry struct {
+	gorm.Model
+	Key        string `gorm:"uniqueIndex"`
+	UserID     uint   `gorm:"index"`
+	RunID      uint
+	StepID     int
+	OutputHash string
+}
+
+type RunStepAttempt struct {
+	gorm.Model
+	RunStepStatusID uint `gorm:"index"`
+	Attempt         uint
+	ErrorMessage    string
+	StartedAt       time.Time
+	FinishedAt      time.Time
 }
 
 type HumanFeedbackQueryPayload struct {
@@ -93,9 +153,210 @@ type HumanFeedbackQueryReq struct {
 }
 
 type CreateRunReq struct {
-	Execute bool `json:"execute"`
+	Execute          bool                   `json:"execute"`
+	MaxParallelSteps uint                   `json:"maxParallelSteps"`
+	Parameters       map[string]interface{} `json:"parameters"`
 }
 
 type ExecuteRunReq struct {
 	ID uint `json:"id"`
 }
+
+// SweepParameter is the search space of a step config field. Grid searches use Values, random searches pick one of
+// Values or, when no values are given, a uniformly distributed number between Min and Max.
+type SweepParameter struct {
+	Type   string        `json:"type"` // float (default) or int, which rounds the values sampled from Min and Max
+	Values []interface{} `json:"values"`
+	Min    null.Float    `json:"min"`
+	Max    null.Float    `json:"max"`
+}
+
+type SweepReq struct {
+	StepID           string                    `json:"stepId"`
+	Strategy         string                    `json:"strategy"` // grid (default) or random
+	Space            map[string]SweepParameter `json:"space"`
+	Samples          uint                      `json:"samples"`
+	Seed             int64                     `json:"seed"`
+	MaxConcurrency   uint                      `json:"maxConcurrency"`
+	Objective        string                    `json:"objective"` // maximize (default) or minimize
+	MaxParallelSteps uint                      `json:"maxParallelSteps"`
+	Parameters       map[string]interface{}    `json:"parameters"`
+}
+
+// Types of the run events published as run and step statuses change
+const (
+	RunEventStepStarted     = "step-started"
+	RunEventStepFinished    = "step-finished"
+	RunEventStepFailed      = "step-failed"
+	RunEventWaitingFeedback = "waiting-feedback"
+	RunEventRunStatus       = "run-status"
+	RunEventRunFinished     = "run-finished"
+)
+
+// RunEvent is a status change of a run or one of its steps
+type RunEvent struct {
+	Type     string    `json:"type"`
+	RunID    uint      `json:"runId"`
+	StepID   int       `json:"stepId"`
+	StepName string    `json:"stepName"`
+	StatusID uint      `json:"statusId"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// RunTimeline is when the steps of a run executed and the resources they used, laid out to draw a Gantt chart
+type RunTimeline struct {
+	RunID       uint              `json:"runId"`
+	StatusID    uint              `json:"statusId"`
+	StartedAt   null.Time         `json:"startedAt"`
+	FinishedAt  null.Time         `json:"finishedAt"`
+	DurationMs  int64             `json:"durationMs"`
+	CPUUserMs   int64             `json:"cpuUserMs"`
+	CPUSystemMs int64             `json:"cpuSystemMs"`
+	MaxRSS      int64             `json:"maxRss"`
+	Steps       []RunTimelineStep `json:"steps"`
+}
+
+type RunTimelineStep struct {
+	StepID      int              `json:"stepId"`
+	Name        string           `json:"name"`
+	StatusID    uint             `json:"statusId"`
+	StartedAt   null.Time        `json:"startedAt"`
+	FinishedAt  null.Time        `json:"finishedAt"`
+	OffsetMs    int64            `json:"offsetMs"` // since the run started
+	DurationMs  int64            `json:"durationMs"`
+	CPUUserMs   int64            `json:"cpuUserMs"`
+	CPUSystemMs int64            `json:"cpuSystemMs"`
+	MaxRSS      int64            `json:"maxRss"`
+	ExitCode    null.Int         `json:"exitCode"`
+	Attempts    []RunStepAttempt `json:"attempts"`
+}
+
+// RunComparison is the difference between runs of a pipeline. Every list of values holds one value per compared run,
+// in the order the runs were given, where the first run is the baseline the others are compared to.
+type RunComparison struct {
+	PipelineID uint               `json:"pipelineId"`
+	Runs       []RunComparisonRun `json:"runs"`
+	Parameters []ValueComparison  `json:"parameters"`
+	Metrics    []ValueComparison  `json:"metrics"`
+	Steps      []StepComparison   `json:"steps"`
+}
+
+type RunComparisonRun struct {
+	RunID        uint      `json:"runId"`
+	StatusID     uint      `json:"statusId"`
+	ErrorMessage string    `json:"errorMessage"`
+	StartedAt    null.Time `json:"startedAt"`
+	DurationMs   int64     `json:"durationMs"`
+}
+
+type ValueComparison struct {
+	Name    string        `json:"name"`
+	Values  []interface{} `json:"values"`
+	Changed bool          `json:"changed"`
+}
+
+// StepComparison is the difference of a step between runs. Config only holds the step config fields that changed.
+type StepComparison struct {
+	StepID    string               `json:"stepId"`
+	Name      string               `json:"name"`
+	Changed   bool                 `json:"changed"`
+	Config    []ValueComparison    `json:"config"`
+	Runs      []StepComparisonRun  `json:"runs"`
+	Artifacts []ArtifactComparison `json:"artifacts"`
+}
+
+type StepComparisonRun struct {
+	RunID      uint   `json:"runId"`
+	InPipeline bool   `json:"inPipeline"`
+	StatusID   uint   `json:"statusId"`
+	DurationMs int64  `json:"durationMs"`
+	OutputHash string `json:"outputHash"`
+}
+
+// ArtifactComparison is an artifact of a step in every run, with an empty checksum where a run did not produce it.
+type ArtifactComparison struct {
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	Checksums []string `json:"checksums"`
+	Sizes     []int64  `json:"sizes"`
+	Changed   bool     `json:"changed"`
+}
+
+// RunMetric is a value of a metric reported by a step, like the loss of a training epoch
+type RunMetric struct {
+	gorm.Model
+	RunID     uint `gorm:"index"`
+	StepID    int
+	StepName  string
+	Name      string
+	Value     float64
+	Iteration null.Int
+	Time      time.Time
+}
+
+// RunMetricSeries are the values of a metric of a step in the order they were reported. The points are laid out as
+// chart points, with the iteration, or the position of the value when the step gave none, as x.
+type RunMetricSeries struct {
+	Name     string           `json:"name"`
+	StepID   int              `json:"stepId"`
+	StepName string           `json:"stepName"`
+	Points   []RunMetricPoint `json:"points"`
+	Last     float64          `json:"last"`
+}
+
+type RunMetricPoint struct {
+	X    int64     `json:"x"`
+	Y    float64   `json:"y"`
+	Time time.Time `json:"time"`
+}
+
+// TestResults is the contract of the test results file a Tester script writes to report how the model did. Every
+// part is optional. Class scores are keyed by class, then by score, like precision, recall, f1 and support.
+type TestResults struct {
+	Metrics         map[string]float64            `json:"metrics"`
+	ConfusionMatrix *ConfusionMatrix              `json:"confusionMatrix,omitempty"`
+	ClassScores     map[string]map[string]float64 `json:"classScores,omitempty"`
+	Predictions     []TestPrediction              `json:"predictions,omitempty"`
+}
+
+// ConfusionMatrix counts the samples of each true label, the rows, by predicted label, the columns, in the order of
+// Labels. Normalized, Total and Accuracy are derived from the matrix when the results are stored.
+type ConfusionMatrix struct {
+	Labels     []string    `json:"labels"`
+	Matrix     [][]float64 `json:"matrix"`
+	Normalized [][]float64 `json:"normalized,omitempty"`
+	Total      float64     `json:"total"`
+	Accuracy   float64     `json:"accuracy"`
+}
+
+// TestPrediction is the prediction for one sample of the test set, Score being the confidence of the prediction
+type TestPrediction struct {
+	Sample     string     `json:"sample"`
+	Label      string     `json:"label"`
+	Prediction string     `json:"prediction"`
+	Score      null.Float `json:"score"`
+}
+
+// RunTestResult are the test results of a step of a run. Results holds TestResults as JSON but for the predictions,
+// which are kept apart in Predictions as they can be large.
+type RunTestResult struct {
+	gorm.Model
+	RunID           uint `gorm:"index"`
+	StepID          int
+	StepName        string
+	Results         string
+	Predictions     string
+	PredictionCount int
+}
+
+// StepTestResults are the test results of a step of a run as the API serves them
+type StepTestResults struct {
+	ID              uint        `json:"id"`
+	RunID           uint        `json:"runId"`
+	StepID          int         `json:"stepId"`
+	StepName        string      `json:"stepName"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	Results         TestResults `json:"results"`
+	PredictionCount int         `json:"predictionCount"`
+}
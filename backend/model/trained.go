@@ -8,6 +8,71 @@ type Trained struct {
 	User   User
 	Name   string `json:"name" gorm:"uniqueIndex"`
 	Path   string `json:"path"`
+	// Registry. Models produced by runs are immutable versions of a registered model, uploaded ones have no version.
+	RegisteredModelID uint   `json:"registeredModelId" gorm:"index"`
+	Version           uint   `json:"version"`
+	RunID             uint   `json:"runId"`
+	PipelineID        uint   `json:"pipelineId"`
+	StepID            int    `json:"stepId"`
+	StepName          string `json:"stepName"`
+	DatasetID         uint   `json:"datasetId"`
+	TrainerID         uint   `json:"trainerId"`
+	Metrics           string `json:"metrics"`
+	Checksum          string `json:"checksum"`
+	Size              int64  `json:"size"`
+	Stage             string `json:"stage" gorm:"default:none"`
+}
+
+// Lifecycle stages of a registered model version. A model has at most one version in production.
+const (
+	TrainedStageNone       = "none"
+	TrainedStageStaging    = "staging"
+	TrainedStageProduction = "production"
+	TrainedStageArchived   = "archived"
+)
+
+var TrainedStages = []string{TrainedStageNone, TrainedStageStaging, TrainedStageProduction, TrainedStageArchived}
+
+// RegisteredModel is a model name of a user, whose versions are the Trained models runs produced under that name
+type RegisteredModel struct {
+	gorm.Model
+	UserID   uint      `json:"userId" gorm:"uniqueIndex:idx_registered_model_user_name"`
+	Name     string    `json:"name" gorm:"uniqueIndex:idx_registered_model_user_name"`
+	Versions []Trained `json:"versions,omitempty"`
+	// Thresholds is the JSON list of MetricThreshold a version must meet to be promoted to production
+	Thresholds string `json:"thresholds"`
+}
+
+// MetricThreshold requires the metric of a version to compare with Value by Operator, one of >, >=, <, <= and ==.
+// Metrics are named stepName/metric, a Metric without a step name applies to the metric of every step.
+type MetricThreshold struct {
+	Metric   string  `json:"metric"`
+	Operator string  `json:"operator"`
+	Value    float64 `json:"value"`
+}
+
+var MetricThresholdOperators = []string{">", ">=", "<", "<=", "=="}
+
+// ModelStageTransition records a change of stage of a registered model version and who made it
+type ModelStageTransition struct {
+	gorm.Model
+	TrainedID         uint   `json:"trainedId" gorm:"index"`
+	RegisteredModelID uint   `json:"registeredModelId" gorm:"index"`
+	Version           uint   `json:"version"`
+	UserID            uint   `json:"userId"`
+	FromStage         string `json:"fromStage"`
+	ToStage           string `json:"toStage"`
+	Comment           string `json:"comment"`
+}
+
+// TrainedLineage traces a Trained model back to the run, pipeline, step, dataset and trainer that produced it
+type TrainedLineage struct {
+	Trained         Trained          `json:"trained"`
+	RegisteredModel *RegisteredModel `json:"registeredModel"`
+	Run             *Run             `json:"run"`
+	Pipeline        *Pipeline        `json:"pipeline"`
+	Dataset         *Dataset         `json:"dataset"`
+	Trainer         *Trainer         `json:"trainer"`
 }
 
 type TrainedReq struct {
@@ -16,3 +81,12 @@ type TrainedReq struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
 }
+
+type TrainedStageReq struct {
+	Stage   string `json:"stage" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+type MetricThresholdsReq struct {
+	Thresholds []MetricThreshold `json:"thresholds"`
+}
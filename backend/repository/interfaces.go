@@ -66,12 +66,26 @@ type TrainedModelRepository interface {
 	Create(trained *model.Trained) error
 	Update(trained *model.Trained) error
 	Delete(trainedID uint) error
+	FindRegisteredModelByID(registeredModelID uint) (*model.RegisteredModel, error)
+	FindRegisteredModelByName(ownerID uint, name string) (*model.RegisteredModel, error)
+	FindRegisteredModelsByOwner(ownerID uint) ([]model.RegisteredModel, error)
+	CreateRegisteredModel(registeredModel *model.RegisteredModel) error
+	FindVersions(registeredModelID uint) ([]model.Trained, error)
+	FindVersionsByRun(registeredModelID uint, runID uint) ([]model.Trained, error)
+	CreateVersion(trained *model.Trained) error
+	UpdateRegisteredModel(registeredModel *model.RegisteredModel) error
+	FindVersionsByStage(registeredModelID uint, stage string) ([]model.Trained, error)
+	FindStageTransitions(registeredModelID uint) ([]model.ModelStageTransition, error)
+	TransitionStage(trained *model.Trained, transition *model.ModelStageTransition) ([]model.ModelStageTransition, error)
 }
 
 type RunRepository interface {
 	FindByID(runID uint) (*model.Run, error)
 	FindByPipeline(pipelineID uint) ([]model.Run, error)
+	FindByParent(parentRunID uint) ([]model.Run, error)
+	FindByStatus(runStatusID uint) ([]model.Run, error)
 	FindRunStepStatusesByRun(runID uint) ([]model.RunStepStatus, error)
+	FindRunArtifactsByRun(runID uint) ([]model.RunArtifact, error)
 	FindHumanFeedbackQueriesByStepID(runID uint, stepID uint) ([]model.HumanFeedbackQuery, error)
 	FindHumanFeedbackQueriesByRunID(runID uint) ([]model.HumanFeedbackQuery, error)
 	FindHumanFeedbackQueryByID(queryID uint) (*model.HumanFeedbackQuery, error)
@@ -79,9 +93,20 @@ type RunRepository interface {
 	FindHumanFeedbackQueryStatusByID(queryStatusID uint) (*model.QueryStatus, error)
 	Create(run *model.Run) error
 	CreateRunStepStatus(runStepStatus *model.RunStepStatus) error
+	CreateRunStepAttempt(runStepAttempt *model.RunStepAttempt) error
+	CreateRunArtifact(runArtifact *model.RunArtifact) error
+	CreateRunMetric(runMetric *model.RunMetric) error
+	FindRunMetricsByRun(runID uint) ([]model.RunMetric, error)
+	CreateRunTestResult(runTestResult *model.RunTestResult) error
+	FindRunTestResultsByRun(runID uint) ([]model.RunTestResult, error)
+	FindRunTestResultsByPipeline(pipelineID uint) ([]model.RunTestResult, error)
+	FindStepCacheEntryByKey(key string, userID uint) (*model.StepCacheEntry, error)
+	SaveStepCacheEntry(stepCacheEntry *model.StepCacheEntry) error
 	CreateHumanFeedbackQuery(humanFeedbackQuery *model.HumanFeedbackQuery) error
 	CreateHumanFeedbackRect(humanFeedbackRect *model.HumanFeedbackRect) error
 	Update(run *model.Run) error
+	UpdateStatusIn(run *model.Run, runStatusIDs []uint) (bool, error)
+	UpdateDefinition(runID uint, definition string) error
 	UpdateRunStepStatus(runStepStatus *model.RunStepStatus) error
 	UpdateHumanFeedbackQuery(query *model.HumanFeedbackQuery) error
 	UpdateHumanFeedbackRect(rect *model.HumanFeedbackRect) error
@@ -89,5 +114,11 @@ type RunRepository interface {
 	DeleteRunStepStatus(runID uint) error
 	DeleteAllHumanFeedbackQueriesByRunID(runID uint) error
 	DeleteAllRunStepStatuses(runID uint) error
+	DeleteRunArtifactsByStep(runID uint, stepID int) error
+	DeleteAllRunArtifacts(runID uint) error
+	DeleteRunMetricsByStep(runID uint, stepID int) error
+	DeleteAllRunMetrics(runID uint) error
+	DeleteRunTestResultsByStep(runID uint, stepID int) error
+	DeleteAllRunTestResults(runID uint) error
 	GetRunStatusByID(runID uint) (*model.RunStatus, error)
 }
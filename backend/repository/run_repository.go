@@ -35,7 +35,32 @@ func (repo *runRepositoryImpl) FindByPipeline(pipelineId uint) ([]model.Run, err
 
 	var runs []model.Run
 
-	result := repo.DB.Preload("Pipeline").Preload("RunStatus").Where("pipeline_id = ?", pipelineId).Order("id desc").Find(&runs)
+	// The child runs of a sweep are listed under their parent run. Runs created before sweeps have no parent run id.
+	result := repo.DB.Preload("Pipeline").Preload("RunStatus").Where("pipeline_id = ? AND (parent_run_id = 0 OR parent_run_id IS NULL)", pipelineId).Order("id desc").Find(&runs)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+
+	return runs, nil
+}
+
+func (repo *runRepositoryImpl) FindByParent(parentRunID uint) ([]model.Run, error) {
+	var runs []model.Run
+
+	result := repo.DB.Preload("RunStatus").Where("parent_run_id = ?", parentRunID).Order("id").Find(&runs)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+
+	return runs, nil
+}
+
+func (repo *runRepositoryImpl) FindByStatus(runStatusID uint) ([]model.Run, error) {
+	var runs []model.Run
+
+	result := repo.DB.Where("run_status_id = ?", runStatusID).Order("id").Find(&runs)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, result.Error
@@ -47,7 +72,7 @@ func (repo *runRepositoryImpl) FindByPipeline(pipelineId uint) ([]model.Run, err
 func (repo *runRepositoryImpl) FindRunStepStatusesByRun(runID uint) ([]model.RunStepStatus, error) {
 	var runStepStatuses []model.RunStepStatus
 
-	result := repo.DB.Preload("Run").Preload("RunStatus").Where("run_id = ?", runID).Find(&runStepStatuses)
+	result := repo.DB.Preload("Run").Preload("RunStatus").Preload("Attempts").Where("run_id = ?", runID).Order("id").Find(&runStepStatuses)
 
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, result.Error
@@ -56,6 +81,18 @@ func (repo *runRepositoryImpl) FindRunStepStatusesByRun(runID uint) ([]model.Run
 	return runStepStatuses, nil
 }
 
+func (repo *runRepositoryImpl) FindRunArtifactsByRun(runID uint) ([]model.RunArtifact, error) {
+	var runArtifacts []model.RunArtifact
+
+	result := repo.DB.Where("run_id = ?", runID).Order("step_id, path").Find(&runArtifacts)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runArtifacts, nil
+}
+
 func (repo *runRepositoryImpl) FindHumanFeedbackQueriesByStepID(runID uint, stepID uint) ([]model.HumanFeedbackQuery, error) {
 	var humanFeedbackQueries []model.HumanFeedbackQuery
 
@@ -136,6 +173,109 @@ func (repo *runRepositoryImpl) CreateRunStepStatus(runStepStatus *model.RunStepS
 	return nil
 }
 
+func (repo *runRepositoryImpl) CreateRunStepAttempt(runStepAttempt *model.RunStepAttempt) error {
+	result := repo.DB.Create(runStepAttempt)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) CreateRunArtifact(runArtifact *model.RunArtifact) error {
+	result := repo.DB.Create(runArtifact)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) CreateRunMetric(runMetric *model.RunMetric) error {
+	result := repo.DB.Create(runMetric)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) FindRunMetricsByRun(runID uint) ([]model.RunMetric, error) {
+	var runMetrics []model.RunMetric
+
+	result := repo.DB.Where("run_id = ?", runID).Order("step_id, name, id").Find(&runMetrics)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runMetrics, nil
+}
+
+func (repo *runRepositoryImpl) CreateRunTestResult(runTestResult *model.RunTestResult) error {
+	result := repo.DB.Create(runTestResult)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) FindRunTestResultsByRun(runID uint) ([]model.RunTestResult, error) {
+	var runTestResults []model.RunTestResult
+
+	result := repo.DB.Where("run_id = ?", runID).Order("step_id, id").Find(&runTestResults)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runTestResults, nil
+}
+
+// FindRunTestResultsByPipeline returns the test results of the runs of the pipeline, oldest first, leaving out their
+// predictions.
+func (repo *runRepositoryImpl) FindRunTestResultsByPipeline(pipelineID uint) ([]model.RunTestResult, error) {
+	var runTestResults []model.RunTestResult
+
+	result := repo.DB.Omit("predictions").Joins("join runs on runs.id = run_test_results.run_id and runs.deleted_at is null").Where("runs.pipeline_id = ?", pipelineID).Order("run_test_results.run_id, run_test_results.step_id, run_test_results.id").Find(&runTestResults)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runTestResults, nil
+}
+
+func (repo *runRepositoryImpl) FindStepCacheEntryByKey(key string, userID uint) (*model.StepCacheEntry, error) {
+	var stepCacheEntry model.StepCacheEntry
+
+	result := repo.DB.Where("key = ? AND user_id = ?", key, userID).First(&stepCacheEntry)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &stepCacheEntry, nil
+}
+
+func (repo *runRepositoryImpl) SaveStepCacheEntry(stepCacheEntry *model.StepCacheEntry) error {
+	result := repo.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "user_id", "run_id", "step_id", "output_hash"}),
+	}).Create(stepCacheEntry)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
 func (repo *runRepositoryImpl) CreateHumanFeedbackQuery(humanFeedbackQuery *model.HumanFeedbackQuery) error {
 	result := repo.DB.Create(humanFeedbackQuery)
 
@@ -166,6 +306,24 @@ func (repo *runRepositoryImpl) Update(run *model.Run) error {
 	return nil
 }
 
+// UpdateStatusIn updates the status of the run only while its status in the database is one of runStatusIDs, so that
+// a status set meanwhile by someone else is not overwritten, and tells whether it did.
+func (repo *runRepositoryImpl) UpdateStatusIn(run *model.Run, runStatusIDs []uint) (bool, error) {
+	result := repo.DB.Model(run).
+		Where("run_status_id IN ?", runStatusIDs).
+		Select("RunStatusID", "ErrorMessage", "StepsWaitingFeedback", "LastRun", "StartedAt", "ResumedAt", "FinishedAt", "DurationMs").
+		Updates(run)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateDefinition replaces only the definition of the run.
+func (repo *runRepositoryImpl) UpdateDefinition(runID uint, definition string) error {
+	result := repo.DB.Model(&model.Run{}).Where("id = ?", runID).Update("definition", definition)
+
+	return result.Error
+}
+
 func (repo *runRepositoryImpl) UpdateRunStepStatus(runStepStatus *model.RunStepStatus) error {
 	result := repo.DB.Save(runStepStatus)
 
@@ -226,6 +384,66 @@ func (repo *runRepositoryImpl) DeleteAllRunStepStatuses(runID uint) error {
 	return nil
 }
 
+func (repo *runRepositoryImpl) DeleteRunArtifactsByStep(runID uint, stepID int) error {
+	result := repo.DB.Where("run_id = ? and step_id = ?", runID, stepID).Delete(&model.RunArtifact{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) DeleteAllRunArtifacts(runID uint) error {
+	result := repo.DB.Where("run_id = ?", runID).Delete(&model.RunArtifact{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) DeleteRunMetricsByStep(runID uint, stepID int) error {
+	result := repo.DB.Where("run_id = ? and step_id = ?", runID, stepID).Delete(&model.RunMetric{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) DeleteAllRunMetrics(runID uint) error {
+	result := repo.DB.Where("run_id = ?", runID).Delete(&model.RunMetric{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) DeleteRunTestResultsByStep(runID uint, stepID int) error {
+	result := repo.DB.Where("run_id = ? and step_id = ?", runID, stepID).Delete(&model.RunTestResult{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *runRepositoryImpl) DeleteAllRunTestResults(runID uint) error {
+	result := repo.DB.Where("run_id = ?", runID).Delete(&model.RunTestResult{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
 func (repo *runRepositoryImpl) DeleteAllHumanFeedbackQueriesByRunID(runID uint) error {
 
 	runStepStatuses, err := repo.FindRunStepStatusesByRun(runID)
@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type trainedRepositoryImpl struct {
@@ -73,3 +74,203 @@ func (repo *trainedRepositoryImpl) Delete(id uint) error {
 
 	return nil
 }
+
+func (repo *trainedRepositoryImpl) FindRegisteredModelByID(id uint) (*model.RegisteredModel, error) {
+	var registeredModel = model.RegisteredModel{}
+
+	result := repo.DB.First(&registeredModel, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &registeredModel, nil
+}
+
+func (repo *trainedRepositoryImpl) FindRegisteredModelByName(ownerID uint, name string) (*model.RegisteredModel, error) {
+	var registeredModel = model.RegisteredModel{}
+
+	result := repo.DB.Where("user_id = ? and name = ?", ownerID, name).First(&registeredModel)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &registeredModel, nil
+}
+
+func (repo *trainedRepositoryImpl) FindRegisteredModelsByOwner(ownerID uint) ([]model.RegisteredModel, error) {
+	var registeredModels []model.RegisteredModel
+
+	result := repo.DB.Where("user_id = ?", ownerID).Order("name").Find(&registeredModels)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return registeredModels, nil
+}
+
+func (repo *trainedRepositoryImpl) CreateRegisteredModel(registeredModel *model.RegisteredModel) error {
+	result := repo.DB.Create(registeredModel)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *trainedRepositoryImpl) FindVersions(registeredModelID uint) ([]model.Trained, error) {
+	var versions []model.Trained
+
+	result := repo.DB.Where("registered_model_id = ?", registeredModelID).Order("version desc").Find(&versions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return versions, nil
+}
+
+func (repo *trainedRepositoryImpl) FindVersionsByRun(registeredModelID uint, runID uint) ([]model.Trained, error) {
+	var versions []model.Trained
+
+	result := repo.DB.Where("registered_model_id = ? and run_id = ?", registeredModelID, runID).Order("version").Find(&versions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return versions, nil
+}
+
+// CreateVersion creates the trained model as the next version of its registered model. The registered model row is
+// locked meanwhile, so concurrent runs never number two versions alike.
+func (repo *trainedRepositoryImpl) CreateVersion(trained *model.Trained) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		var registeredModel model.RegisteredModel
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registeredModel, trained.RegisteredModelID); result.Error != nil {
+			return result.Error
+		}
+
+		var lastVersion uint
+
+		if result := tx.Model(&model.Trained{}).Where("registered_model_id = ?", trained.RegisteredModelID).Select("coalesce(max(version), 0)").Scan(&lastVersion); result.Error != nil {
+			return result.Error
+		}
+
+		trained.Version = lastVersion + 1
+
+		return tx.Create(trained).Error
+	})
+}
+
+func (repo *trainedRepositoryImpl) UpdateRegisteredModel(registeredModel *model.RegisteredModel) error {
+	return repo.DB.Save(registeredModel).Error
+}
+
+func (repo *trainedRepositoryImpl) FindVersionsByStage(registeredModelID uint, stage string) ([]model.Trained, error) {
+	var versions []model.Trained
+
+	result := repo.DB.Where("registered_model_id = ? and stage = ?", registeredModelID, stage).Order("version desc").Find(&versions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return versions, nil
+}
+
+func (repo *trainedRepositoryImpl) FindStageTransitions(registeredModelID uint) ([]model.ModelStageTransition, error) {
+	var transitions []model.ModelStageTransition
+
+	result := repo.DB.Where("registered_model_id = ?", registeredModelID).Order("id desc").Find(&transitions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return transitions, nil
+}
+
+// TransitionStage moves the version to the stage of the transition and records it. A version promoted to production
+// archives the version that was in production, recording that transition too, all of it while the registered model
+// row is locked so two promotions never leave two versions in production. The recorded transitions are returned.
+func (repo *trainedRepositoryImpl) TransitionStage(trained *model.Trained, transition *model.ModelStageTransition) ([]model.ModelStageTransition, error) {
+	var transitions []model.ModelStageTransition
+
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var registeredModel model.RegisteredModel
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&registeredModel, trained.RegisteredModelID); result.Error != nil {
+			return result.Error
+		}
+
+		var current model.Trained
+
+		if result := tx.First(&current, trained.ID); result.Error != nil {
+			return result.Error
+		}
+
+		var productionVersions []model.Trained
+
+		if transition.ToStage == model.TrainedStageProduction {
+			if result := tx.Where("registered_model_id = ? and stage = ? and id <> ?", trained.RegisteredModelID, model.TrainedStageProduction, trained.ID).Find(&productionVersions); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		// The transitions are built before the updates, which write the new stage back into the versions
+		transitions = stageTransitions(current, productionVersions, *transition)
+
+		for _, productionVersion := range productionVersions {
+			if result := tx.Model(&productionVersion).Update("stage", model.TrainedStageArchived); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if result := tx.Model(&current).Update("stage", transition.ToStage); result.Error != nil {
+			return result.Error
+		}
+
+		for i := range transitions {
+			if result := tx.Create(&transitions[i]); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		*transition = transitions[len(transitions)-1]
+		trained.Stage = transition.ToStage
+
+		return nil
+	})
+
+	return transitions, err
+}
+
+// stageTransitions returns the transitions recorded when the version moves from its current stage to the stage of
+// the transition: the archiving of the production versions it replaces, then its own.
+func stageTransitions(current model.Trained, productionVersions []model.Trained, transition model.ModelStageTransition) []model.ModelStageTransition {
+	var transitions []model.ModelStageTransition
+
+	for _, productionVersion := range productionVersions {
+		transitions = append(transitions, model.ModelStageTransition{
+			TrainedID:         productionVersion.ID,
+			RegisteredModelID: productionVersion.RegisteredModelID,
+			Version:           productionVersion.Version,
+			UserID:            transition.UserID,
+			FromStage:         productionVersion.Stage,
+			ToStage:           model.TrainedStageArchived,
+			Comment:           transition.Comment,
+		})
+	}
+
+	transition.TrainedID = current.ID
+	transition.RegisteredModelID = current.RegisteredModelID
+	transition.Version = current.Version
+	transition.FromStage = current.Stage
+
+	return append(transitions, transition)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"di/model"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestStageTransitions(t *testing.T) {
+	current := model.Trained{Model: gorm.Model{ID: 3}, RegisteredModelID: 1, Version: 3, Stage: model.TrainedStageStaging}
+	productionVersions := []model.Trained{
+		{Model: gorm.Model{ID: 2}, RegisteredModelID: 1, Version: 2, Stage: model.TrainedStageProduction},
+	}
+	transition := model.ModelStageTransition{UserID: 7, ToStage: model.TrainedStageProduction, Comment: "promote"}
+
+	got := stageTransitions(current, productionVersions, transition)
+	want := []model.ModelStageTransition{
+		{TrainedID: 2, RegisteredModelID: 1, Version: 2, UserID: 7, FromStage: model.TrainedStageProduction, ToStage: model.TrainedStageArchived, Comment: "promote"},
+		{TrainedID: 3, RegisteredModelID: 1, Version: 3, UserID: 7, FromStage: model.TrainedStageStaging, ToStage: model.TrainedStageProduction, Comment: "promote"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stageTransitions() = %+v, want %+v", got, want)
+	}
+
+	transition = model.ModelStageTransition{UserID: 7, ToStage: model.TrainedStageArchived}
+	got = stageTransitions(current, nil, transition)
+
+	if len(got) != 1 || got[0].FromStage != model.TrainedStageStaging || got[0].ToStage != model.TrainedStageArchived {
+		t.Errorf("stageTransitions() = %+v, want one transition from %s to %s", got, model.TrainedStageStaging, model.TrainedStageArchived)
+	}
+}
@@ -1,10 +1,21 @@
 import numpy as np
 import argparse
 import csv
+import json
 from pathlib import Path
 
 DEFAULT_EPSILON = 0.1
 
+CLASSIFIER_MODELS = {
+    'ridgeClassifier',
+    'ridgeClassifierCV',
+    'logisticRegression',
+    'logisticRegressionCV',
+    'sgdClassifier',
+    'perceptron',
+    'passiveAgressiveClassifier',
+}
+
 # Ordinary Least Squares
 def least_squares(
         X_train, 
@@ -2178,6 +2189,22 @@ def call_liner_model(args, X_train, y_train, X_test):
 
     return model
 
+# Scores the predictions on the testing set, the accuracy for classifiers and the coefficient of determination otherwise
+def write_score(args, y_pred):
+    from sklearn.metrics import accuracy_score, r2_score
+
+    y_test = np.load(args.testing_target_path, allow_pickle=True)
+
+    if args.model in CLASSIFIER_MODELS:
+        metric, score = 'accuracy', accuracy_score(y_test, y_pred)
+    else:
+        metric, score = 'r2', r2_score(y_test, y_pred)
+
+    with open(args.score_path, 'w') as score_file:
+        json.dump({'metric': metric, 'score': float(score)}, score_file)
+
+    print('##metric ' + json.dumps({'name': metric, 'value': float(score)}), flush=True)
+
 def main():
     parser = argparse.ArgumentParser()
     parser.add_argument("--fit_intercept", action=argparse.BooleanOptionalAction)
@@ -2247,6 +2274,8 @@ def main():
     parser.add_argument("--train_data_path", type=Path, required=True)
     parser.add_argument("--train_target_path", type=Path, required=True)
     parser.add_argument("--testing_data_path", type=Path, required=True)
+    parser.add_argument("--testing_target_path", type=Path, required=False)
+    parser.add_argument("--score_path", type=Path, required=False)
     args = parser.parse_args()
 
     X_train = np.load(args.train_data_path, allow_pickle=True)
@@ -2257,5 +2286,8 @@ def main():
 
     print(model)
 
+    if args.testing_target_path and args.score_path:
+        write_score(args, model[1])
+
 if __name__ == "__main__":
     main()
\ No newline at end of file
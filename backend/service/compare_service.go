@@ -0,0 +1,254 @@
+package service
+
+import (
+	"di/model"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Compare returns the difference between runs of a pipeline: their parameters, metrics, the configs of their steps in
+// the definitions the runs were executed with, and the status, duration and artifacts of every step.
+func (service *runServiceImpl) Compare(runs []model.Run) (model.RunComparison, error) {
+	comparison := model.RunComparison{
+		Runs:       []model.RunComparisonRun{},
+		Parameters: []model.ValueComparison{},
+		Metrics:    []model.ValueComparison{},
+		Steps:      []model.StepComparison{},
+	}
+
+	if len(runs) > 0 {
+		comparison.PipelineID = runs[0].PipelineID
+	}
+
+	parameters := make([]map[string]interface{}, len(runs))
+	metrics := make([]map[string]interface{}, len(runs))
+	stepNodes := make([]map[string]map[string]interface{}, len(runs))
+	stepStatuses := make([]map[string]model.RunStepStatus, len(runs))
+	// The artifacts of a step are keyed by path, as a directory output records one artifact per file under its name
+	stepArtifacts := make([]map[string]map[string]model.RunArtifact, len(runs))
+	var stepIDs []string
+	stepNames := make(map[string]string)
+
+	addStep := func(stepID string, name string) {
+		if _, ok := stepNames[stepID]; !ok {
+			stepIDs = append(stepIDs, stepID)
+			stepNames[stepID] = name
+		} else if stepNames[stepID] == "" {
+			stepNames[stepID] = name
+		}
+	}
+
+	for i, run := range runs {
+		comparison.Runs = append(comparison.Runs, model.RunComparisonRun{
+			RunID:        run.ID,
+			StatusID:     run.RunStatusID,
+			ErrorMessage: run.ErrorMessage,
+			StartedAt:    run.StartedAt,
+			DurationMs:   run.DurationMs,
+		})
+
+		parameters[i] = make(map[string]interface{})
+
+		if run.Parameters != "" {
+			json.Unmarshal([]byte(run.Parameters), &parameters[i])
+		}
+
+		metrics[i] = make(map[string]interface{})
+
+		if run.SweepScore.Valid {
+			metrics[i]["score"] = run.SweepScore.Float64
+		}
+
+		runMetricSeries, err := service.FindRunMetricSeries(run.ID)
+
+		if err != nil {
+			return comparison, err
+		}
+
+		// Metrics are compared by the last value the step reported
+		for _, series := range runMetricSeries {
+			metrics[i][series.StepName+"/"+series.Name] = series.Last
+		}
+
+		stepNodes[i] = make(map[string]map[string]interface{})
+		var nodeDescriptions []map[string]interface{}
+
+		if err := json.Unmarshal([]byte(run.Definition), &nodeDescriptions); err == nil {
+			for _, nodeDescription := range nodeDescriptions {
+				data, _ := nodeDescription["data"].(map[string]interface{})
+
+				if data == nil || data["id"] == nil {
+					continue
+				}
+
+				stepID := fmt.Sprint(data["id"])
+				stepNodes[i][stepID] = data
+				nameAndType, _ := data["nameAndType"].(map[string]interface{})
+				name, _ := nameAndType["name"].(string)
+				addStep(stepID, name)
+			}
+		}
+
+		runStepStatuses, err := service.FindRunStepStatusesByRun(run.ID)
+
+		if err != nil {
+			return comparison, err
+		}
+
+		stepStatuses[i] = make(map[string]model.RunStepStatus)
+
+		for _, runStepStatus := range runStepStatuses {
+			stepID := fmt.Sprint(runStepStatus.StepID)
+			stepStatuses[i][stepID] = runStepStatus
+			addStep(stepID, runStepStatus.Name)
+		}
+
+		runArtifacts, err := service.FindRunArtifactsByRun(run.ID)
+
+		if err != nil {
+			return comparison, err
+		}
+
+		stepArtifacts[i] = make(map[string]map[string]model.RunArtifact)
+
+		for _, runArtifact := range runArtifacts {
+			stepID := fmt.Sprint(runArtifact.StepID)
+
+			if stepArtifacts[i][stepID] == nil {
+				stepArtifacts[i][stepID] = make(map[string]model.RunArtifact)
+			}
+
+			stepArtifacts[i][stepID][runArtifact.Path] = runArtifact
+			addStep(stepID, runArtifact.StepName)
+		}
+	}
+
+	comparison.Parameters = compareValues(parameters, false)
+	comparison.Metrics = compareValues(metrics, false)
+
+	sort.SliceStable(stepIDs, func(i, j int) bool {
+		first, firstErr := strconv.Atoi(stepIDs[i])
+		second, secondErr := strconv.Atoi(stepIDs[j])
+
+		if firstErr != nil || secondErr != nil {
+			return stepIDs[i] < stepIDs[j]
+		}
+
+		return first < second
+	})
+
+	for _, stepID := range stepIDs {
+		stepComparison := model.StepComparison{
+			StepID:    stepID,
+			Name:      stepNames[stepID],
+			Runs:      []model.StepComparisonRun{},
+			Artifacts: []model.ArtifactComparison{},
+		}
+
+		configs := make([]map[string]interface{}, len(runs))
+		var artifactPaths []string
+		artifactSeen := make(map[string]bool)
+
+		for i, run := range runs {
+			data, inPipeline := stepNodes[i][stepID]
+			configs[i], _ = data["stepConfig"].(map[string]interface{})
+			runStepStatus := stepStatuses[i][stepID]
+
+			stepComparison.Runs = append(stepComparison.Runs, model.StepComparisonRun{
+				RunID:      run.ID,
+				InPipeline: inPipeline,
+				StatusID:   runStepStatus.RunStatusID,
+				DurationMs: runStepStatus.DurationMs,
+				OutputHash: runStepStatus.OutputHash,
+			})
+
+			if i > 0 && (inPipeline != stepComparison.Runs[0].InPipeline || runStepStatus.RunStatusID != stepComparison.Runs[0].StatusID) {
+				stepComparison.Changed = true
+			}
+
+			for path := range stepArtifacts[i][stepID] {
+				if !artifactSeen[path] {
+					artifactSeen[path] = true
+					artifactPaths = append(artifactPaths, path)
+				}
+			}
+		}
+
+		stepComparison.Config = compareValues(configs, true)
+
+		if len(stepComparison.Config) > 0 {
+			stepComparison.Changed = true
+		}
+
+		sort.Strings(artifactPaths)
+
+		for _, path := range artifactPaths {
+			artifactComparison := model.ArtifactComparison{Path: path}
+
+			for i := range runs {
+				runArtifact, ok := stepArtifacts[i][stepID][path]
+
+				if ok {
+					artifactComparison.Name = runArtifact.Name
+				}
+
+				artifactComparison.Checksums = append(artifactComparison.Checksums, runArtifact.Checksum)
+				artifactComparison.Sizes = append(artifactComparison.Sizes, runArtifact.Size)
+
+				if runArtifact.Checksum != artifactComparison.Checksums[0] {
+					artifactComparison.Changed = true
+				}
+			}
+
+			if artifactComparison.Changed {
+				stepComparison.Changed = true
+			}
+
+			stepComparison.Artifacts = append(stepComparison.Artifacts, artifactComparison)
+		}
+
+		comparison.Steps = append(comparison.Steps, stepComparison)
+	}
+
+	return comparison, nil
+}
+
+// compareValues lines up the values of every name over the maps, one per run, with nil where a map lacks the name.
+// With onlyChanged set the names whose values are equal in every map are left out.
+func compareValues(valuesByRun []map[string]interface{}, onlyChanged bool) []model.ValueComparison {
+	comparisons := []model.ValueComparison{}
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, values := range valuesByRun {
+		for name := range values {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		comparison := model.ValueComparison{Name: name}
+
+		for i, values := range valuesByRun {
+			comparison.Values = append(comparison.Values, values[name])
+
+			if i > 0 && !reflect.DeepEqual(values[name], comparison.Values[0]) {
+				comparison.Changed = true
+			}
+		}
+
+		if comparison.Changed || !onlyChanged {
+			comparisons = append(comparisons, comparison)
+		}
+	}
+
+	return comparisons
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"di/model"
+	"reflect"
+	"testing"
+)
+
+func TestCompareArtifacts(t *testing.T) {
+	runRepository := &fakeRunRepository{
+		runArtifacts: []model.RunArtifact{
+			{RunID: 1, StepID: 2, Name: "model", Path: "model/weights.pt", Checksum: "a"},
+			{RunID: 1, StepID: 2, Name: "model", Path: "model/config.json", Checksum: "b"},
+			{RunID: 2, StepID: 2, Name: "model", Path: "model/weights.pt", Checksum: "c"},
+			{RunID: 2, StepID: 2, Name: "model", Path: "model/config.json", Checksum: "b"},
+		},
+	}
+	service := &runServiceImpl{I18n: newTestLocalizer(t), RunRepository: runRepository}
+
+	runs := []model.Run{{Definition: "[]"}, {Definition: "[]"}}
+	runs[0].ID = 1
+	runs[1].ID = 2
+
+	comparison, err := service.Compare(runs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comparison.Steps) != 1 {
+		t.Fatalf("got %d steps, want 1", len(comparison.Steps))
+	}
+
+	// Every file of the directory output is compared, not only one of those sharing its name
+	want := []model.ArtifactComparison{
+		{Name: "model", Path: "model/config.json", Checksums: []string{"b", "b"}, Sizes: []int64{0, 0}},
+		{Name: "model", Path: "model/weights.pt", Checksums: []string{"a", "c"}, Sizes: []int64{0, 0}, Changed: true},
+	}
+
+	if got := comparison.Steps[0].Artifacts; !reflect.DeepEqual(got, want) {
+		t.Errorf("got artifacts %+v, want %+v", got, want)
+	}
+
+	if !comparison.Steps[0].Changed {
+		t.Error("got the step unchanged, want it changed by its artifact")
+	}
+}
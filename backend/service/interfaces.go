@@ -18,6 +18,7 @@ type Services struct {
 	TrainedService  TrainedModelService
 	RunService      RunService
 	TokenService    TokenService
+	StepService     StepService
 }
 
 type UserService interface {
@@ -34,6 +35,8 @@ type TokenService interface {
 	Signout(ctx context.Context, uid uint) error
 	ValidateIDToken(tokenString string) (*model.User, error)
 	ValidateRefreshToken(refreshTokenString string) (*model.RefreshToken, error)
+	NewRunEventsToken(u *model.User, runID uint) (string, error)
+	ValidateRunEventsToken(tokenString string, runID uint) (*model.User, error)
 }
 
 type PipelineService interface {
@@ -42,7 +45,7 @@ type PipelineService interface {
 	GetPipelineSchedule(id uint) (*model.PipelineSchedule, error)
 	GetPipelineSchedules(id uint) ([]model.PipelineSchedule, error)
 	GetByOwner(ownerId uint) ([]model.Pipeline, error)
-	Create(userId uint, name string, definition string) error
+	Create(userId uint, name string, definition string, parameters string, queue string) error
 	CreatePipelineSchedule(pipelineID uint, uniqueOcurrence time.Time, cronExpression string) error
 	Update(pipeline *model.Pipeline) error
 	Delete(id uint) error
@@ -82,6 +85,15 @@ type TrainedModelService interface {
 	GetByOwner(ownerId uint) ([]model.Trained, error)
 	Create(userId uint, name string) (*model.Trained, error)
 	Update(trained *model.Trained) error
+	RegisterVersion(userId uint, name string, version *model.Trained) (*model.Trained, bool, error)
+	GetRegisteredModels(ownerId uint) ([]model.RegisteredModel, error)
+	GetRegisteredModel(id uint) (*model.RegisteredModel, error)
+	GetVersions(registeredModelId uint) ([]model.Trained, error)
+	GetLatestInStage(ownerId uint, name string, stage string) (*model.Trained, error)
+	UnmetThresholds(trained *model.Trained) ([]string, error)
+	SetThresholds(registeredModel *model.RegisteredModel, thresholds []model.MetricThreshold) error
+	TransitionStage(trained *model.Trained, stage string, userId uint, comment string) ([]model.ModelStageTransition, error)
+	GetStageTransitions(registeredModelId uint) ([]model.ModelStageTransition, error)
 	Delete(id uint) error
 }
 
@@ -89,16 +101,29 @@ type RunService interface {
 	Get(id uint) (*model.Run, error)
 	GetByPipeline(pipelineId uint) ([]model.Run, error)
 	FindRunStepStatusesByRun(runID uint) ([]model.RunStepStatus, error)
+	FindRunArtifactsByRun(runID uint) ([]model.RunArtifact, error)
+	FindRunMetricSeries(runID uint) ([]model.RunMetricSeries, error)
+	FindRunTestResults(runID uint, withPredictions bool) ([]model.StepTestResults, error)
+	FindPipelineTestResults(pipelineID uint) ([]model.StepTestResults, error)
 	FindHumanFeedbackQueriesByRunID(runID uint) ([]model.HumanFeedbackQuery, error)
 	FindHumanFeedbackQueryByID(queryID uint) (*model.HumanFeedbackQuery, error)
 	FindHumanFeedbackQueriesByStepID(runID uint, runStepStatusID uint) ([]model.HumanFeedbackQuery, error)
 	FindHumanFeedbackRectsByHumanFeedbackQueryID(humanFeedbackQueryID uint) ([]model.HumanFeedbackRect, error)
 	FindHumanFeedbackQueryStatusByID(queryStatusID uint) (*model.QueryStatus, error)
-	Create(pipeline model.Pipeline) (model.Run, error)
+	Create(pipeline model.Pipeline, runReq model.CreateRunReq) (model.Run, error)
 	CreateRunStepStatus(runID uint, stepID int, stepName string, runStatusID uint, errorMessage string) error
 	CreateHumanFeedbackQuery(epoch uint, runID uint, stepID int, queryID uint, rects [][]uint) error
 	Execute(runID uint) error
 	Resume(runID uint) error
+	Cancel(runID uint) error
+	Rerun(runID uint, stepID int) error
+	Sweep(pipeline model.Pipeline, sweepReq model.SweepReq) (model.Run, error)
+	ReconcileOrphanedRuns()
+	SubscribeRunEvents(ctx context.Context, runID uint) (<-chan model.RunEvent, error)
+	StartRunReconciler()
+	FindSweepTrials(runID uint) ([]model.Run, error)
+	Timeline(runID uint) (model.RunTimeline, error)
+	Compare(runs []model.Run) (model.RunComparison, error)
 	Update(run *model.Run) error
 	UpdateRunStepStatus(run *model.RunStepStatus) error
 	UpdateHumanFeedbackQuery(query *model.HumanFeedbackQuery) error
@@ -109,7 +134,7 @@ type RunService interface {
 	NewResumeRunPipelineTask(pipelineID uint, runID uint, graph string, stepID int) (*asynq.Task, error)
 	HandleRunPipelineTask(ctx context.Context, t *asynq.Task) error
 	HandleScheduledRunPipelineTask(ctx context.Context, t *asynq.Task) error
-	UpdateRunStatus(runID uint, statusID uint, stepWaitingFeedback int, errorMessage string) error
+	UpdateRunStatus(runID uint, statusID uint, stepsWaitingFeedback []int, errorMessage string) error
 }
 
 type RunStepStatusService interface {
@@ -124,6 +149,8 @@ type RunStepStatusService interface {
 type StepService interface {
 	NewStepInstance(pipelineID uint, runID uint, nodeType string, nodeDescription model.NodeDescription) (*steps.Step, error)
 	NewEdgeInstance(pipelineID uint, runID uint, edgeType string, nodeDescription model.NodeDescription) (*steps.Edge, error)
+	IsEdgeType(nodeType string) bool
+	ValidatePipeline(definition string, parameters string, userID uint) []model.PipelineDiagnostic
 }
 
 type TaskService interface {
@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hibiken/asynq"
@@ -44,7 +47,12 @@ func (service *pipelineServiceImpl) SyncAsyncTasks() {
 	}
 
 	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort})
-	scheduledTasks, _ := inspector.ListScheduledTasks("runs")
+	var scheduledTasks []*asynq.TaskInfo
+
+	for _, queue := range append(RunQueues, legacyRunsQueue) {
+		queueScheduledTasks, _ := inspector.ListScheduledTasks(queue)
+		scheduledTasks = append(scheduledTasks, queueScheduledTasks...)
+	}
 	pipelineSchedules, err := service.GetAllPipelineSchedules()
 
 	if err != nil {
@@ -188,8 +196,8 @@ func (service *pipelineServiceImpl) GetByOwner(ownerId uint) ([]model.Pipeline,
 	return pipelines, err
 }
 
-func (service *pipelineServiceImpl) Create(userId uint, name string, definition string) error {
-	if err := service.PipelineRepository.Create(&model.Pipeline{UserID: userId, Name: name, Definition: definition}); err != nil {
+func (service *pipelineServiceImpl) Create(userId uint, name string, definition string, parameters string, queue string) error {
+	if err := service.PipelineRepository.Create(&model.Pipeline{UserID: userId, Name: name, Definition: definition, Parameters: parameters, Queue: queue}); err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "pipeline.repository.create.pipeline.failed",
 			TemplateData: map[string]interface{}{
@@ -206,6 +214,16 @@ func (service *pipelineServiceImpl) Create(userId uint, name string, definition
 
 func (service *pipelineServiceImpl) CreatePipelineSchedule(pipelineID uint, uniqueOcurrence time.Time, cronExpression string) error {
 	if cronExpression != "" || uniqueOcurrence.Year() > 1 {
+		pipeline, err := service.Get(pipelineID)
+
+		if err != nil {
+			return err
+		}
+
+		// A scheduled run is triggered without parameter values, so every parameter of the pipeline needs a default
+		if _, err := resolvePipelineParameters(service.I18n, pipeline.Parameters, nil, true); err != nil {
+			return err
+		}
 
 		pipelineSchedule := &model.PipelineSchedule{PipelineID: pipelineID, UniqueOcurrence: uniqueOcurrence, CronExpression: cronExpression}
 		if err := service.PipelineRepository.CreatePipelineSchedule(pipelineSchedule); err != nil {
@@ -220,7 +238,7 @@ func (service *pipelineServiceImpl) CreatePipelineSchedule(pipelineID uint, uniq
 			return errors.New(errMessage)
 		}
 
-		err := service.enqueueTask(uniqueOcurrence, cronExpression, pipelineID, pipelineSchedule.ID)
+		err = service.enqueueTask(uniqueOcurrence, cronExpression, pipelineID, pipelineSchedule.ID)
 		if err != nil {
 			return err
 		}
@@ -249,7 +267,13 @@ func (service *pipelineServiceImpl) enqueueTask(uniqueOcurrence time.Time, cronE
 		return err
 	}
 
-	service.TaskQueueClient.Enqueue(task, asynq.Queue("runs"), asynq.Timeout(0), asynq.ProcessAt(nextExec))
+	queue := ScheduledQueue
+
+	if pipeline, err := service.PipelineRepository.FindByID(pipelineID); err == nil {
+		queue = runQueue(*pipeline, ScheduledQueue)
+	}
+
+	service.TaskQueueClient.Enqueue(task, asynq.Queue(queue), asynq.Timeout(runTaskTimeout), asynq.ProcessAt(nextExec))
 	return nil
 }
 
@@ -318,3 +342,207 @@ func NewScheduledRunPipelineTask(pipelineID uint, pipelineScheduleID uint) (*asy
 
 	return asynq.NewTask(ScheduledRunPipelineTask, payload, asynq.MaxRetry(0)), nil
 }
+
+// parameterPlaceholder matches the ${params.name} placeholders that can be used in step configs.
+var parameterPlaceholder = regexp.MustCompile(`\$\{params\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolvePipelineParameters returns the typed value of every parameter the pipeline declares, taken from values
+// when set there and from the parameter default otherwise. A parameter with neither is only an error when
+// requireValues is set, as when a run is triggered; otherwise it resolves to the zero value of its type, so that
+// the definition can still be checked before the values are known.
+func resolvePipelineParameters(I18n *i18n.Localizer, parameters string, values map[string]interface{}, requireValues bool) (map[string]interface{}, error) {
+	var declarations []model.PipelineParameter
+
+	if strings.TrimSpace(parameters) != "" {
+		if err := json.Unmarshal([]byte(parameters), &declarations); err != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "pipeline.parameter.definition.invalid",
+				TemplateData: map[string]interface{}{
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			return nil, errors.New(errMessage)
+		}
+	}
+
+	resolved := make(map[string]interface{})
+
+	for _, declaration := range declarations {
+		if !parameterPlaceholder.MatchString("${params." + declaration.Name + "}") {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "pipeline.parameter.name.invalid",
+				TemplateData: map[string]interface{}{
+					"Name": declaration.Name,
+				},
+				PluralCount: 1,
+			})
+
+			return nil, errors.New(errMessage)
+		}
+
+		value := declaration.Default
+
+		if override, ok := values[declaration.Name]; ok {
+			value = override
+		}
+
+		if value == nil && !requireValues {
+			value = zeroParameterValue(declaration.Type)
+		}
+
+		typedValue, err := convertParameterValue(declaration.Type, value)
+
+		if err != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "pipeline.parameter.value.invalid",
+				TemplateData: map[string]interface{}{
+					"Name":   declaration.Name,
+					"Type":   declaration.Type,
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			return nil, errors.New(errMessage)
+		}
+
+		resolved[declaration.Name] = typedValue
+	}
+
+	for name := range values {
+		if _, ok := resolved[name]; !ok {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "pipeline.parameter.unknown",
+				TemplateData: map[string]interface{}{
+					"Name": name,
+				},
+				PluralCount: 1,
+			})
+
+			return nil, errors.New(errMessage)
+		}
+	}
+
+	return resolved, nil
+}
+
+// convertParameterValue converts a parameter value, as decoded from JSON, to the Go type of the parameter type.
+func convertParameterValue(parameterType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("a value is required")
+	}
+
+	text := formatParameterValue(value)
+
+	switch parameterType {
+	case "string":
+		return text, nil
+	case "int":
+		return strconv.ParseInt(text, 10, 64)
+	case "float":
+		return strconv.ParseFloat(text, 64)
+	case "bool":
+		return strconv.ParseBool(text)
+	}
+
+	return nil, fmt.Errorf("unknown type %s", parameterType)
+}
+
+// zeroParameterValue returns the zero value of the parameter type, as it would be decoded from JSON.
+func zeroParameterValue(parameterType string) interface{} {
+	switch parameterType {
+	case "int", "float":
+		return float64(0)
+	case "bool":
+		return false
+	}
+
+	return ""
+}
+
+// formatParameterValue returns the text a value, as decoded from JSON, is inserted as in a step config.
+func formatParameterValue(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// substitutePipelineParameters replaces the ${params.name} placeholders in the step configs of the definition.
+// A bool parameter that makes up a whole field is inserted as a JSON boolean; every other value is inserted as text,
+// which the numeric step config fields accept as well.
+func substitutePipelineParameters(I18n *i18n.Localizer, definition string, values map[string]interface{}) (string, error) {
+	if !parameterPlaceholder.MatchString(definition) {
+		return definition, nil
+	}
+
+	var nodeDescriptions []map[string]interface{}
+
+	if err := json.Unmarshal([]byte(definition), &nodeDescriptions); err != nil {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "pipeline.validate.definition.invalid",
+			TemplateData: map[string]interface{}{
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return "", errors.New(errMessage)
+	}
+
+	var undefined []string
+
+	substitute := func(text string) interface{} {
+		if match := parameterPlaceholder.FindStringSubmatch(text); match != nil && match[0] == text {
+			if value, ok := values[match[1]].(bool); ok {
+				return value
+			}
+		}
+
+		return parameterPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+			name := parameterPlaceholder.FindStringSubmatch(placeholder)[1]
+			value, ok := values[name]
+
+			if !ok {
+				undefined = append(undefined, name)
+				return placeholder
+			}
+
+			return formatParameterValue(value)
+		})
+	}
+
+	for _, nodeDescription := range nodeDescriptions {
+		data, _ := nodeDescription["data"].(map[string]interface{})
+		stepConfig, _ := data["stepConfig"].(map[string]interface{})
+
+		for field, value := range stepConfig {
+			if text, ok := value.(string); ok {
+				stepConfig[field] = substitute(text)
+			}
+		}
+	}
+
+	if len(undefined) > 0 {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "pipeline.parameter.undefined",
+			TemplateData: map[string]interface{}{
+				"Names": strings.Join(undefined, ", "),
+			},
+			PluralCount: 1,
+		})
+
+		return "", errors.New(errMessage)
+	}
+
+	resolvedDefinition, err := json.Marshal(nodeDescriptions)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(resolvedDefinition), nil
+}
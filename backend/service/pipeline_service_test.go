@@ -0,0 +1,77 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvePipelineParameters(t *testing.T) {
+	parameters := `[{"name": "epochs", "type": "int", "default": 10}, {"name": "repo", "type": "string"}]`
+
+	tests := []struct {
+		name          string
+		values        map[string]interface{}
+		requireValues bool
+		want          map[string]interface{}
+		wantErr       bool
+	}{
+		{name: "defaults and values", values: map[string]interface{}{"repo": "url"}, requireValues: true, want: map[string]interface{}{"epochs": int64(10), "repo": "url"}},
+		{name: "value overrides default", values: map[string]interface{}{"epochs": float64(5), "repo": "url"}, requireValues: true, want: map[string]interface{}{"epochs": int64(5), "repo": "url"}},
+		{name: "required value missing on run", requireValues: true, wantErr: true},
+		{name: "required value missing on validation", want: map[string]interface{}{"epochs": int64(10), "repo": ""}},
+		{name: "unknown parameter", values: map[string]interface{}{"other": "1"}, wantErr: true},
+		{name: "invalid value", values: map[string]interface{}{"epochs": "many"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolvePipelineParameters(newTestLocalizer(t), parameters, test.values, test.requireValues)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSubstitutePipelineParameters(t *testing.T) {
+	values := map[string]interface{}{"epochs": int64(5), "fit": true}
+
+	tests := []struct {
+		name       string
+		definition string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "no placeholders",
+			definition: `[{"data": {"stepConfig": {"epochs": "1"}}}]`,
+			want:       `[{"data": {"stepConfig": {"epochs": "1"}}}]`,
+		},
+		{
+			name:       "placeholders",
+			definition: `[{"data": {"stepConfig": {"epochs": "${params.epochs}", "fit_intercept": "${params.fit}", "customArguments": "--epochs ${params.epochs}"}}}]`,
+			want:       `[{"data":{"stepConfig":{"customArguments":"--epochs 5","epochs":"5","fit_intercept":true}}}]`,
+		},
+		{name: "undefined parameter", definition: `[{"data": {"stepConfig": {"epochs": "${params.other}"}}}]`, wantErr: true},
+		{name: "invalid definition", definition: `[{"data": "${params.epochs}"`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := substitutePipelineParameters(newTestLocalizer(t), test.definition, values)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
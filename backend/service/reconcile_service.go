@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"di/model"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// defaultReconcileInterval is the time between two reconciliations when RUN_RECONCILE_INTERVAL isn't set.
+const defaultReconcileInterval = time.Minute
+
+// reconcileGracePeriod keeps the reconciler away from runs that were just set to executing and may not have a task
+// ID yet.
+const reconcileGracePeriod = time.Minute
+
+// reconcileLockKey is the Redis key held by the instance that last reconciled the orphaned runs, so that the
+// instances sharing the database don't reconcile them at the same time.
+const reconcileLockKey = "run:reconciler:lock"
+
+// StartRunReconciler reconciles the orphaned runs right away, and then every RUN_RECONCILE_INTERVAL seconds.
+func (service *runServiceImpl) StartRunReconciler() {
+	interval := defaultReconcileInterval
+
+	if reconcileInterval, exists := os.LookupEnv("RUN_RECONCILE_INTERVAL"); exists {
+		if value, err := strconv.Atoi(reconcileInterval); err == nil && value > 0 {
+			interval = time.Duration(value) * time.Second
+		}
+	}
+
+	service.reconcileOrphanedRunsExclusively(interval)
+
+	go func() {
+		for range time.Tick(interval) {
+			service.reconcileOrphanedRunsExclusively(interval)
+		}
+	}()
+}
+
+// reconcileOrphanedRunsExclusively reconciles the orphaned runs unless another instance did within the interval.
+func (service *runServiceImpl) reconcileOrphanedRunsExclusively(interval time.Duration) {
+	if service.RedisClient != nil {
+		// The lock expires a bit before the next tick of the instance holding it
+		acquired, err := service.RedisClient.SetNX(context.Background(), reconcileLockKey, os.Getpid(), interval*9/10).Result()
+
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+
+		if !acquired {
+			return
+		}
+	}
+
+	service.ReconcileOrphanedRuns()
+}
+
+// ReconcileOrphanedRuns marks as interrupted the executing runs whose task is no longer queued nor processed,
+// as it happens when the process dies mid-run. When RUN_RECONCILE_REQUEUE is true, the interrupted runs are
+// executed again from the definition they were created with, except for the child runs of sweeps.
+func (service *runServiceImpl) ReconcileOrphanedRuns() {
+	runs, err := service.RunRepository.FindByStatus(2)
+
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	requeue := false
+
+	if reconcileRequeue, exists := os.LookupEnv("RUN_RECONCILE_REQUEUE"); exists {
+		requeue, _ = strconv.ParseBool(reconcileRequeue)
+	}
+
+	for _, run := range runs {
+		// A sweep has no task of its own, it is done once its child runs are
+		if run.Sweep != "" {
+			if err := service.advanceSweep(run.ID); err != nil {
+				log.Println(err.Error())
+			}
+
+			continue
+		}
+
+		if time.Since(run.LastRun) < reconcileGracePeriod || service.isRunTaskAlive(run) {
+			continue
+		}
+
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.reconcile.interrupted",
+			TemplateData: map[string]interface{}{
+				"ID":     run.ID,
+				"TaskID": run.TaskID,
+			},
+			PluralCount: 1,
+		})
+
+		log.Println(errMessage)
+
+		// Only the instance that marks the run as interrupted executes it again
+		interrupted, err := service.updateRunStatusIn(run.ID, []uint{2}, 10, nil, errMessage)
+
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		if !interrupted {
+			continue
+		}
+
+		runStepStatuses, err := service.FindRunStepStatusesByRun(run.ID)
+
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		for i := range runStepStatuses {
+			if runStepStatuses[i].RunStatusID == 2 {
+				if err := service.updateStepRunStatus(&runStepStatuses[i], 10, errMessage); err != nil {
+					log.Println(err.Error())
+				}
+			}
+		}
+
+		// An interrupted child run of a sweep is done, and the sweep goes on with the next one
+		if run.ParentRunID != 0 {
+			service.finishSweepTrial(run.ID)
+			continue
+		}
+
+		if !requeue {
+			continue
+		}
+
+		if err := service.Execute(run.ID); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// isRunTaskAlive returns whether the task of a run is still waiting in the queue or being processed.
+func (service *runServiceImpl) isRunTaskAlive(run model.Run) bool {
+	if run.TaskID == "" {
+		return false
+	}
+
+	taskInfo, err := service.TaskInspector.GetTaskInfo(getRunTaskQueue(run), run.TaskID)
+
+	if err != nil {
+		if !errors.Is(err, asynq.ErrTaskNotFound) {
+			// when Redis can't be reached the run is left alone until the next reconciliation
+			log.Println(err.Error())
+			return true
+		}
+
+		return false
+	}
+
+	return taskInfo.State != asynq.TaskStateCompleted && taskInfo.State != asynq.TaskStateArchived
+}
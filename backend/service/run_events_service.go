@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"di/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// runEventsChannel is the Redis pub/sub channel the status changes of a run are published to.
+func runEventsChannel(runID uint) string {
+	return fmt.Sprintf("run:%d:events", runID)
+}
+
+// publishRunEvent publishes a status change of a run. Publishing is best effort: the status is already stored and
+// a client that misses an event gets the current state when it subscribes again.
+func (service *runServiceImpl) publishRunEvent(event model.RunEvent) {
+	if service.RedisClient == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	if err := service.RedisClient.Publish(context.Background(), runEventsChannel(event.RunID), payload).Err(); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (service *runServiceImpl) publishStepEvent(runStepStatus model.RunStepStatus) {
+	eventType := model.RunEventStepFinished
+
+	switch runStepStatus.RunStatusID {
+	case 2:
+		eventType = model.RunEventStepStarted
+	case 3:
+		eventType = model.RunEventStepFailed
+	case 5:
+		eventType = model.RunEventWaitingFeedback
+	}
+
+	service.publishRunEvent(model.RunEvent{
+		Type:     eventType,
+		RunID:    runStepStatus.RunID,
+		StepID:   runStepStatus.StepID,
+		StepName: runStepStatus.Name,
+		StatusID: runStepStatus.RunStatusID,
+		Message:  runStepStatus.ErrorMessage,
+	})
+}
+
+// SubscribeRunEvents returns the status changes of a run published from now on. The channel is closed once ctx is done.
+func (service *runServiceImpl) SubscribeRunEvents(ctx context.Context, runID uint) (<-chan model.RunEvent, error) {
+	pubSub := service.RedisClient.Subscribe(ctx, runEventsChannel(runID))
+
+	// wait for the subscription to be confirmed, so the caller can load the current state without missing changes
+	if _, err := pubSub.Receive(ctx); err != nil {
+		pubSub.Close()
+
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.events.subscribe.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	events := make(chan model.RunEvent)
+
+	go func() {
+		defer close(events)
+		defer pubSub.Close()
+
+		messages := pubSub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event model.RunEvent
+
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					log.Println(err.Error())
+					continue
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
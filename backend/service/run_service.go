@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
 	"di/model"
 	"di/repository"
 	"di/steps"
 	"di/util"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,10 +15,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/dominikbraun/graph"
+	"github.com/go-redis/redis/v8"
 	"github.com/hibiken/asynq"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/robfig/cron/v3"
@@ -24,22 +32,32 @@ import (
 	"gorm.io/gorm"
 )
 
+const defaultMaxParallelSteps = 4
+
+// runTaskTimeout is the deadline of a run task. asynq falls back to a 30 minutes timeout when none is given,
+// which would kill long training steps now that the task context reaches them.
+const runTaskTimeout = 24 * time.Hour
+
 type runServiceImpl struct {
 	RunRepository   repository.RunRepository
 	PipelineService PipelineService
 	NodeTypeService StepService
 	TrainedService  TrainedModelService
 	TaskQueueClient asynq.Client
+	TaskInspector   *asynq.Inspector
+	RedisClient     *redis.Client
 	I18n            *i18n.Localizer
 }
 
-func NewRunService(gormDB *gorm.DB, client *asynq.Client, i18n *i18n.Localizer, pipelineService *PipelineService, stepTypeService *StepService, trainedService *TrainedModelService) RunService {
+func NewRunService(gormDB *gorm.DB, client *asynq.Client, inspector *asynq.Inspector, redisClient *redis.Client, i18n *i18n.Localizer, pipelineService *PipelineService, stepTypeService *StepService, trainedService *TrainedModelService) RunService {
 	return &runServiceImpl{
 		RunRepository:   repository.NewRunRepository(gormDB),
+		RedisClient:     redisClient,
 		PipelineService: *pipelineService,
 		NodeTypeService: *stepTypeService,
 		TrainedService:  *trainedService,
 		TaskQueueClient: *client,
+		TaskInspector:   inspector,
 		I18n:            i18n,
 	}
 }
@@ -101,6 +119,25 @@ func (service *runServiceImpl) FindRunStepStatusesByRun(runID uint) ([]model.Run
 	return runStepStatuses, err
 }
 
+func (service *runServiceImpl) FindRunArtifactsByRun(runID uint) ([]model.RunArtifact, error) {
+	runArtifacts, err := service.RunRepository.FindRunArtifactsByRun(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.find.artifact.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return runArtifacts, errors.New(errMessage)
+	}
+
+	return runArtifacts, err
+}
+
 func (service *runServiceImpl) FindHumanFeedbackQueriesByStepID(runID uint, stepID uint) ([]model.HumanFeedbackQuery, error) {
 	runStepStatuses, err := service.RunRepository.FindHumanFeedbackQueriesByStepID(runID, stepID)
 
@@ -120,6 +157,46 @@ func (service *runServiceImpl) FindHumanFeedbackQueriesByStepID(runID uint, step
 	return runStepStatuses, err
 }
 
+// FindRunMetricSeries returns the series of values of every metric the steps of the run reported.
+func (service *runServiceImpl) FindRunMetricSeries(runID uint) ([]model.RunMetricSeries, error) {
+	runMetrics, err := service.RunRepository.FindRunMetricsByRun(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.find.metric.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	series := []model.RunMetricSeries{}
+
+	for _, runMetric := range runMetrics {
+		last := len(series) - 1
+
+		if last < 0 || series[last].StepID != runMetric.StepID || series[last].Name != runMetric.Name {
+			series = append(series, model.RunMetricSeries{Name: runMetric.Name, StepID: runMetric.StepID, StepName: runMetric.StepName})
+			last++
+		}
+
+		x := int64(len(series[last].Points))
+
+		if runMetric.Iteration.Valid {
+			x = runMetric.Iteration.Int64
+		}
+
+		series[last].Points = append(series[last].Points, model.RunMetricPoint{X: x, Y: runMetric.Value, Time: runMetric.Time})
+		series[last].Last = runMetric.Value
+	}
+
+	return series, nil
+}
+
 func (service *runServiceImpl) FindHumanFeedbackQueriesByRunID(runID uint) ([]model.HumanFeedbackQuery, error) {
 	runStepStatuses, err := service.RunRepository.FindHumanFeedbackQueriesByRunID(runID)
 
@@ -196,8 +273,20 @@ func (service *runServiceImpl) FindHumanFeedbackQueryStatusByID(queryStatusID ui
 	return queryStatus, err
 }
 
-func (service *runServiceImpl) Create(pipeline model.Pipeline) (model.Run, error) {
-	newRun := &model.Run{PipelineID: pipeline.ID, RunStatusID: 1, Definition: pipeline.Definition}
+func (service *runServiceImpl) Create(pipeline model.Pipeline, runReq model.CreateRunReq) (model.Run, error) {
+	parameters, err := resolvePipelineParameters(service.I18n, pipeline.Parameters, runReq.Parameters, true)
+
+	if err != nil {
+		return model.Run{}, err
+	}
+
+	resolvedParameters, err := json.Marshal(parameters)
+
+	if err != nil {
+		return model.Run{}, err
+	}
+
+	newRun := &model.Run{PipelineID: pipeline.ID, RunStatusID: 1, Definition: pipeline.Definition, MaxParallelSteps: runReq.MaxParallelSteps, Parameters: string(resolvedParameters)}
 	if err := service.RunRepository.Create(newRun); err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "run.repository.create.run.failed",
@@ -214,7 +303,12 @@ func (service *runServiceImpl) Create(pipeline model.Pipeline) (model.Run, error
 }
 
 func (service *runServiceImpl) CreateRunStepStatus(runID uint, stepID int, stepName string, runStatusID uint, errorMessage string) error {
-	newRunStepStatus := &model.RunStepStatus{RunID: runID, StepID: stepID, RunStatusID: runStatusID, LastRun: time.Now()}
+	newRunStepStatus := &model.RunStepStatus{RunID: runID, StepID: stepID, Name: stepName, RunStatusID: runStatusID, ErrorMessage: errorMessage, LastRun: time.Now()}
+
+	if runStatus, err := service.RunRepository.GetRunStatusByID(runStatusID); err == nil {
+		stampExecutionTimes(&newRunStepStatus.StartedAt, &newRunStepStatus.ResumedAt, &newRunStepStatus.FinishedAt, &newRunStepStatus.DurationMs, runStatusID, runStatus.IsFinal)
+	}
+
 	if err := service.RunRepository.CreateRunStepStatus(newRunStepStatus); err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "run.repository.create.step-status.failed",
@@ -227,6 +321,8 @@ func (service *runServiceImpl) CreateRunStepStatus(runID uint, stepID int, stepN
 		return errors.New(errMessage)
 	}
 
+	service.publishStepEvent(*newRunStepStatus)
+
 	return nil
 }
 
@@ -294,7 +390,30 @@ func (service *runServiceImpl) Execute(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	if err := service.UpdateRunStatus(runID, 2, 0, ""); err != nil {
+	pipeline, err := service.PipelineService.Get(run.PipelineID)
+
+	if err != nil {
+		return err
+	}
+
+	// A run executes the definition it was created with, even when the pipeline was edited since
+	runDefinition := run.Definition
+
+	if runDefinition == "" {
+		runDefinition = pipeline.Definition
+	}
+
+	definition, err := resolveRunDefinition(service.I18n, *run, runDefinition)
+
+	if err != nil {
+		return err
+	}
+
+	if err := service.validatePipelineDefinition(definition, pipeline.ID); err != nil {
+		return err
+	}
+
+	if err := service.UpdateRunStatus(runID, 2, nil, ""); err != nil {
 		return err
 	}
 
@@ -313,23 +432,65 @@ func (service *runServiceImpl) Execute(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	pipeline, err := service.PipelineService.Get(run.PipelineID)
+	err = service.RunRepository.DeleteAllRunArtifacts(runID)
 
 	if err != nil {
-		return err
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.artifact.all.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	err = service.RunRepository.DeleteAllRunMetrics(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.metric.all.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	err = service.RunRepository.DeleteAllRunTestResults(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.test-results.all.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
 	}
 
-	runPipelineTask, err := service.NewRunPipelineTask(pipeline.ID, runID, pipeline.Definition)
+	runPipelineTask, err := service.NewRunPipelineTask(pipeline.ID, runID, runDefinition)
 
 	if err != nil {
 		return err
 	}
 
-	if _, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue("runs"), asynq.Timeout(0)); err != nil {
+	queue := runQueue(*pipeline, InteractiveQueue)
+	taskInfo, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue(queue), asynq.Timeout(runTaskTimeout))
+
+	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "tasks.client.enqueue.failed",
 			TemplateData: map[string]interface{}{
-				"Queue":  "runs",
+				"Queue":  queue,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
@@ -338,7 +499,7 @@ func (service *runServiceImpl) Execute(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	return nil
+	return service.updateRunTaskID(runID, taskInfo.ID, taskInfo.Queue)
 }
 
 func (service *runServiceImpl) Resume(runID uint) error {
@@ -384,43 +545,46 @@ func (service *runServiceImpl) Resume(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	humanFeedbackQueries, err := service.FindHumanFeedbackQueriesByStepID(runID, uint(runStepStatuses[0].StepID))
+	// Several steps may wait for feedback, the first one whose feedback is all submitted is resumed and the others
+	// keep waiting
+	resumeStepID := -1
 
-	if err != nil {
-		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.service.resume.status.error",
-			TemplateData: map[string]interface{}{
-				"ID": run.ID,
-			},
-			PluralCount: 1,
-		})
-		log.Printf(errMessage)
-		return errors.New(errMessage)
-	}
+	for _, runStepStatus := range runStepStatuses {
+		humanFeedbackQueries, err := service.FindHumanFeedbackQueriesByStepID(runID, uint(runStepStatus.StepID))
 
-	if len(humanFeedbackQueries) == 0 {
-		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.service.resume.status.error",
-			TemplateData: map[string]interface{}{
-				"ID": run.ID,
-			},
-			PluralCount: 1,
+		if err != nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.resume.status.error",
+				TemplateData: map[string]interface{}{
+					"ID": run.ID,
+				},
+				PluralCount: 1,
+			})
+			log.Printf(errMessage)
+			return errors.New(errMessage)
+		}
+
+		if len(humanFeedbackQueries) == 0 {
+			continue
+		}
+
+		humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
+			return humanFeedbackQuery.QueryStatusID != 3
 		})
-		log.Printf(errMessage)
-		return errors.New(errMessage)
-	}
 
-	humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
-		return humanFeedbackQuery.QueryStatusID != 3
-	})
+		queriesCounts := len(humanFeedbackQueries)
 
-	queriesCounts := len(humanFeedbackQueries)
+		humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
+			return humanFeedbackQuery.QueryStatusID == 2
+		})
 
-	humanFeedbackQueries = util.Filter(humanFeedbackQueries, func(humanFeedbackQuery model.HumanFeedbackQuery) bool {
-		return humanFeedbackQuery.QueryStatusID == 2
-	})
+		if len(humanFeedbackQueries) == queriesCounts {
+			resumeStepID = runStepStatus.StepID
+			break
+		}
+	}
 
-	if len(humanFeedbackQueries) < queriesCounts {
+	if resumeStepID < 0 {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "run.service.resume.queries-not-submitted.error",
 			TemplateData: map[string]interface{}{
@@ -432,21 +596,25 @@ func (service *runServiceImpl) Resume(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	if err := service.UpdateRunStatus(runID, 2, 0, ""); err != nil {
+	if err := service.UpdateRunStatus(runID, 2, nil, ""); err != nil {
 		return err
 	}
 
-	runPipelineTask, err := service.NewResumeRunPipelineTask(run.Pipeline.ID, runID, run.Definition, runStepStatuses[0].StepID)
+	runPipelineTask, err := service.NewResumeRunPipelineTask(run.Pipeline.ID, runID, run.Definition, resumeStepID)
 
 	if err != nil {
 		return err
 	}
 
-	if _, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue("runs"), asynq.Timeout(0)); err != nil {
+	// resuming after feedback never waits behind scheduled or heavy runs
+	queue := InteractiveQueue
+	taskInfo, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue(queue), asynq.Timeout(runTaskTimeout))
+
+	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "tasks.client.enqueue.failed",
 			TemplateData: map[string]interface{}{
-				"Queue":  "runs",
+				"Queue":  queue,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
@@ -455,17 +623,19 @@ func (service *runServiceImpl) Resume(runID uint) error {
 		return errors.New(errMessage)
 	}
 
-	return nil
+	return service.updateRunTaskID(runID, taskInfo.ID, taskInfo.Queue)
 }
 
-func (service *runServiceImpl) Update(run *model.Run) error {
-	err := service.RunRepository.Update(run)
+// Rerun executes a finished run again starting from the given step. The work dir of the run is kept,
+// so the outputs of the upstream steps are reused, and only the step and its descendants are executed.
+func (service *runServiceImpl) Rerun(runID uint, stepID int) error {
+	run, err := service.RunRepository.FindByID(runID)
 
 	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.update.run.failed",
+			MessageID: "run.repository.find.run.id.failed",
 			TemplateData: map[string]interface{}{
-				"ID":     run.ID,
+				"ID":     runID,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
@@ -474,18 +644,11 @@ func (service *runServiceImpl) Update(run *model.Run) error {
 		return errors.New(errMessage)
 	}
 
-	return nil
-}
-
-func (service *runServiceImpl) UpdateRunStepStatus(runStepStatus *model.RunStepStatus) error {
-	err := service.RunRepository.UpdateRunStepStatus(runStepStatus)
-
-	if err != nil {
+	if !run.RunStatus.IsFinal {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.update.step-status.failed",
+			MessageID: "run.service.rerun.status.error",
 			TemplateData: map[string]interface{}{
-				"ID":     runStepStatus.ID,
-				"Reason": err.Error(),
+				"ID": run.ID,
 			},
 			PluralCount: 1,
 		})
@@ -493,19 +656,20 @@ func (service *runServiceImpl) UpdateRunStepStatus(runStepStatus *model.RunStepS
 		return errors.New(errMessage)
 	}
 
-	return nil
-}
+	pipelineGraph, err := service.createPipelineGraph(RunPipelinePayload{PipelineID: run.PipelineID, RunID: run.ID, GraphDefinition: run.Definition})
 
-func (service *runServiceImpl) UpdateHumanFeedbackQuery(query *model.HumanFeedbackQuery) error {
+	if err != nil {
+		return err
+	}
 
-	err := service.RunRepository.UpdateHumanFeedbackQuery(query)
+	step, err := pipelineGraph.Vertex(stepID)
 
 	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.update.human-feedback-query.failed",
+			MessageID: "run.service.rerun.step.error",
 			TemplateData: map[string]interface{}{
-				"ID":     query.ID,
-				"Reason": err.Error(),
+				"ID":     run.ID,
+				"StepID": stepID,
 			},
 			PluralCount: 1,
 		})
@@ -513,20 +677,41 @@ func (service *runServiceImpl) UpdateHumanFeedbackQuery(query *model.HumanFeedba
 		return errors.New(errMessage)
 	}
 
-	return nil
-}
+	adjacencyMap, err := pipelineGraph.AdjacencyMap()
 
-func (service *runServiceImpl) UpdateHumanFeedbackRects(rects []model.HumanFeedbackRect) error {
+	if err != nil {
+		return err
+	}
 
-	for _, rect := range rects {
-		err := service.RunRepository.UpdateHumanFeedbackRect(&rect)
+	predecessorMap, err := pipelineGraph.PredecessorMap()
 
-		if err != nil {
+	if err != nil {
+		return err
+	}
+
+	runStepStatuses, err := service.FindRunStepStatusesByRun(run.ID)
+
+	if err != nil {
+		return err
+	}
+
+	succeeded := make(map[int]bool)
+
+	for _, runStepStatus := range runStepStatuses {
+		if runStepStatus.RunStatusID == 4 || runStepStatus.RunStatusID == 9 {
+			succeeded[runStepStatus.StepID] = true
+		}
+	}
+
+	for predecessor := range predecessorMap[stepID] {
+		if !succeeded[predecessor] {
 			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "run.repository.update.human-feedback-rects.failed",
+				MessageID: "run.service.rerun.upstream.error",
 				TemplateData: map[string]interface{}{
-					"ID":     rect.HumanFeedbackQueryID,
-					"Reason": err.Error(),
+					"ID":         run.ID,
+					"StepID":     stepID,
+					"Name":       step.GetName(),
+					"UpstreamID": predecessor,
 				},
 				PluralCount: 1,
 			})
@@ -535,17 +720,49 @@ func (service *runServiceImpl) UpdateHumanFeedbackRects(rects []model.HumanFeedb
 		}
 	}
 
-	return nil
-}
+	rerunSteps := reachableSteps(adjacencyMap, stepID)
 
-func (service *runServiceImpl) Delete(id uint) error {
-	err := service.RunRepository.Delete(id)
+	for _, runStepStatus := range runStepStatuses {
+		if rerunSteps[runStepStatus.StepID] {
+			if err := service.DeleteRunStepStatus(runStepStatus.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The steps that are executed again may fail or be skipped, so nothing of their previous execution is kept
+	for rerunStepID := range rerunSteps {
+		if err := service.RunRepository.DeleteRunArtifactsByStep(run.ID, rerunStepID); err != nil {
+			return err
+		}
+
+		if err := service.RunRepository.DeleteRunMetricsByStep(run.ID, rerunStepID); err != nil {
+			return err
+		}
+
+		if err := service.RunRepository.DeleteRunTestResultsByStep(run.ID, rerunStepID); err != nil {
+			return err
+		}
+	}
+
+	if err := service.UpdateRunStatus(runID, 2, nil, ""); err != nil {
+		return err
+	}
+
+	runPipelineTask, err := service.NewResumeRunPipelineTask(run.PipelineID, runID, run.Definition, stepID)
+
+	if err != nil {
+		return err
+	}
+
+	queue := runQueue(run.Pipeline, InteractiveQueue)
+	taskInfo, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue(queue), asynq.Timeout(runTaskTimeout))
 
 	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.delete.run.failed",
+			MessageID: "tasks.client.enqueue.failed",
 			TemplateData: map[string]interface{}{
-				"ID":     id,
+				"Queue":  queue,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
@@ -554,17 +771,19 @@ func (service *runServiceImpl) Delete(id uint) error {
 		return errors.New(errMessage)
 	}
 
-	return nil
+	return service.updateRunTaskID(runID, taskInfo.ID, taskInfo.Queue)
 }
 
-func (service *runServiceImpl) DeleteRunStepStatus(id uint) error {
-	err := service.RunRepository.DeleteRunStepStatus(id)
+// Cancel stops a run that is executing or waiting for feedback. The run is marked as cancelled right away,
+// and the worker executing it is notified, so it kills the processes of the running steps and skips the remaining ones.
+func (service *runServiceImpl) Cancel(runID uint) error {
+	run, err := service.RunRepository.FindByID(runID)
 
 	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.delete.step-status.id.failed",
+			MessageID: "run.repository.find.run.id.failed",
 			TemplateData: map[string]interface{}{
-				"ID":     id,
+				"ID":     runID,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
@@ -573,18 +792,11 @@ func (service *runServiceImpl) DeleteRunStepStatus(id uint) error {
 		return errors.New(errMessage)
 	}
 
-	return nil
-}
-
-func (service *runServiceImpl) DeleteAllHumanFeedbackQueriesByRunID(runID uint) error {
-	err := service.RunRepository.DeleteAllHumanFeedbackQueriesByRunID(runID)
-
-	if err != nil {
+	if run.RunStatusID != 2 && run.RunStatusID != 5 {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.delete.human-feedback-queries.run.failed",
+			MessageID: "run.service.cancel.status.error",
 			TemplateData: map[string]interface{}{
-				"ID":     runID,
-				"Reason": err.Error(),
+				"ID": run.ID,
 			},
 			PluralCount: 1,
 		})
@@ -592,11 +804,262 @@ func (service *runServiceImpl) DeleteAllHumanFeedbackQueriesByRunID(runID uint)
 		return errors.New(errMessage)
 	}
 
-	return nil
-}
+	errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "run.service.execute.run.cancelled",
+		TemplateData: map[string]interface{}{
+			"ID": run.ID,
+		},
+		PluralCount: 1,
+	})
+
+	// The run may finish between its status being read and cancelled, in which case it is left as it finished
+	cancelled, err := service.updateRunStatusIn(runID, activeRunStatusIDs, 6, nil, errMessage)
 
-func (service *runServiceImpl) NewRunPipelineTask(pipelineID uint, runID uint, graph string) (*asynq.Task, error) {
-	payload, err := json.Marshal(RunPipelinePayload{PipelineID: pipelineID, RunID: runID, GraphDefinition: graph})
+	if err != nil {
+		return err
+	}
+
+	if !cancelled {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.cancel.status.error",
+			TemplateData: map[string]interface{}{
+				"ID": run.ID,
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	runStepStatuses, err := service.FindRunStepStatusesByRun(runID)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range runStepStatuses {
+		if runStepStatuses[i].RunStatusID == 5 {
+			if err := service.updateStepRunStatus(&runStepStatuses[i], 6, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The children of a sweep are cancelled with it, stopping the tasks of the ones that started
+	if run.Sweep != "" {
+		childRuns, err := service.FindSweepTrials(runID)
+
+		if err != nil {
+			return err
+		}
+
+		for _, childRun := range childRuns {
+			if childRun.RunStatusID == 2 || childRun.RunStatusID == 5 {
+				if err := service.Cancel(childRun.ID); err != nil {
+					log.Println(err.Error())
+				}
+
+				continue
+			}
+
+			if _, err := service.updateRunStatusIn(childRun.ID, activeRunStatusIDs, 6, nil, errMessage); err != nil {
+				return err
+			}
+		}
+	}
+
+	if run.TaskID == "" {
+		return nil
+	}
+
+	if err := service.TaskInspector.CancelProcessing(run.TaskID); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "tasks.inspector.cancel.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     run.TaskID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	// a task still waiting in the queue is dropped, an active one can't be deleted and stops on its own
+	taskInfo, err := service.TaskInspector.GetTaskInfo(getRunTaskQueue(*run), run.TaskID)
+
+	if err == nil && taskInfo.State == asynq.TaskStateActive {
+		return nil
+	}
+
+	// The task of a child run of a sweep that won't execute can't let the sweep go on, so it is done here
+	if run.ParentRunID != 0 {
+		defer service.finishSweepTrial(run.ID)
+	}
+
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+
+	if err == nil {
+		err = service.TaskInspector.DeleteTask(getRunTaskQueue(*run), run.TaskID)
+	}
+
+	if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "tasks.inspector.cancel.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     run.TaskID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) Update(run *model.Run) error {
+	err := service.RunRepository.Update(run)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     run.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) UpdateRunStepStatus(runStepStatus *model.RunStepStatus) error {
+	err := service.RunRepository.UpdateRunStepStatus(runStepStatus)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.step-status.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runStepStatus.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) UpdateHumanFeedbackQuery(query *model.HumanFeedbackQuery) error {
+
+	err := service.RunRepository.UpdateHumanFeedbackQuery(query)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.human-feedback-query.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     query.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) UpdateHumanFeedbackRects(rects []model.HumanFeedbackRect) error {
+
+	for _, rect := range rects {
+		err := service.RunRepository.UpdateHumanFeedbackRect(&rect)
+
+		if err != nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.repository.update.human-feedback-rects.failed",
+				TemplateData: map[string]interface{}{
+					"ID":     rect.HumanFeedbackQueryID,
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			return errors.New(errMessage)
+		}
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) Delete(id uint) error {
+	err := service.RunRepository.Delete(id)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     id,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) DeleteRunStepStatus(id uint) error {
+	err := service.RunRepository.DeleteRunStepStatus(id)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.step-status.id.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     id,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) DeleteAllHumanFeedbackQueriesByRunID(runID uint) error {
+	err := service.RunRepository.DeleteAllHumanFeedbackQueriesByRunID(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.delete.human-feedback-queries.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) NewRunPipelineTask(pipelineID uint, runID uint, graph string) (*asynq.Task, error) {
+	payload, err := json.Marshal(RunPipelinePayload{PipelineID: pipelineID, RunID: runID, GraphDefinition: graph})
 	if err != nil {
 		return nil, err
 	}
@@ -619,21 +1082,71 @@ func (service *runServiceImpl) HandleRunPipelineTask(ctx context.Context, t *asy
 		return asynq.SkipRetry
 	}
 
+	// A child run of a sweep lets the sweep go on once it is done, however its task ends
+	defer service.finishSweepTrial(runPipelinePayload.RunID)
+
+	if service.isRunCancelled(runPipelinePayload.RunID) {
+		return asynq.SkipRetry
+	}
+
+	run, err := service.RunRepository.FindByID(runPipelinePayload.RunID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return asynq.SkipRetry
+	}
+
+	definition, err := service.prepareRunDefinition(*run, runPipelinePayload.GraphDefinition)
+
+	if err != nil {
+		log.Println(err.Error())
+
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, err.Error()); err != nil {
+			log.Println(err.Error())
+		}
+
+		return asynq.SkipRetry
+	}
+
+	runPipelinePayload.GraphDefinition = definition
+
 	if runPipelinePayload.StepID.Valid {
-		return service.resumeRunPipelineTask(runPipelinePayload)
+		return service.resumeRunPipelineTask(ctx, runPipelinePayload)
 	}
 
-	return service.executeRunPipelineTask(runPipelinePayload)
+	return service.executeRunPipelineTask(ctx, runPipelinePayload)
 }
 
-func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipelinePayload) error {
+func (service *runServiceImpl) executeRunPipelineTask(ctx context.Context, runPipelinePayload RunPipelinePayload) error {
+
+	if err := service.validatePipelineDefinition(runPipelinePayload.GraphDefinition, runPipelinePayload.PipelineID); err != nil {
+		log.Printf(err.Error())
+
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, err.Error()); err != nil {
+			log.Println(err.Error())
+		}
+
+		return asynq.SkipRetry
+	}
 
 	pipelineGraph, err := service.createPipelineGraph(runPipelinePayload)
 
 	if err != nil {
 		log.Printf(err.Error())
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, err.Error()); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, err.Error()); err != nil {
+			log.Println(err.Error())
+		}
+
+		return asynq.SkipRetry
+	}
+
+	entryStepIDs, err := service.findEntrySteps(pipelineGraph)
+
+	if err != nil {
+		log.Printf(err.Error())
+
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, err.Error()); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -653,7 +1166,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -673,7 +1186,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -695,7 +1208,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -714,7 +1227,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -733,7 +1246,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -752,7 +1265,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -770,7 +1283,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 			PluralCount: 1,
 		})
 
-		service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage)
+		service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage)
 		log.Println(errors.New(errMessage))
 		return asynq.SkipRetry
 	}
@@ -788,7 +1301,7 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 		})
 
 		log.Print(errMessage)
-		service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage)
+		service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage)
 		return asynq.SkipRetry
 	}
 
@@ -799,17 +1312,19 @@ func (service *runServiceImpl) executeRunPipelineTask(runPipelinePayload RunPipe
 		return asynq.SkipRetry
 	}
 
-	return service.traverseAndExecuteSteps(currentPipelineWorkDir, runPipelinePayload.RunID, pipelineGraph, 0, logFile)
+	stepConfigs := createStepExecutionConfigs(runPipelinePayload.GraphDefinition)
+
+	return service.traverseAndExecuteSteps(ctx, currentPipelineWorkDir, runPipelinePayload.RunID, pipelineGraph, stepConfigs, entryStepIDs, logFile)
 }
 
-func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipelinePayload) error {
+func (service *runServiceImpl) resumeRunPipelineTask(ctx context.Context, runPipelinePayload RunPipelinePayload) error {
 
 	pipelineGraph, err := service.createPipelineGraph(runPipelinePayload)
 
 	if err != nil {
 		log.Printf(err.Error())
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, err.Error()); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, err.Error()); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -829,7 +1344,7 @@ func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipel
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -849,7 +1364,7 @@ func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipel
 
 		log.Println(errMessage)
 
-		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage); err != nil {
+		if err := service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage); err != nil {
 			log.Println(err.Error())
 		}
 
@@ -869,7 +1384,7 @@ func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipel
 			PluralCount: 1,
 		})
 
-		service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage)
+		service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage)
 		log.Println(errors.New(errMessage))
 		return asynq.SkipRetry
 	}
@@ -887,7 +1402,7 @@ func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipel
 		})
 
 		log.Print(errMessage)
-		service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage)
+		service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage)
 		return asynq.SkipRetry
 	}
 
@@ -904,136 +1419,419 @@ func (service *runServiceImpl) resumeRunPipelineTask(runPipelinePayload RunPipel
 		})
 
 		log.Print(errMessage)
-		service.UpdateRunStatus(runPipelinePayload.RunID, 3, 0, errMessage)
+		service.UpdateRunStatus(runPipelinePayload.RunID, 3, nil, errMessage)
 		return asynq.SkipRetry
 	}
 
-	return service.traverseAndExecuteSteps(currentPipelineWorkDir, runPipelinePayload.RunID, pipelineGraph, int(runPipelinePayload.StepID.Int64), logFile)
+	stepConfigs := createStepExecutionConfigs(runPipelinePayload.GraphDefinition)
+
+	return service.traverseAndExecuteSteps(ctx, currentPipelineWorkDir, runPipelinePayload.RunID, pipelineGraph, stepConfigs, []int{int(runPipelinePayload.StepID.Int64)}, logFile)
 }
 
-func (service *runServiceImpl) traverseAndExecuteSteps(currentPipelineWorkDir string, runID uint, pipelineGraph graph.Graph[int, steps.Step], startAtStepID int, logFile *os.File) error {
+func (service *runServiceImpl) traverseAndExecuteSteps(ctx context.Context, currentPipelineWorkDir string, runID uint, pipelineGraph graph.Graph[int, steps.Step], stepConfigs map[int]stepExecutionConfig, entryStepIDs []int, logFile *os.File) error {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	// The steps running in parallel share the run log, so every write to it goes through runLog
+	runLog := util.NewSyncWriter(logFile)
+	runLogger := log.New(runLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	msg := fmt.Sprintf("Executing run %d", runID)
 	log.Println(msg)
 	runLogger.Println(msg)
 
-	hasError := false
-	var stepErr error
-	hasFeedback := false
-	stepWaitingFeedback := 0
+	state := &runExecutionState{stepConfigs: stepConfigs, runLog: runLog}
 
-	graph.BFS(pipelineGraph, int(startAtStepID), func(id int) bool {
-		step, _ := pipelineGraph.Vertex(id)
+	if err := service.scheduleSteps(ctx, currentPipelineWorkDir, runID, pipelineGraph, entryStepIDs, logFile, runLogger, state); err != nil {
+		state.fail(err)
+	}
 
-		msg := fmt.Sprintf("Executing step %s (%d) ...", step.GetName(), step.GetID())
-		log.Println(msg)
-		runLogger.Println(msg)
+	// Steps waiting for feedback since a previous execution keep waiting, along with the ones of this execution
+	stepsWaitingFeedback := service.findStepsWaitingFeedback(runID)
 
-		var feedbackQueries []model.HumanFeedbackQuery
-		var feebackRects [][]model.HumanFeedbackRect
+	if state.cancelled && !service.isRunCancelled(runID) {
+		// The task was cancelled by asynq shutting down the worker rather than by the user
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.run.interrupted",
+			TemplateData: map[string]interface{}{
+				"ID": runID,
+			},
+			PluralCount: 1,
+		})
 
-		run, _ := service.Get(uint(runID))
+		if _, err := service.updateRunStatusIn(runID, activeRunStatusIDs, 10, nil, errMessage); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
 
-		var runStepStatus *model.RunStepStatus
-		hasStepStatus := false
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	} else if state.cancelled {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.run.cancelled",
+			TemplateData: map[string]interface{}{
+				"ID": runID,
+			},
+			PluralCount: 1,
+		})
 
-		if step.GetIsStaggered() {
-			runStepStatuses, getError := service.FindRunStepStatusesByRun(run.ID)
+		if _, err := service.updateRunStatusIn(runID, activeRunStatusIDs, 6, nil, errMessage); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
 
-			if getError != nil {
-				log.Printf(getError.Error())
-				hasError = true
-				stepErr = getError
-				return true
-			}
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	} else if state.hasError {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": state.stepErr.Error(),
+			},
+			PluralCount: 1,
+		})
 
-			runStepStatuses = util.Filter(runStepStatuses, func(runStateStatus model.RunStepStatus) bool {
-				return runStateStatus.RunStatusID == 5 && runStateStatus.StepID == startAtStepID
-			})
+		if _, err := service.updateRunStatusIn(runID, activeRunStatusIDs, 3, nil, errMessage); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
 
-			if len(runStepStatuses) > 0 {
-				hasStepStatus = true
-				runStepStatus = &runStepStatuses[0]
-			}
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	} else if failedSteps := service.findFailedSteps(runID); len(stepsWaitingFeedback) == 0 && len(failedSteps) > 0 {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.run.partial-success",
+			TemplateData: map[string]interface{}{
+				"ID":    runID,
+				"Steps": strings.Join(failedSteps, ", "),
+			},
+			PluralCount: 1,
+		})
+
+		if _, err := service.updateRunStatusIn(runID, activeRunStatusIDs, 8, nil, errMessage); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
 		}
 
-		if hasStepStatus { // we're resuming a run
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	} else {
+		runStatusID := 4
+		if len(stepsWaitingFeedback) > 0 {
+			runStatusID = 5
+		}
+		if _, err := service.updateRunStatusIn(runID, activeRunStatusIDs, uint(runStatusID), stepsWaitingFeedback, ""); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
 
-			if runStepStatus.RunStatusID == 5 {
-				err := service.updateStepRunStatus(runStepStatus, 2, "")
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.run.success",
+			TemplateData: map[string]interface{}{
+				"ID": runID,
+			},
+			PluralCount: 1,
+		})
 
-				if err != nil {
-					log.Println(err.Error())
-					runLogger.Println(err.Error())
-				}
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	}
 
-				feedbackQueries, err = service.FindHumanFeedbackQueriesByStepID(runID, uint(runStepStatus.StepID))
+	logFile.Close()
 
-				if err != nil {
-					errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-						MessageID: "run.handler.feedback.find.fail",
-						TemplateData: map[string]interface{}{
-							"ID":     runStepStatus.StepID,
-							"Reason": err.Error(),
-						},
-						PluralCount: 1,
-					})
-					log.Printf(errMessage)
-					hasError = true
-					stepErr = errors.New(errMessage)
-
-					if err := service.updateStepRunStatus(runStepStatus, 3, err.Error()); err != nil {
-						log.Println(err.Error())
-						runLogger.Println(err.Error())
-					}
+	return asynq.SkipRetry
+}
 
-					return true
-				}
+// findLatestRunStepStatuses returns the status of the last execution of every step of the run, ignoring the ones
+// left by earlier executions of the same step.
+func (service *runServiceImpl) findLatestRunStepStatuses(runID uint) ([]model.RunStepStatus, error) {
+	runStepStatuses, err := service.FindRunStepStatusesByRun(runID)
 
-				for _, humanFeedbackQuery := range feedbackQueries {
-					if humanFeedbackQuery.QueryStatusID == 3 {
-						continue
-					}
+	if err != nil {
+		return nil, err
+	}
 
-					rects, err := service.FindHumanFeedbackRectsByHumanFeedbackQueryID(humanFeedbackQuery.ID)
+	latest := make(map[int]int)
+	var latestRunStepStatuses []model.RunStepStatus
 
-					if err != nil {
-						log.Printf(err.Error())
-						hasError = true
-						stepErr = errors.New(err.Error())
-						return true
-					}
+	for _, runStepStatus := range runStepStatuses {
+		if i, ok := latest[runStepStatus.StepID]; ok {
+			latestRunStepStatuses[i] = runStepStatus
+			continue
+		}
 
-					feebackRects = append(feebackRects, rects)
-				}
-			}
-		} else {
-			runStepStatus = &model.RunStepStatus{RunID: runID, StepID: id, Name: step.GetName(), RunStatusID: 2, LastRun: time.Now()}
-			err := service.RunRepository.CreateRunStepStatus(runStepStatus)
+		latest[runStepStatus.StepID] = len(latestRunStepStatuses)
+		latestRunStepStatuses = append(latestRunStepStatuses, runStepStatus)
+	}
+
+	return latestRunStepStatuses, nil
+}
+
+// findFailedSteps returns the names of the steps of the run that failed, in this execution or in a previous one.
+func (service *runServiceImpl) findFailedSteps(runID uint) []string {
+	var failedSteps []string
+
+	runStepStatuses, err := service.findLatestRunStepStatuses(runID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return failedSteps
+	}
+
+	for _, runStepStatus := range runStepStatuses {
+		if runStepStatus.RunStatusID == 3 {
+			failedSteps = append(failedSteps, runStepStatus.Name)
+		}
+	}
+
+	return failedSteps
+}
+
+// findStepsWaitingFeedback returns the ids of the steps of the run waiting for feedback, in increasing order.
+func (service *runServiceImpl) findStepsWaitingFeedback(runID uint) []int {
+	var stepIDs []int
+
+	runStepStatuses, err := service.findLatestRunStepStatuses(runID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return stepIDs
+	}
+
+	for _, runStepStatus := range runStepStatuses {
+		if runStepStatus.RunStatusID == 5 {
+			stepIDs = append(stepIDs, runStepStatus.StepID)
+		}
+	}
+
+	sort.Ints(stepIDs)
+
+	return stepIDs
+}
+
+// executeStep runs a single step of the run and records its outcome in the shared execution state.
+// It is called concurrently for independent steps, so every write to the state goes through its mutex.
+// It returns the status the step finished with; its successors may be executed only when it succeeded.
+func (service *runServiceImpl) executeStep(ctx context.Context, currentPipelineWorkDir string, runID uint, pipelineGraph graph.Graph[int, steps.Step], id int, logFile *os.File, runLogger *log.Logger, state *runExecutionState) uint {
+	step, _ := pipelineGraph.Vertex(id)
+
+	msg := fmt.Sprintf(util.StepLogMarker, step.GetName(), step.GetID())
+	log.Println(msg)
+	runLogger.Println(msg)
+
+	var feedbackQueries []model.HumanFeedbackQuery
+	var feebackRects [][]model.HumanFeedbackRect
+
+	run, _ := service.Get(uint(runID))
+
+	var runStepStatus *model.RunStepStatus
+	hasStepStatus := false
+
+	if step.GetIsStaggered() {
+		runStepStatuses, getError := service.FindRunStepStatusesByRun(run.ID)
+
+		if getError != nil {
+			log.Printf(getError.Error())
+			state.fail(getError)
+			return 3
+		}
+
+		runStepStatuses = util.Filter(runStepStatuses, func(runStateStatus model.RunStepStatus) bool {
+			return runStateStatus.RunStatusID == 5 && runStateStatus.StepID == id
+		})
+
+		if len(runStepStatuses) > 0 {
+			hasStepStatus = true
+			runStepStatus = &runStepStatuses[0]
+		}
+	}
+
+	if hasStepStatus { // we're resuming a run
+
+		if runStepStatus.RunStatusID == 5 {
+			err := service.updateStepRunStatus(runStepStatus, 2, "")
 
 			if err != nil {
 				log.Println(err.Error())
 				runLogger.Println(err.Error())
+			}
+
+			feedbackQueries, err = service.FindHumanFeedbackQueriesByStepID(runID, uint(runStepStatus.StepID))
+
+			if err != nil {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "run.handler.feedback.find.fail",
+					TemplateData: map[string]interface{}{
+						"ID":     runStepStatus.StepID,
+						"Reason": err.Error(),
+					},
+					PluralCount: 1,
+				})
+				log.Printf(errMessage)
+				state.fail(errors.New(errMessage))
 
 				if err := service.updateStepRunStatus(runStepStatus, 3, err.Error()); err != nil {
 					log.Println(err.Error())
 					runLogger.Println(err.Error())
 				}
 
-				hasError = true
-				return true
+				return 3
+			}
+
+			for _, humanFeedbackQuery := range feedbackQueries {
+				if humanFeedbackQuery.QueryStatusID == 3 {
+					continue
+				}
+
+				rects, err := service.FindHumanFeedbackRectsByHumanFeedbackQueryID(humanFeedbackQuery.ID)
+
+				if err != nil {
+					log.Printf(err.Error())
+					state.fail(err)
+					return 3
+				}
+
+				feebackRects = append(feebackRects, rects)
 			}
 		}
+	} else {
+		runStepStatus = &model.RunStepStatus{RunID: runID, StepID: id, Name: step.GetName(), RunStatusID: 2, LastRun: time.Now()}
+		stampExecutionTimes(&runStepStatus.StartedAt, &runStepStatus.ResumedAt, &runStepStatus.FinishedAt, &runStepStatus.DurationMs, 2, false)
+		err := service.RunRepository.CreateRunStepStatus(runStepStatus)
 
-		feedbackPayload, executeError := step.Execute(logFile, feebackRects, service.I18n)
+		if err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
 
-		if executeError != nil {
+			if err := service.updateStepRunStatus(runStepStatus, 3, err.Error()); err != nil {
+				log.Println(err.Error())
+				runLogger.Println(err.Error())
+			}
+
+			state.fail(err)
+			return 3
+		}
+
+		service.publishStepEvent(*runStepStatus)
+
+		// The metrics of a previous execution of the step are dropped, unlike when it resumes after feedback
+		if err := service.RunRepository.DeleteRunMetricsByStep(runID, id); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
+	}
+
+	var cacheKey string
+	var workDirSnapshot map[string]util.FileState
+
+	if state.tracksOutputs() && !step.GetIsStaggered() {
+		if state.stepConfigs[id].Cache {
+			cacheKey = service.createStepCacheKey(ctx, run, pipelineGraph, id, state.stepConfigs[id])
+
+			if cacheKey != "" && service.restoreCachedStep(currentPipelineWorkDir, cacheKey, run.Pipeline.UserID, step, runStepStatus, runLogger) {
+				service.copyCachedStepMetrics(runStepStatus.CachedFromRunID, runID, step, runLogger)
+				service.ingestStepOutputs(currentPipelineWorkDir, pipelineGraph, step, runStepStatus, run, state, runLogger)
+				return 9
+			}
+		}
+
+		snapshot, err := util.SnapshotDir(currentPipelineWorkDir)
+
+		if err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		} else {
+			workDirSnapshot = snapshot
+		}
+	}
+
+	var feedbackPayload []model.HumanFeedbackQueryPayload
+	stepLogPath := util.StepLogPath(logFile.Name(), step.GetID())
+	stepLog, executeError := steps.NewStepLog(stepLogPath, state.runLog, hasStepStatus)
+
+	if executeError != nil {
+		executeError = errors.New(service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "os.cmd.create.file.failed",
+			TemplateData: map[string]interface{}{
+				"Path":   stepLogPath,
+				"Reason": executeError.Error(),
+			},
+			PluralCount: 1,
+		}))
+	} else {
+		usage := &processUsage{}
+
+		stepLog.ReportMetrics(func(metric steps.StepMetric) {
+			service.recordStepMetric(runID, step, metric, runLogger)
+		}, func(err error) {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "os.cmd.read.file.failed",
+				TemplateData: map[string]interface{}{
+					"Path":   stepLog.MetricsPath(),
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			log.Println(errMessage)
+			runLogger.Println(errMessage)
+		})
+		stepLog.OnProcess = usage.add
+
+		feedbackPayload, executeError = service.executeStepAttempts(ctx, currentPipelineWorkDir, step, runStepStatus, state.stepConfigs[id], stepLog, feebackRects, runLogger)
+		stepLog.Close()
+		usage.record(runStepStatus)
+	}
+
+	if errors.Is(executeError, context.Canceled) {
+		// A run is marked as cancelled before its task is, so a run still active is stopped by the worker shutting down
+		runStatusID := uint(6)
+		msg := fmt.Sprintf("Step %s (%d) was cancelled", step.GetName(), step.GetID())
+
+		if !service.isRunCancelled(runID) {
+			runStatusID = 10
+			msg = fmt.Sprintf("Step %s (%d) was interrupted", step.GetName(), step.GetID())
+		}
+
+		log.Println(msg)
+		runLogger.Println(msg)
+
+		if err := service.updateStepRunStatus(runStepStatus, runStatusID, ""); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+		}
+
+		return runStatusID
+	}
+
+	if executeError != nil {
+		if state.stepConfigs[id].OnFailure != onFailureContinue {
+			state.fail(executeError)
+		}
+
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.step.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     step.GetID(),
+				"Reason": executeError.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		runLogger.Println(errMessage)
+		log.Println(errMessage)
+
+		if err := service.updateStepRunStatus(runStepStatus, 3, executeError.Error()); err != nil {
+			runLogger.Println(errMessage)
+			log.Println(errMessage)
+		}
 
-			stepErr = executeError
-			hasError = true
+		return 3
+	} else {
+		for _, feedback := range feedbackPayload {
+			executeError = service.CreateHumanFeedbackQuery(feedback.Epoch, feedback.RunID, feedback.StepID, feedback.QueryID, feedback.Rects)
+		}
 
+		if executeError != nil {
 			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "run.service.execute.step.failed",
 				TemplateData: map[string]interface{}{
@@ -1050,431 +1848,2135 @@ func (service *runServiceImpl) traverseAndExecuteSteps(currentPipelineWorkDir st
 				runLogger.Println(errMessage)
 				log.Println(errMessage)
 			}
-
-			return true
 		} else {
-			for _, feedback := range feedbackPayload {
-				executeError = service.CreateHumanFeedbackQuery(feedback.Epoch, feedback.RunID, feedback.StepID, feedback.QueryID, feedback.Rects)
-				hasFeedback = true
-			}
-
-			if executeError != nil {
-				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-					MessageID: "run.service.execute.step.failed",
-					TemplateData: map[string]interface{}{
-						"ID":     step.GetID(),
-						"Reason": executeError.Error(),
-					},
-					PluralCount: 1,
-				})
-
-				runLogger.Println(errMessage)
-				log.Println(errMessage)
-
-				if err := service.updateStepRunStatus(runStepStatus, 3, executeError.Error()); err != nil {
-					runLogger.Println(errMessage)
-					log.Println(errMessage)
-				}
-			} else {
-				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-					MessageID: "run.service.execute.step.success",
-					TemplateData: map[string]interface{}{
-						"ID": step.GetID(),
-					},
-					PluralCount: 1,
-				})
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.execute.step.success",
+				TemplateData: map[string]interface{}{
+					"ID": step.GetID(),
+				},
+				PluralCount: 1,
+			})
 
-				runLogger.Println(errMessage)
-				log.Println(errMessage)
-			}
+			runLogger.Println(errMessage)
+			log.Println(errMessage)
 		}
+	}
 
-		if step.GetIsStaggered() {
-			queryStatus, err := service.FindHumanFeedbackQueryStatusByID(3)
+	if step.GetIsStaggered() {
+		queryStatus, err := service.FindHumanFeedbackQueryStatusByID(3)
 
+		if err != nil {
+			runLogger.Println(err.Error())
+			log.Println(err.Error())
+			state.fail(err)
+			return 3
+		}
+
+		for _, feedbackQuery := range feedbackQueries {
+			feedbackQuery.QueryStatus = *queryStatus
+			feedbackQuery.QueryStatusID = queryStatus.ID
+			err = service.UpdateHumanFeedbackQuery(&feedbackQuery)
+		}
+
+		if err != nil {
+			runLogger.Println(err.Error())
+			log.Println(err.Error())
+			state.fail(err)
+			return 3
+		}
+	}
+
+	hasFeedback := len(feedbackPayload) > 0
+
+	if !hasFeedback { // it means the execution finalized
+		service.ingestStepOutputs(currentPipelineWorkDir, pipelineGraph, step, runStepStatus, run, state, runLogger)
+	}
+
+	if !hasFeedback && workDirSnapshot != nil {
+		service.recordStepOutputs(currentPipelineWorkDir, workDirSnapshot, cacheKey, run.Pipeline.UserID, step, runStepStatus, runLogger)
+	}
+
+	var updateError error
+
+	if hasFeedback {
+		updateError = service.updateStepRunStatus(runStepStatus, 5, "") // waiting feedback
+	} else {
+		updateError = service.updateStepRunStatus(runStepStatus, 4, "") // success
+	}
+
+	if updateError != nil {
+		runLogger.Println(updateError.Error())
+		log.Println(updateError.Error())
+		state.fail(updateError)
+		return 3
+	}
+
+	if hasFeedback {
+		return 5
+	}
+
+	return 4
+}
+
+// ingestStepOutputs records what a finished step produced, whether it executed or was restored from the cache: the
+// models it trained, its artifacts and its test results.
+func (service *runServiceImpl) ingestStepOutputs(currentPipelineWorkDir string, pipelineGraph graph.Graph[int, steps.Step], step steps.Step, runStepStatus *model.RunStepStatus, run *model.Run, state *runExecutionState, runLogger *log.Logger) {
+	finishedAt := time.Now()
+
+	state.trainedMutex.Lock()
+	service.createTrainedModels(currentPipelineWorkDir, pipelineGraph, step, run, state, runStepStatus.StartedAt.Time, finishedAt, runLogger)
+	state.trainedMutex.Unlock()
+
+	service.recordStepArtifacts(currentPipelineWorkDir, run.ID, step, runStepStatus.StartedAt.Time, runLogger)
+	service.recordStepTestResults(currentPipelineWorkDir, run.ID, step, runLogger)
+}
+
+// copyCachedStepMetrics copies the metrics the step reported in the run its outputs are cached from, since a cached
+// step does not execute and so can't report them again.
+func (service *runServiceImpl) copyCachedStepMetrics(cachedFromRunID uint, runID uint, step steps.Step, runLogger *log.Logger) {
+	runMetrics, err := service.RunRepository.FindRunMetricsByRun(cachedFromRunID)
+
+	if err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	for _, runMetric := range runMetrics {
+		if runMetric.StepID != step.GetID() {
+			continue
+		}
+
+		runMetric.Model = gorm.Model{}
+		runMetric.RunID = runID
+
+		if err := service.RunRepository.CreateRunMetric(&runMetric); err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+			return
+		}
+	}
+}
+
+// stepCacheKeyData is everything the result of a cached step depends on.
+type stepCacheKeyData struct {
+	PipelineID    uint
+	UserID        uint
+	Type          string
+	StepConfig    model.StepDataConfig
+	Model         string
+	AssetHashes   map[string]string
+	UpstreamSteps map[int]string
+	Commit        string
+}
+
+// createStepCacheKey hashes the pipeline and owner of the run, the configuration of the step, the content of the
+// assets and uploaded scripts it references, the commit it checks out and the outputs of all its upstream steps.
+// It returns an empty key when some of them is unknown, so the step is executed.
+func (service *runServiceImpl) createStepCacheKey(ctx context.Context, run *model.Run, pipelineGraph graph.Graph[int, steps.Step], id int, config stepExecutionConfig) string {
+	predecessorMap, err := pipelineGraph.PredecessorMap()
+
+	if err != nil {
+		return ""
+	}
+
+	runStepStatuses, err := service.findLatestRunStepStatuses(run.ID)
+
+	if err != nil {
+		return ""
+	}
+
+	outputHashes := make(map[int]string)
+
+	for _, runStepStatus := range runStepStatuses {
+		if runStepStatus.RunStatusID == 4 || runStepStatus.RunStatusID == 9 {
+			outputHashes[runStepStatus.StepID] = runStepStatus.OutputHash
+		}
+	}
+
+	keyData := stepCacheKeyData{
+		PipelineID:    run.PipelineID,
+		UserID:        run.Pipeline.UserID,
+		Type:          config.Description.Type,
+		StepConfig:    config.Description.Data.StepConfig,
+		Model:         config.Description.Data.Model.ValueOrZero(),
+		AssetHashes:   make(map[string]string),
+		UpstreamSteps: make(map[int]string),
+	}
+
+	for upstreamStepID := range reachableSteps(predecessorMap, id) {
+		if upstreamStepID == id {
+			continue
+		}
+
+		outputHash, ok := outputHashes[upstreamStepID]
+
+		if !ok || outputHash == "" {
+			return ""
+		}
+
+		keyData.UpstreamSteps[upstreamStepID] = outputHash
+	}
+
+	stepConfig := config.Description.Data.StepConfig
+	assetPaths := []string{stepConfig.DatasetPath, stepConfig.TrainerPath, stepConfig.TesterPath, stepConfig.TrainedPath}
+
+	if scriptPath := uploadedScriptPath(run.PipelineID, config.Description); scriptPath != "" {
+		assetPaths = append(assetPaths, scriptPath)
+	}
+
+	for _, assetPath := range assetPaths {
+		if assetPath == "" {
+			continue
+		}
+
+		assetHash, err := util.HashFile(assetPath)
+
+		if err != nil {
+			return ""
+		}
+
+		keyData.AssetHashes[assetPath] = assetHash
+	}
+
+	step, _ := pipelineGraph.Vertex(id)
+
+	if checkoutRepo, ok := step.(*steps.CheckoutRepo); ok {
+		if keyData.Commit, err = checkoutRepo.ResolveCommit(ctx); err != nil {
+			return ""
+		}
+	}
+
+	keyBytes, err := json.Marshal(keyData)
+
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(keyBytes)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// uploadedScriptPath returns the path of the script uploaded for the pipeline that a script step executes, or an
+// empty path when the step runs an inline script, or a script of the work dir, instead.
+func uploadedScriptPath(pipelineID uint, stepDescription model.NodeDescription) string {
+	filename := stepDescription.Data.StepConfig.Filename.ValueOrZero()
+	scriptType := stepDescription.Data.NameAndType.ScriptType
+
+	switch stepDescription.Type {
+	case "shellScript", "pythonScript":
+		if scriptType == "inline" || scriptType == "file" {
+			return ""
+		}
+	case "customPyTorchModel":
+		if scriptType != "file" {
+			return ""
+		}
+	case "customHITL":
+	default:
+		return ""
+	}
+
+	if filename == "" {
+		return ""
+	}
+
+	return os.Getenv("FILE_UPLOAD_DIR") + "pipelines/" + fmt.Sprint(pipelineID) + "/" + filename
+}
+
+// getStepCacheDir returns the dir where the outputs cached under the key are kept.
+func (service *runServiceImpl) getStepCacheDir(cacheKey string) (string, error) {
+	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
+
+	if !exists {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "env.variable.find.failed",
+			TemplateData: map[string]interface{}{
+				"Name": "PIPELINES_WORK_DIR",
+			},
+			PluralCount: 1,
+		})
+
+		return "", errors.New(errMessage)
+	}
+
+	return filepath.Join(pipelinesWorkDir, "cache", cacheKey), nil
+}
+
+// restoreCachedStep copies the outputs cached under the key into the work dir and marks the step as cached.
+// It returns false when there is nothing cached under the key, or the outputs couldn't be restored.
+func (service *runServiceImpl) restoreCachedStep(currentPipelineWorkDir string, cacheKey string, userID uint, step steps.Step, runStepStatus *model.RunStepStatus, runLogger *log.Logger) bool {
+	stepCacheEntry, err := service.RunRepository.FindStepCacheEntryByKey(cacheKey, userID)
+
+	if err != nil {
+		return false
+	}
+
+	stepCacheDir, err := service.getStepCacheDir(cacheKey)
+
+	if err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return false
+	}
+
+	err = filepath.Walk(stepCacheDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(stepCacheDir, path)
+
+		if err != nil {
+			return err
+		}
+
+		return util.CopyFile(path, filepath.Join(currentPipelineWorkDir, relativePath))
+	})
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.step.cache.restore.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     step.GetID(),
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+		return false
+	}
+
+	runStepStatus.OutputHash = stepCacheEntry.OutputHash
+	runStepStatus.CachedFromRunID = stepCacheEntry.RunID
+
+	if err := service.updateStepRunStatus(runStepStatus, 9, ""); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return false
+	}
+
+	errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "run.service.execute.step.cached",
+		TemplateData: map[string]interface{}{
+			"ID":    step.GetID(),
+			"RunID": stepCacheEntry.RunID,
+		},
+		PluralCount: 1,
+	})
+
+	log.Println(errMessage)
+	runLogger.Println(errMessage)
+
+	return true
+}
+
+// recordStepOutputs hashes the files the step created or modified under the outputs it declares and, when the step is
+// cached, keeps a copy of them under the cache key for the owner of the pipeline. Since independent steps share the
+// work dir, a dir shared with steps running at the same time may hold some of their files too.
+func (service *runServiceImpl) recordStepOutputs(currentPipelineWorkDir string, workDirSnapshot map[string]util.FileState, cacheKey string, userID uint, step steps.Step, runStepStatus *model.RunStepStatus, runLogger *log.Logger) {
+	snapshot, err := util.SnapshotDir(currentPipelineWorkDir)
+
+	if err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	outputs := util.Filter(util.ChangedFiles(workDirSnapshot, snapshot), func(path string) bool {
+		return isStepOutput(step, path)
+	})
+	outputHash := sha256.New()
+
+	for _, output := range outputs {
+		fileHash, err := util.HashFile(filepath.Join(currentPipelineWorkDir, output))
+
+		if err != nil {
+			log.Println(err.Error())
+			runLogger.Println(err.Error())
+			return
+		}
+
+		fmt.Fprintf(outputHash, "%s %s\n", output, fileHash)
+	}
+
+	runStepStatus.OutputHash = hex.EncodeToString(outputHash.Sum(nil))
+
+	if cacheKey == "" {
+		return
+	}
+
+	stepCacheDir, err := service.getStepCacheDir(cacheKey)
+
+	if err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	if err := os.RemoveAll(stepCacheDir); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	for _, output := range outputs {
+		if err := util.CopyFile(filepath.Join(currentPipelineWorkDir, output), filepath.Join(stepCacheDir, output)); err != nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.execute.step.cache.store.failed",
+				TemplateData: map[string]interface{}{
+					"ID":     runStepStatus.StepID,
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			log.Println(errMessage)
+			runLogger.Println(errMessage)
+			os.RemoveAll(stepCacheDir)
+			return
+		}
+	}
+
+	stepCacheEntry := &model.StepCacheEntry{Key: cacheKey, UserID: userID, RunID: runStepStatus.RunID, StepID: runStepStatus.StepID, OutputHash: runStepStatus.OutputHash}
+
+	if err := service.RunRepository.SaveStepCacheEntry(stepCacheEntry); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+	}
+}
+
+// isStepOutput tells whether the file at path, relative to the work dir, is one of the outputs the step declares or
+// lies in one of its output dirs.
+func isStepOutput(step steps.Step, path string) bool {
+	path = filepath.ToSlash(path)
+
+	for _, output := range step.GetOutputs() {
+		if output.Path == "" || path == output.Path || (strings.HasSuffix(output.Path, "/") && strings.HasPrefix(path, output.Path)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createTrainedModels registers the models a trainer step saved as new versions of the models of the same name, along
+// with the run, step, dataset, trainer and metrics that produced them.
+func (service *runServiceImpl) createTrainedModels(currentPipelineWorkDir string, pipelineGraph graph.Graph[int, steps.Step], step steps.Step, run *model.Run, state *runExecutionState, startedAt time.Time, finishedAt time.Time, runLogger *log.Logger) {
+	paths, err := trainedModelFiles(currentPipelineWorkDir, step, startedAt, finishedAt)
+
+	if err != nil {
+		runLogger.Print("Error creating trained models: " + err.Error())
+		return
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+
+	datasetID, trainerID := findModelLineage(pipelineGraph, state.stepConfigs, step.GetID())
+	metrics := service.lastMetricValues(run.ID)
+
+	for _, path := range paths {
+		trainedErr := func() error {
+			info, err := os.Stat(path)
+
+			if err != nil {
+				return err
+			}
+
+			pipeline, err := service.PipelineService.Get(step.GetPipelineID())
+
+			if err != nil {
+				return err
+			}
+
+			checksum, err := util.HashFile(path)
+
+			if err != nil {
+				return err
+			}
+
+			model_name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(filepath.Base(path)))
+			trained, created, err := service.TrainedService.RegisterVersion(pipeline.UserID, model_name, &model.Trained{
+				RunID:      run.ID,
+				PipelineID: pipeline.ID,
+				StepID:     step.GetID(),
+				StepName:   step.GetName(),
+				DatasetID:  datasetID,
+				TrainerID:  trainerID,
+				Metrics:    metrics,
+				Checksum:   checksum,
+				Size:       info.Size(),
+			})
+
+			if err != nil {
+				return err
+			}
+
+			// A model already registered with the same content, such as one saved again unchanged, is skipped
+			if !created {
+				return nil
+			}
+
+			msg := fmt.Sprintf("Registered version %d of model %s from step %s (%d)", trained.Version, model_name, step.GetName(), step.GetID())
+			log.Println(msg)
+			runLogger.Println(msg)
+
+			fileUploadDir, exists := os.LookupEnv("FILE_UPLOAD_DIR")
+
+			if !exists {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "env.variable.find.failed",
+					TemplateData: map[string]interface{}{
+						"Name": "FILE_UPLOAD_DIR",
+					},
+					PluralCount: 1,
+				})
+
+				log.Printf(errMessage)
+				return errors.New(errMessage)
+			}
+
+			modelUploadDir := filepath.Join(filepath.Join(fileUploadDir, "trained"), fmt.Sprint(trained.ID))
+			if err := os.MkdirAll(modelUploadDir, os.ModePerm); err != nil {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "os.cmd.mkdir.dir.failed",
+					TemplateData: map[string]interface{}{
+						"Path":   modelUploadDir,
+						"Reason": err.Error(),
+					},
+					PluralCount: 1,
+				})
+
+				log.Println(errMessage)
+				return err
+			}
+
+			sourceFile, err := os.Open(path)
+
+			if err != nil {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "os.cmd.read.dir.failed",
+					TemplateData: map[string]interface{}{
+						"Path":   modelUploadDir,
+						"Reason": err.Error(),
+					},
+					PluralCount: 1,
+				})
+
+				log.Println(errMessage)
+				return err
+			}
+
+			defer sourceFile.Close()
+
+			filePath := filepath.Join(modelUploadDir, filepath.Base(path))
+			datasetFileDestination, err := os.Create(filePath)
+
+			if err != nil {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "os.cmd.read.dir.failed",
+					TemplateData: map[string]interface{}{
+						"Path":   modelUploadDir,
+						"Reason": err.Error(),
+					},
+					PluralCount: 1,
+				})
+
+				log.Println(errMessage)
+				return err
+			}
+
+			defer datasetFileDestination.Close()
+
+			_, err = io.Copy(datasetFileDestination, sourceFile)
+
+			if err != nil {
+				errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "os.cmd.copy.dir.failed",
+					TemplateData: map[string]interface{}{
+						"Path":   modelUploadDir,
+						"Reason": err.Error(),
+					},
+					PluralCount: 1,
+				})
+
+				log.Println(errMessage)
+				return err
+			}
+
+			trained.Path = filePath
+			err = service.TrainedService.Update(trained)
+
+			if err != nil {
+				return err
+			}
+
+			return nil
+		}()
+
+		if trainedErr != nil {
+			runLogger.Print("Error creating trained models: " + trainedErr.Error())
+		}
+	}
+}
+
+// trainedModelFiles returns the .pt files a trainer step saved to the trained models dir while it executed. The dir
+// is shared by the trainers of the run, so the files changed before the step started are not its models, nor those
+// changed after it finished, which another trainer may still be writing.
+func trainedModelFiles(currentPipelineWorkDir string, step steps.Step, startedAt time.Time, finishedAt time.Time) ([]string, error) {
+	var paths []string
+
+	if _, ok := step.(*steps.Trainer); !ok {
+		return paths, nil
+	}
+
+	for _, output := range step.GetOutputs() {
+		if output.Path != steps.TrainedModelsDir {
+			continue
+		}
+
+		outputPath := filepath.Join(currentPipelineWorkDir, output.Path)
+
+		if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(outputPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || filepath.Ext(path) != ".pt" {
+				return nil
+			}
+
+			if !modifiedSince(info, startedAt) || info.ModTime().After(finishedAt) {
+				return nil
+			}
+
+			paths = append(paths, path)
+			return nil
+		})
+
+		if err != nil {
+			return paths, err
+		}
+	}
+
+	return paths, nil
+}
+
+// findModelLineage returns the dataset and trainer a model produced by the step was trained with, i.e. those of the
+// step itself or of its nearest upstream steps.
+func findModelLineage(pipelineGraph graph.Graph[int, steps.Step], stepConfigs map[int]stepExecutionConfig, id int) (uint, uint) {
+	var datasetID, trainerID uint
+
+	predecessorMap, err := pipelineGraph.PredecessorMap()
+
+	if err != nil {
+		return datasetID, trainerID
+	}
+
+	visited := map[int]bool{id: true}
+	queue := []int{id}
+
+	for len(queue) > 0 && (datasetID == 0 || trainerID == 0) {
+		current := queue[0]
+		queue = queue[1:]
+		stepConfig := stepConfigs[current].Description.Data.StepConfig
+
+		if datasetID == 0 {
+			datasetID = stepConfig.DatasetID
+		}
+
+		if trainerID == 0 {
+			trainerID = stepConfig.TrainerID
+		}
+
+		predecessors := make([]int, 0, len(predecessorMap[current]))
+
+		for predecessor := range predecessorMap[current] {
+			predecessors = append(predecessors, predecessor)
+		}
+
+		sort.Ints(predecessors)
+
+		for _, predecessor := range predecessors {
+			if !visited[predecessor] {
+				visited[predecessor] = true
+				queue = append(queue, predecessor)
+			}
+		}
+	}
+
+	return datasetID, trainerID
+}
+
+// lastMetricValues returns the last value of every metric reported so far in the run as JSON, keyed by step name and
+// metric name.
+func (service *runServiceImpl) lastMetricValues(runID uint) string {
+	runMetricSeries, err := service.FindRunMetricSeries(runID)
+
+	if err != nil || len(runMetricSeries) == 0 {
+		return ""
+	}
+
+	metrics := make(map[string]float64)
+
+	for _, series := range runMetricSeries {
+		metrics[series.StepName+"/"+series.Name] = series.Last
+	}
+
+	metricsJSON, _ := json.Marshal(metrics)
+
+	return string(metricsJSON)
+}
+
+// executeStepAttempts executes the step until it succeeds or runs out of retries, waiting for the retry backoff
+// between attempts, which doubles after every failed attempt. Each attempt is bounded by the step timeout and recorded.
+func (service *runServiceImpl) executeStepAttempts(ctx context.Context, currentPipelineWorkDir string, step steps.Step, runStepStatus *model.RunStepStatus, config stepExecutionConfig, stepLog *steps.StepLog, feedbackRects [][]model.HumanFeedbackRect, runLogger *log.Logger) ([]model.HumanFeedbackQueryPayload, error) {
+	backoff := config.RetryBackoff
+	var outputSnapshots map[string]map[string]util.FileState
+
+	if config.MaxRetries > 0 {
+		outputSnapshots = snapshotStepOutputs(currentPipelineWorkDir, step)
+	}
+
+	for attempt := 1; ; attempt++ {
+		// A retry starts from the outputs as they were before the step, not from what the failed attempt left
+		if attempt > 1 {
+			if err := cleanStepOutputs(currentPipelineWorkDir, step, outputSnapshots); err != nil {
+				log.Println(err.Error())
+				runLogger.Println(err.Error())
+			}
+		}
+
+		var attemptCtx context.Context
+		var cancel context.CancelFunc
+
+		if config.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, config.Timeout)
+		} else {
+			attemptCtx, cancel = context.WithCancel(ctx)
+		}
+
+		runStepAttempt := &model.RunStepAttempt{RunStepStatusID: runStepStatus.ID, Attempt: uint(attempt), StartedAt: time.Now()}
+
+		feedbackPayload, err := step.Execute(attemptCtx, stepLog, feedbackRects, service.I18n)
+
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.execute.step.timeout",
+				TemplateData: map[string]interface{}{
+					"ID":      step.GetID(),
+					"Timeout": config.Timeout,
+				},
+				PluralCount: 1,
+			})
+
+			err = errors.New(errMessage)
+		}
+
+		cancel()
+
+		runStepAttempt.FinishedAt = time.Now()
+
+		if err != nil {
+			runStepAttempt.ErrorMessage = err.Error()
+		}
+
+		if createError := service.RunRepository.CreateRunStepAttempt(runStepAttempt); createError != nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.repository.create.step-attempt.failed",
+				TemplateData: map[string]interface{}{
+					"ID":     runStepStatus.ID,
+					"Reason": createError.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			log.Println(errMessage)
+			runLogger.Println(errMessage)
+		}
+
+		if err == nil || ctx.Err() != nil || attempt > config.MaxRetries {
+			return feedbackPayload, err
+		}
+
+		msg := fmt.Sprintf("Attempt %d of step %s (%d) failed, retrying in %v: %s", attempt, step.GetName(), step.GetID(), backoff, err.Error())
+		log.Println(msg)
+		runLogger.Println(msg)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		backoff *= 2
+	}
+}
+
+// snapshotStepOutputs returns the files found under every dir output of the step, indexed by the path of the output.
+// An output dir that does not exist yet has no snapshot.
+func snapshotStepOutputs(currentPipelineWorkDir string, step steps.Step) map[string]map[string]util.FileState {
+	outputSnapshots := make(map[string]map[string]util.FileState)
+
+	for _, output := range step.GetOutputs() {
+		if output.Path == "" || !strings.HasSuffix(output.Path, "/") {
+			continue
+		}
+
+		if snapshot, err := util.SnapshotDir(filepath.Join(currentPipelineWorkDir, output.Path)); err == nil {
+			outputSnapshots[output.Path] = snapshot
+		}
+	}
+
+	return outputSnapshots
+}
+
+// cleanStepOutputs removes what an attempt of the step wrote to its outputs. Output files are removed, as are the
+// output dirs that did not exist before the step, whereas in a dir shared with other steps only the files created or
+// changed since outputSnapshots were taken are removed.
+func cleanStepOutputs(currentPipelineWorkDir string, step steps.Step, outputSnapshots map[string]map[string]util.FileState) error {
+	for _, output := range step.GetOutputs() {
+		if output.Path == "" {
+			continue
+		}
+
+		outputPath := filepath.Join(currentPipelineWorkDir, output.Path)
+		snapshot, existed := outputSnapshots[output.Path]
+
+		if !strings.HasSuffix(output.Path, "/") || !existed {
+			if err := os.RemoveAll(outputPath); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		current, err := util.SnapshotDir(outputPath)
+
+		if err != nil {
+			return err
+		}
+
+		for _, changedFile := range util.ChangedFiles(snapshot, current) {
+			if err := os.Remove(filepath.Join(outputPath, changedFile)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Failure policies of a step. A failed step stops the run by default; with the continue policy only its
+// descendants are skipped and the run ends as a partial success. A step with the always policy runs once
+// all its predecessors are done, whatever their outcome, like a finally block.
+const (
+	onFailureStop     = "stop"
+	onFailureContinue = "continue"
+	onFailureAlways   = "always"
+)
+
+// stepExecutionConfig holds the settings the engine enforces around the execution of a step, whatever its type.
+type stepExecutionConfig struct {
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	OnFailure    string
+	Cache        bool
+	Description  model.NodeDescription
+}
+
+// createStepExecutionConfigs reads the execution settings of every step in the pipeline definition, indexed by step id.
+func createStepExecutionConfigs(graphDefinition string) map[int]stepExecutionConfig {
+	stepConfigs := make(map[int]stepExecutionConfig)
+
+	var stepDescriptions []model.NodeDescription
+
+	if err := json.Unmarshal([]byte(graphDefinition), &stepDescriptions); err != nil {
+		return stepConfigs
+	}
+
+	for _, stepDescription := range stepDescriptions {
+		id, err := strconv.Atoi(stepDescription.Data.ID)
+
+		if err != nil {
+			continue
+		}
+
+		stepConfig := stepDescription.Data.StepConfig
+
+		stepConfigs[id] = stepExecutionConfig{
+			Timeout:      time.Duration(stepConfig.Timeout.Int64) * time.Second,
+			MaxRetries:   int(stepConfig.MaxRetries.Int64),
+			RetryBackoff: time.Duration(stepConfig.RetryBackoff.Int64) * time.Second,
+			OnFailure:    stepConfig.OnFailure.ValueOrZero(),
+			Cache:        stepConfig.Cache,
+			Description:  stepDescription,
+		}
+	}
+
+	return stepConfigs
+}
+
+// runExecutionState holds the outcome of a run, shared by the steps that execute concurrently.
+type runExecutionState struct {
+	stepConfigs  map[int]stepExecutionConfig
+	runLog       io.Writer
+	mutex        sync.Mutex
+	trainedMutex sync.Mutex
+	hasError     bool
+	stepErr      error
+	cancelled    bool
+}
+
+func (state *runExecutionState) fail(err error) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if !state.hasError {
+		state.stepErr = err
+	}
+
+	state.hasError = true
+}
+
+// tracksOutputs tells whether the outputs of the steps must be hashed, which is only needed when some step is cached.
+func (state *runExecutionState) tracksOutputs() bool {
+	for _, stepConfig := range state.stepConfigs {
+		if stepConfig.Cache {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (state *runExecutionState) hasFailed() bool {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	return state.hasError
+}
+
+// scheduleSteps executes the entry steps and every step downstream of them, in topological order.
+// A step is started only after all of its predecessors have succeeded, either in this execution or,
+// when resuming, in a previous one. Independent steps run concurrently, up to the run parallelism limit.
+// A step waiting for feedback only holds back its own successors. A failed step skips its descendants and,
+// unless its failure policy says otherwise, every other step not started yet but the ones that always run.
+// When ctx is cancelled, the running steps are stopped and the steps not yet started are skipped,
+// whereas a deadline exceeded fails the run.
+func (service *runServiceImpl) scheduleSteps(ctx context.Context, currentPipelineWorkDir string, runID uint, pipelineGraph graph.Graph[int, steps.Step], entryStepIDs []int, logFile *os.File, runLogger *log.Logger, state *runExecutionState) error {
+	adjacencyMap, err := pipelineGraph.AdjacencyMap()
+
+	if err != nil {
+		return err
+	}
+
+	predecessorMap, err := pipelineGraph.PredecessorMap()
+
+	if err != nil {
+		return err
+	}
+
+	pending := make(map[int]bool)
+
+	for _, entryStepID := range entryStepIDs {
+		if _, ok := adjacencyMap[entryStepID]; !ok {
+			return fmt.Errorf("could not find step with id %d", entryStepID)
+		}
+
+		for id := range reachableSteps(adjacencyMap, entryStepID) {
+			pending[id] = true
+		}
+	}
+
+	runStepStatuses, err := service.FindRunStepStatusesByRun(runID)
+
+	if err != nil {
+		return err
+	}
+
+	succeeded := make(map[int]bool)
+
+	for _, runStepStatus := range runStepStatuses {
+		if (runStepStatus.RunStatusID == 4 || runStepStatus.RunStatusID == 9) && !pending[runStepStatus.StepID] {
+			succeeded[runStepStatus.StepID] = true
+		}
+	}
+
+	unsuccessful := make(map[int]bool)
+	maxParallelSteps := service.getMaxParallelSteps(runID)
+	finished := make(chan stepResult)
+	done := ctx.Done()
+	running := 0
+
+	for {
+		if !state.cancelled {
+			stopped := state.hasFailed()
+
+			for skipping := true; skipping; {
+				skipping = false
+
+				for _, id := range sortedStepIDs(pending) {
+					if state.stepConfigs[id].OnFailure == onFailureAlways {
+						continue
+					}
+
+					if stopped || predecessorsUnsuccessful(predecessorMap[id], unsuccessful) {
+						delete(pending, id)
+						unsuccessful[id] = true
+						skipping = true
+						service.skipStep(runID, pipelineGraph, id, runLogger)
+					}
+				}
+			}
+
+			for _, id := range sortedStepIDs(pending) {
+				if running >= maxParallelSteps {
+					break
+				}
+
+				if state.stepConfigs[id].OnFailure == onFailureAlways {
+					if !predecessorsFinished(predecessorMap[id], succeeded, unsuccessful) {
+						continue
+					}
+				} else if !predecessorsSucceeded(predecessorMap[id], succeeded) {
+					continue
+				}
+
+				delete(pending, id)
+				running++
+
+				go func(id int) {
+					runStatusID := uint(3)
+
+					// A panicking step fails on its own instead of taking the worker down along with every run on it
+					defer func() {
+						if recovered := recover(); recovered != nil {
+							service.failPanickedStep(runID, pipelineGraph, id, recovered, runLogger, state)
+						}
+
+						finished <- stepResult{ID: id, RunStatusID: runStatusID}
+					}()
+
+					runStatusID = service.executeStep(ctx, currentPipelineWorkDir, runID, pipelineGraph, id, logFile, runLogger, state)
+				}(id)
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		select {
+		case result := <-finished:
+			running--
+
+			switch result.RunStatusID {
+			case 4, 9:
+				succeeded[result.ID] = true
+			case 3:
+				unsuccessful[result.ID] = true
+			}
+		case <-done:
+			done = nil
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				state.fail(ctx.Err())
+			} else {
+				state.cancelled = true
+			}
+		}
+	}
+
+	if state.cancelled {
+		for _, id := range sortedStepIDs(pending) {
+			service.skipStep(runID, pipelineGraph, id, runLogger)
+		}
+	}
+
+	return nil
+}
+
+// failPanickedStep records the failure of a step whose execution panicked, logging the stack of the panic.
+func (service *runServiceImpl) failPanickedStep(runID uint, pipelineGraph graph.Graph[int, steps.Step], id int, recovered interface{}, runLogger *log.Logger, state *runExecutionState) {
+	step, _ := pipelineGraph.Vertex(id)
+
+	errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "run.service.execute.step.panicked",
+		TemplateData: map[string]interface{}{
+			"ID":     id,
+			"Reason": fmt.Sprint(recovered),
+		},
+		PluralCount: 1,
+	})
+
+	log.Printf("%s\n%s", errMessage, debug.Stack())
+	runLogger.Printf("%s\n%s", errMessage, debug.Stack())
+
+	if state.stepConfigs[id].OnFailure != onFailureContinue {
+		state.fail(errors.New(errMessage))
+	}
+
+	runStepStatuses, err := service.FindRunStepStatusesByRun(runID)
+
+	if err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	for i := range runStepStatuses {
+		if runStepStatuses[i].StepID == id && runStepStatuses[i].RunStatusID == 2 {
+			if err := service.updateStepRunStatus(&runStepStatuses[i], 3, errMessage); err != nil {
+				log.Println(err.Error())
+				runLogger.Println(err.Error())
+			}
+
+			return
+		}
+	}
+
+	// The step panicked before its status was created
+	if err := service.CreateRunStepStatus(runID, id, step.GetName(), 3, errMessage); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+	}
+}
+
+// skipStep records that the step won't be executed in this run.
+func (service *runServiceImpl) skipStep(runID uint, pipelineGraph graph.Graph[int, steps.Step], id int, runLogger *log.Logger) {
+	step, _ := pipelineGraph.Vertex(id)
+
+	msg := fmt.Sprintf("Skipping step %s (%d)", step.GetName(), step.GetID())
+	log.Println(msg)
+	runLogger.Println(msg)
+
+	if err := service.CreateRunStepStatus(runID, id, step.GetName(), 7, ""); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+	}
+}
+
+// findEntrySteps returns the steps a fresh execution starts from, i.e. the root of every connected
+// component of the pipeline along with the steps flagged as first step, whatever their predecessors.
+func (service *runServiceImpl) findEntrySteps(pipelineGraph graph.Graph[int, steps.Step]) ([]int, error) {
+	predecessorMap, err := pipelineGraph.PredecessorMap()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entryStepIDs := entrySteps(pipelineGraph, predecessorMap)
+
+	if len(entryStepIDs) == 0 {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID:   "run.service.execute.entry-step.missing",
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return entryStepIDs, nil
+}
+
+// entrySteps returns the sorted ids of the steps a run starts from: every step without upstream steps and every step
+// flagged as first step. The run executes these and every step reachable from them.
+func entrySteps(pipelineGraph graph.Graph[int, steps.Step], predecessorMap map[int]map[int]graph.Edge[int]) []int {
+	var entryStepIDs []int
+
+	for id, predecessors := range predecessorMap {
+		step, _ := pipelineGraph.Vertex(id)
+
+		if len(predecessors) == 0 || step.GetIsFirstStep() {
+			entryStepIDs = append(entryStepIDs, id)
+		}
+	}
+
+	sort.Ints(entryStepIDs)
+
+	return entryStepIDs
+}
+
+// resolveRunDefinition substitutes the parameter values stored on the run into the pipeline definition.
+func resolveRunDefinition(I18n *i18n.Localizer, run model.Run, definition string) (string, error) {
+	var parameters map[string]interface{}
+
+	if run.Parameters != "" {
+		if err := json.Unmarshal([]byte(run.Parameters), &parameters); err != nil {
+			return "", err
+		}
+	}
+
+	return substitutePipelineParameters(I18n, definition, parameters)
+}
+
+// prepareRunDefinition resolves the parameters and the model references of the definition of the run and stores the
+// result, which every execution of the run, including when it is resumed or executed again, goes through.
+func (service *runServiceImpl) prepareRunDefinition(run model.Run, definition string) (string, error) {
+	definition, err := resolveRunDefinition(service.I18n, run, definition)
+
+	if err != nil {
+		return "", err
+	}
+
+	if definition, err = service.resolveModelReferences(run.PipelineID, definition); err != nil {
+		return "", err
+	}
+
+	if err := service.recordRunDefinition(run.ID, definition); err != nil {
+		return "", err
+	}
+
+	return definition, nil
+}
+
+// recordRunDefinition stores the definition the run executes once it is resolved, so that the run is compared with
+// others and executed again as it ran.
+func (service *runServiceImpl) recordRunDefinition(runID uint, definition string) error {
+	if err := service.RunRepository.UpdateDefinition(runID, definition); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+// resolveModelReferences points the Trained steps that refer to a registered model by name to the latest version of
+// the model in the stage they ask for, production by default, when the run starts. The model is looked up among the
+// models of the pipeline owner. The name is replaced by the version it resolved to, so that the run keeps executing
+// that version when it is resumed or executed again.
+func (service *runServiceImpl) resolveModelReferences(pipelineID uint, definition string) (string, error) {
+	var nodeDescriptions []map[string]interface{}
+
+	if err := json.Unmarshal([]byte(definition), &nodeDescriptions); err != nil {
+		return definition, nil
+	}
+
+	var pipeline *model.Pipeline
+	resolved := false
+
+	for _, nodeDescription := range nodeDescriptions {
+		data, _ := nodeDescription["data"].(map[string]interface{})
+		stepConfig, _ := data["stepConfig"].(map[string]interface{})
+		modelName, _ := stepConfig["modelName"].(string)
+
+		if modelName == "" {
+			continue
+		}
+
+		modelStage, _ := stepConfig["modelStage"].(string)
+
+		if modelStage == "" {
+			modelStage = model.TrainedStageProduction
+		}
+
+		if pipeline == nil {
+			var err error
+
+			if pipeline, err = service.PipelineService.Get(pipelineID); err != nil {
+				return "", err
+			}
+		}
+
+		trained, err := service.TrainedService.GetLatestInStage(pipeline.UserID, modelName, modelStage)
+
+		if err != nil {
+			return "", err
+		}
+
+		stepConfig["trainedID"] = trained.ID
+		stepConfig["trainedName"] = trained.Name
+		// The path is relative to the upload dir, the weights of registered versions are not served publicly
+		stepConfig["trainedPath"] = strings.TrimPrefix(trained.Path, os.Getenv("FILE_UPLOAD_DIR"))
+		delete(stepConfig, "modelName")
+		delete(stepConfig, "modelStage")
+		resolved = true
+	}
+
+	if !resolved {
+		return definition, nil
+	}
+
+	definitionJSON, err := json.Marshal(nodeDescriptions)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(definitionJSON), nil
+}
+
+// runBlockingWarnings are the codes of the diagnostics that only warn when the pipeline is saved but fail a run.
+var runBlockingWarnings = map[string]bool{
+	"unresolved-reference": true,
+}
+
+// validatePipelineDefinition runs the validation of a definition of the pipeline and returns the error diagnostics,
+// and the warnings that block a run, as one error.
+func (service *runServiceImpl) validatePipelineDefinition(definition string, pipelineID uint) error {
+	pipeline, err := service.PipelineService.Get(pipelineID)
+
+	if err != nil {
+		return err
+	}
+
+	var messages []string
+
+	for _, diagnostic := range service.NodeTypeService.ValidatePipeline(definition, "", pipeline.UserID) {
+		if diagnostic.Severity == "error" || runBlockingWarnings[diagnostic.Code] {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "pipeline.validate.failed",
+		TemplateData: map[string]interface{}{
+			"Reason": strings.Join(messages, "; "),
+		},
+		PluralCount: 1,
+	})
+
+	return errors.New(errMessage)
+}
+
+// recordStepArtifacts records every file found under the outputs the step declares, replacing the ones
+// recorded by a previous execution of the step. Since output dirs such as trained_models/ are shared with other
+// steps, only the files in them created or changed since the step started are recorded.
+func (service *runServiceImpl) recordStepArtifacts(currentPipelineWorkDir string, runID uint, step steps.Step, startedAt time.Time, runLogger *log.Logger) {
+	if err := service.RunRepository.DeleteRunArtifactsByStep(runID, step.GetID()); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
+
+	for _, output := range step.GetOutputs() {
+		outputPath := filepath.Join(currentPipelineWorkDir, output.Path)
+
+		if _, err := os.Stat(outputPath); err != nil {
+			if output.Optional {
+				continue
+			}
+
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.execute.step.output.missing",
+				TemplateData: map[string]interface{}{
+					"ID":     step.GetID(),
+					"Output": output.Name,
+					"Path":   output.Path,
+				},
+				PluralCount: 1,
+			})
+
+			log.Println(errMessage)
+			runLogger.Println(errMessage)
+			continue
+		}
+
+		err := filepath.Walk(outputPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
-				runLogger.Println(err.Error())
-				log.Println(err.Error())
-				stepErr = err
-				hasError = true
-				return true
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			if strings.HasSuffix(output.Path, "/") && !modifiedSince(info, startedAt) {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(currentPipelineWorkDir, path)
+
+			if err != nil {
+				return err
+			}
+
+			checksum, err := util.HashFile(path)
+
+			if err != nil {
+				return err
+			}
+
+			return service.RunRepository.CreateRunArtifact(&model.RunArtifact{
+				RunID:    runID,
+				StepID:   step.GetID(),
+				StepName: step.GetName(),
+				Name:     output.Name,
+				Path:     relativePath,
+				Size:     info.Size(),
+				Checksum: checksum,
+			})
+		})
+
+		if err != nil {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.repository.create.artifact.failed",
+				TemplateData: map[string]interface{}{
+					"ID":     step.GetID(),
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			log.Println(errMessage)
+			runLogger.Println(errMessage)
+		}
+	}
+}
+
+// modifiedSince reports whether the file was created or changed since the step started at startedAt. File systems may
+// keep modification times with a precision of a second, so a file changed in the second the step started counts.
+func modifiedSince(info os.FileInfo, startedAt time.Time) bool {
+	return !info.ModTime().Before(startedAt.Truncate(time.Second))
+}
+
+type stepResult struct {
+	ID          int
+	RunStatusID uint
+}
+
+func reachableSteps(adjacencyMap map[int]map[int]graph.Edge[int], entryStepID int) map[int]bool {
+	reachable := map[int]bool{entryStepID: true}
+	queue := []int{entryStepID}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for adjacency := range adjacencyMap[id] {
+			if !reachable[adjacency] {
+				reachable[adjacency] = true
+				queue = append(queue, adjacency)
 			}
+		}
+	}
+
+	return reachable
+}
+
+func predecessorsSucceeded(predecessors map[int]graph.Edge[int], succeeded map[int]bool) bool {
+	for predecessor := range predecessors {
+		if !succeeded[predecessor] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func predecessorsUnsuccessful(predecessors map[int]graph.Edge[int], unsuccessful map[int]bool) bool {
+	for predecessor := range predecessors {
+		if unsuccessful[predecessor] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func predecessorsFinished(predecessors map[int]graph.Edge[int], succeeded map[int]bool, unsuccessful map[int]bool) bool {
+	for predecessor := range predecessors {
+		if !succeeded[predecessor] && !unsuccessful[predecessor] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sortedStepIDs(stepIDs map[int]bool) []int {
+	var ids []int
+
+	for id := range stepIDs {
+		ids = append(ids, id)
+	}
 
-			for _, feedbackQuery := range feedbackQueries {
-				feedbackQuery.QueryStatus = *queryStatus
-				feedbackQuery.QueryStatusID = queryStatus.ID
-				err = service.UpdateHumanFeedbackQuery(&feedbackQuery)
+	sort.Ints(ids)
+
+	return ids
+}
+
+// getMaxParallelSteps returns how many steps of the run may execute at the same time.
+// The run setting takes precedence over RUN_MAX_PARALLEL_STEPS.
+func (service *runServiceImpl) getMaxParallelSteps(runID uint) int {
+	run, err := service.RunRepository.FindByID(runID)
+
+	if err == nil && run.MaxParallelSteps > 0 {
+		return int(run.MaxParallelSteps)
+	}
+
+	if maxParallelSteps, exists := os.LookupEnv("RUN_MAX_PARALLEL_STEPS"); exists {
+		if value, err := strconv.Atoi(maxParallelSteps); err == nil && value > 0 {
+			return value
+		}
+	}
+
+	return defaultMaxParallelSteps
+}
+
+func (service *runServiceImpl) createPipelineGraph(runPipelinePayload RunPipelinePayload) (graph.Graph[int, steps.Step], error) {
+	var stepDescriptions []model.NodeDescription
+
+	if err := json.Unmarshal([]byte(runPipelinePayload.GraphDefinition), &stepDescriptions); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.execute.demarshal.error",
+			TemplateData: map[string]interface{}{
+				"ID":     runPipelinePayload.RunID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errMessage)
+		return nil, errors.New(errMessage)
+	}
+
+	pipelineGraph := graph.New(stepHash, graph.Directed(), graph.Acyclic())
+	var edges []steps.Edge
+	var edgeDescriptions []model.NodeDescription
+
+	for _, stepDescription := range stepDescriptions {
+		if service.NodeTypeService.IsEdgeType(stepDescription.Type) {
+			edge, err := service.NodeTypeService.NewEdgeInstance(runPipelinePayload.PipelineID, runPipelinePayload.RunID, stepDescription.Type, stepDescription)
+
+			if err != nil {
+				return nil, service.graphError(runPipelinePayload.RunID, "run.service.execute.graph.edge.invalid", stepDescription.ID, err)
 			}
 
-			if err != nil {
-				runLogger.Println(err.Error())
-				log.Println(err.Error())
-				stepErr = err
-				hasError = true
-				return true
-			}
-		}
+			edges = append(edges, *edge)
+			edgeDescriptions = append(edgeDescriptions, stepDescription)
+			continue
+		}
+
+		step, err := service.NodeTypeService.NewStepInstance(runPipelinePayload.PipelineID, runPipelinePayload.RunID, stepDescription.Type, stepDescription)
+
+		if err != nil {
+			return nil, service.graphError(runPipelinePayload.RunID, "run.service.execute.graph.step.invalid", stepDescription.ID, err)
+		}
+
+		if err := pipelineGraph.AddVertex(*step); err != nil {
+			return nil, service.graphError(runPipelinePayload.RunID, "run.service.execute.graph.step.invalid", stepDescription.ID, err)
+		}
+	}
+
+	for i, edge := range edges {
+		if err := pipelineGraph.AddEdge(edge.GetTargetID(), edge.GetSourceID()); err != nil {
+			return nil, service.graphError(runPipelinePayload.RunID, "run.service.execute.graph.edge.invalid", edgeDescriptions[i].ID, err)
+		}
+	}
+
+	return pipelineGraph, nil
+}
+
+// graphError localizes and logs a failure to build the graph of a run.
+func (service *runServiceImpl) graphError(runID uint, messageID string, id string, err error) error {
+	errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: messageID,
+		TemplateData: map[string]interface{}{
+			"RunID":  runID,
+			"ID":     id,
+			"Reason": err.Error(),
+		},
+		PluralCount: 1,
+	})
+	log.Println(errMessage)
+	return errors.New(errMessage)
+}
+
+func (service *runServiceImpl) HandleScheduledRunPipelineTask(ctx context.Context, t *asynq.Task) error {
+	var scheduledRunPipelinePayload ScheduledRunPipelinePayload
+	if err := json.Unmarshal(t.Payload(), &scheduledRunPipelinePayload); err != nil {
+		errStr := fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+		log.Println(errStr)
+		return errStr
+	}
+
+	pipelineSchedule, err := service.PipelineService.GetPipelineSchedule(scheduledRunPipelinePayload.PipelineScheduleID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return asynq.SkipRetry
+	}
+
+	pipeline, err := service.PipelineService.Get(scheduledRunPipelinePayload.PipelineID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return asynq.SkipRetry
+	}
+
+	// The next occurrence is scheduled first, so that a run that can't be created doesn't end the schedule
+	if err := service.enqueueNextOccurrence(*pipeline, *pipelineSchedule); err != nil {
+		log.Println(err.Error())
+	}
+
+	run, err := service.Create(*pipeline, model.CreateRunReq{})
+
+	if err != nil {
+		log.Println(err.Error())
+		return asynq.SkipRetry
+	}
+
+	if taskID, ok := asynq.GetTaskID(ctx); ok {
+		queue, _ := asynq.GetQueueName(ctx)
+
+		if err := service.updateRunTaskID(run.ID, taskID, queue); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	definition, err := service.prepareRunDefinition(run, pipeline.Definition)
+
+	if err != nil {
+		log.Println(err.Error())
+
+		if err := service.UpdateRunStatus(run.ID, 3, nil, err.Error()); err != nil {
+			log.Println(err.Error())
+		}
+
+		return asynq.SkipRetry
+	}
+
+	runPipelinePayload := &RunPipelinePayload{
+		PipelineID:      pipeline.ID,
+		RunID:           run.ID,
+		GraphDefinition: definition,
+	}
+
+	return service.executeRunPipelineTask(ctx, *runPipelinePayload)
+}
+
+// enqueueNextOccurrence enqueues the task of the next occurrence of a recurring schedule of the pipeline.
+func (service *runServiceImpl) enqueueNextOccurrence(pipeline model.Pipeline, pipelineSchedule model.PipelineSchedule) error {
+	if pipelineSchedule.CronExpression == "" {
+		return nil
+	}
+
+	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	schedule, parseError := parser.Parse(pipelineSchedule.CronExpression)
+
+	if parseError != nil {
+		return parseError
+	}
+
+	nextExec := schedule.Next(time.Now())
+
+	task, err := NewScheduledRunPipelineTask(pipelineSchedule.PipelineID, pipelineSchedule.ID)
+
+	if err != nil {
+		return err
+	}
+
+	queue := runQueue(pipeline, ScheduledQueue)
+
+	if _, err = service.TaskQueueClient.Enqueue(task, asynq.Queue(queue), asynq.Timeout(runTaskTimeout), asynq.ProcessAt(nextExec)); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "tasks.client.enqueue.failed",
+			TemplateData: map[string]interface{}{
+				"Queue":  queue,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+func (service *runServiceImpl) UpdateRunStatus(runID uint, statusID uint, stepsWaitingFeedback []int, errorMessage string) error {
+	_, err := service.updateRunStatusIn(runID, nil, statusID, stepsWaitingFeedback, errorMessage)
+	return err
+}
 
-		if len(feedbackPayload) == 0 { // it means the execution finalized
+// activeRunStatusIDs are the statuses of a run that has not finished yet.
+var activeRunStatusIDs = []uint{1, 2, 5}
 
-			if _, err := os.Stat(filepath.Join(currentPipelineWorkDir, "trained_models")); !os.IsNotExist(err) {
-				trainedErr := filepath.Walk(filepath.Join(currentPipelineWorkDir, "trained_models"), func(path string, info os.FileInfo, err error) error {
+// updateRunStatusIn updates the status of the run like UpdateRunStatus, but only when its current status is one of
+// fromStatusIDs, unless there are none, and tells whether it did. A worker finishing a run as it is being cancelled
+// thus can't overwrite the cancellation.
+func (service *runServiceImpl) updateRunStatusIn(runID uint, fromStatusIDs []uint, statusID uint, stepsWaitingFeedback []int, errorMessage string) (bool, error) {
+	run, _ := service.RunRepository.FindByID(runID)
+	runStatus, _ := service.RunRepository.GetRunStatusByID(statusID)
+	run.RunStatusID = statusID
+	run.RunStatus = *runStatus
+	run.ErrorMessage = errorMessage
+	run.StepsWaitingFeedback = stepsWaitingFeedback
+	run.LastRun = time.Now()
+	stampExecutionTimes(&run.StartedAt, &run.ResumedAt, &run.FinishedAt, &run.DurationMs, statusID, runStatus.IsFinal)
 
-					if err != nil {
-						return err
-					}
+	updated := true
+	var err error
 
-					if info.IsDir() || filepath.Ext(path) != ".pt" {
-						return nil
-					}
+	if len(fromStatusIDs) == 0 {
+		err = service.RunRepository.Update(run)
+	} else {
+		updated, err = service.RunRepository.UpdateStatusIn(run, fromStatusIDs)
+	}
 
-					pipeline, err := service.PipelineService.Get(step.GetPipelineID())
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.run.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
 
-					if err != nil {
-						return err
-					}
+		return false, errors.New(errMessage)
+	}
 
-					model_name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(filepath.Base(path)))
-					trained, err := service.TrainedService.Create(pipeline.UserID, model_name+"_run"+fmt.Sprint(run.ID))
+	if !updated {
+		return false, nil
+	}
 
-					if err != nil {
-						return err
-					}
+	eventType := model.RunEventRunStatus
 
-					fileUploadDir, exists := os.LookupEnv("FILE_UPLOAD_DIR")
+	if statusID == 5 {
+		eventType = model.RunEventWaitingFeedback
+	} else if runStatus.IsFinal {
+		eventType = model.RunEventRunFinished
+	}
 
-					if !exists {
-						errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "env.variable.find.failed",
-							TemplateData: map[string]interface{}{
-								"Name": "FILE_UPLOAD_DIR",
-							},
-							PluralCount: 1,
-						})
+	if eventType != model.RunEventWaitingFeedback {
+		service.publishRunEvent(model.RunEvent{Type: eventType, RunID: runID, StatusID: statusID, Message: errorMessage})
+	}
 
-						log.Printf(errMessage)
-						return err
-					}
+	for _, stepID := range stepsWaitingFeedback {
+		service.publishRunEvent(model.RunEvent{Type: eventType, RunID: runID, StepID: stepID, StatusID: statusID, Message: errorMessage})
+	}
 
-					modelUploadDir := filepath.Join(filepath.Join(fileUploadDir, "trained"), fmt.Sprint(trained.ID))
-					if err := os.MkdirAll(modelUploadDir, os.ModePerm); err != nil {
-						errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "os.cmd.mkdir.dir.failed",
-							TemplateData: map[string]interface{}{
-								"Path":   modelUploadDir,
-								"Reason": err.Error(),
-							},
-							PluralCount: 1,
-						})
-
-						log.Println(errMessage)
-						return err
-					}
+	return true, nil
+}
 
-					sourceFile, err := os.Open(path)
+// updateRunTaskID stores the id and queue of the asynq task executing the run, so the run can be cancelled later on.
+func (service *runServiceImpl) updateRunTaskID(runID uint, taskID string, taskQueue string) error {
+	run, err := service.RunRepository.FindByID(runID)
 
-					if err != nil {
-						errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "os.cmd.read.dir.failed",
-							TemplateData: map[string]interface{}{
-								"Path":   modelUploadDir,
-								"Reason": err.Error(),
-							},
-							PluralCount: 1,
-						})
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.find.run.id.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
 
-						log.Println(errMessage)
-						return err
-					}
+		return errors.New(errMessage)
+	}
 
-					defer sourceFile.Close()
+	run.TaskID = taskID
+	run.TaskQueue = taskQueue
 
-					filePath := filepath.Join(modelUploadDir, filepath.Base(path))
-					datasetFileDestination, err := os.Create(filePath)
+	return service.Update(run)
+}
 
-					if err != nil {
-						errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "os.cmd.read.dir.failed",
-							TemplateData: map[string]interface{}{
-								"Path":   modelUploadDir,
-								"Reason": err.Error(),
-							},
-							PluralCount: 1,
-						})
+// getRunTaskQueue returns the queue of the run task. Runs enqueued before the queues were split have none stored.
+func getRunTaskQueue(run model.Run) string {
+	if run.TaskQueue == "" {
+		return legacyRunsQueue
+	}
 
-						log.Println(errMessage)
-						return err
-					}
+	return run.TaskQueue
+}
 
-					defer datasetFileDestination.Close()
+// isRunCancelled tells whether the run was cancelled before its task was picked up by the worker.
+func (service *runServiceImpl) isRunCancelled(runID uint) bool {
+	run, err := service.RunRepository.FindByID(runID)
 
-					_, err = io.Copy(datasetFileDestination, sourceFile)
+	return err == nil && run.RunStatusID == 6
+}
 
-					if err != nil {
-						errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "os.cmd.copy.dir.failed",
-							TemplateData: map[string]interface{}{
-								"Path":   modelUploadDir,
-								"Reason": err.Error(),
-							},
-							PluralCount: 1,
-						})
+func (service *runServiceImpl) updateStepRunStatus(runStepStatus *model.RunStepStatus, statusID uint, errorMessage string) error {
+	runStatus, _ := service.RunRepository.GetRunStatusByID(statusID)
+	runStepStatus.RunStatusID = statusID
+	runStepStatus.RunStatus = *runStatus
+	runStepStatus.ErrorMessage = errorMessage
+	runStepStatus.LastRun = time.Now()
+	stampExecutionTimes(&runStepStatus.StartedAt, &runStepStatus.ResumedAt, &runStepStatus.FinishedAt, &runStepStatus.DurationMs, statusID, runStatus.IsFinal)
 
-						log.Println(errMessage)
-						return err
-					}
+	if err := service.RunRepository.UpdateRunStepStatus(runStepStatus); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.update.step-status.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runStepStatus.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
 
-					trained.Path = filePath
-					err = service.TrainedService.Update(trained)
+		return errors.New(errMessage)
+	}
 
-					if err != nil {
-						return err
-					}
+	service.publishStepEvent(*runStepStatus)
 
-					return nil
-				})
+	return nil
+}
 
-				if trainedErr != nil {
-					runLogger.Print("Error creating trained models: " + trainedErr.Error())
-				}
-			}
+// stampExecutionTimes starts the clock of a run or step when it starts executing, unless it is resumed after waiting
+// for feedback, and stops it once it reaches a final status. The clock is paused while waiting for feedback, so the
+// duration only adds up the time spent executing since resumedAt.
+func stampExecutionTimes(startedAt *null.Time, resumedAt *null.Time, finishedAt *null.Time, durationMs *int64, statusID uint, isFinal bool) {
+	now := time.Now()
+
+	if statusID == 2 {
+		if !startedAt.Valid || finishedAt.Valid {
+			*startedAt = null.TimeFrom(now)
+			*resumedAt = null.Time{}
+			*finishedAt = null.Time{}
+			*durationMs = 0
 		}
 
-		if hasFeedback {
-			executeError = service.updateStepRunStatus(runStepStatus, 5, "") // waiting feedback
-		} else {
-			executeError = service.updateStepRunStatus(runStepStatus, 4, "") // success
+		if !resumedAt.Valid {
+			*resumedAt = null.TimeFrom(now)
 		}
+	}
 
-		if executeError != nil {
-			runLogger.Println(executeError.Error())
-			log.Println(executeError.Error())
-			stepErr = executeError
-			hasError = true
-			return true
-		}
+	if (statusID == 5 || isFinal) && resumedAt.Valid {
+		*durationMs += now.Sub(resumedAt.Time).Milliseconds()
+		*resumedAt = null.Time{}
+	}
 
-		if hasFeedback {
-			stepWaitingFeedback = step.GetID()
-			return true
+	if isFinal {
+		if !startedAt.Valid {
+			*startedAt = null.TimeFrom(now)
 		}
 
-		return false
+		*finishedAt = null.TimeFrom(now)
+	}
+}
+
+// recordStepMetric stores a metric reported by a step while it runs.
+func (service *runServiceImpl) recordStepMetric(runID uint, step steps.Step, metric steps.StepMetric, runLogger *log.Logger) {
+	runMetric := &model.RunMetric{
+		RunID:     runID,
+		StepID:    step.GetID(),
+		StepName:  step.GetName(),
+		Name:      metric.Name,
+		Value:     metric.Value,
+		Iteration: metric.Iteration,
+		Time:      metric.Time,
+	}
+
+	if err := service.RunRepository.CreateRunMetric(runMetric); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.create.metric.failed",
+			TemplateData: map[string]interface{}{
+				"Name":   metric.Name,
+				"ID":     step.GetID(),
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		log.Println(errMessage)
+		runLogger.Println(errMessage)
+	}
+}
+
+// recordStepTestResults stores the test results of a step that declares its test results file as an output, replacing
+// the ones of a previous execution of the step. The metrics of the results are stored as metrics of the step too.
+func (service *runServiceImpl) recordStepTestResults(currentPipelineWorkDir string, runID uint, step steps.Step, runLogger *log.Logger) {
+	testResultsOutputs := util.Filter(step.GetOutputs(), func(output steps.StepFile) bool {
+		return output.Path == steps.TestResultsPath(step.GetID())
 	})
 
-	if hasError {
-		if err := service.UpdateRunStatus(runID, 3, 0, msg); err != nil {
-			log.Println(err.Error())
-			runLogger.Println(err.Error())
-		}
+	if len(testResultsOutputs) == 0 {
+		return
+	}
+
+	if err := service.RunRepository.DeleteRunTestResultsByStep(runID, step.GetID()); err != nil {
+		log.Println(err.Error())
+		runLogger.Println(err.Error())
+		return
+	}
 
+	testResultsPath := filepath.Join(currentPipelineWorkDir, steps.TestResultsPath(step.GetID()))
+	testResults, err := steps.ReadTestResults(testResultsPath)
+
+	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.service.execute.run.failed",
+			MessageID: "run.service.execute.step.test-results.invalid",
 			TemplateData: map[string]interface{}{
-				"ID":     runID,
-				"Reason": stepErr.Error(),
+				"ID":     step.GetID(),
+				"Path":   testResultsPath,
+				"Reason": err.Error(),
 			},
 			PluralCount: 1,
 		})
 
 		log.Println(errMessage)
 		runLogger.Println(errMessage)
-	} else {
-		runStatusID := 4
-		if hasFeedback {
-			runStatusID = 5
-		}
-		if err := service.UpdateRunStatus(runID, uint(runStatusID), stepWaitingFeedback, ""); err != nil {
-			log.Println(err.Error())
-			runLogger.Println(err.Error())
-		}
+		return
+	}
+
+	if testResults == nil {
+		return
+	}
+
+	predictions := testResults.Predictions
+	testResults.Predictions = nil
 
+	resultsJSON, _ := json.Marshal(testResults)
+	runTestResult := &model.RunTestResult{
+		RunID:           runID,
+		StepID:          step.GetID(),
+		StepName:        step.GetName(),
+		Results:         string(resultsJSON),
+		PredictionCount: len(predictions),
+	}
+
+	if len(predictions) > 0 {
+		predictionsJSON, _ := json.Marshal(predictions)
+		runTestResult.Predictions = string(predictionsJSON)
+	}
+
+	if err := service.RunRepository.CreateRunTestResult(runTestResult); err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.service.execute.run.success",
+			MessageID: "run.repository.create.test-results.failed",
 			TemplateData: map[string]interface{}{
-				"ID": runID,
+				"ID":     step.GetID(),
+				"Reason": err.Error(),
 			},
 			PluralCount: 1,
 		})
 
 		log.Println(errMessage)
 		runLogger.Println(errMessage)
+		return
 	}
 
-	logFile.Close()
+	names := make([]string, 0, len(testResults.Metrics))
 
-	return asynq.SkipRetry
+	for name := range testResults.Metrics {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		service.recordStepMetric(runID, step, steps.StepMetric{Name: name, Value: testResults.Metrics[name], Time: runTestResult.CreatedAt}, runLogger)
+	}
 }
 
-func (service *runServiceImpl) createPipelineGraph(runPipelinePayload RunPipelinePayload) (graph.Graph[int, steps.Step], error) {
-	var stepDescriptions []model.NodeDescription
+// FindRunTestResults returns the test results of the steps of a run, with the predictions of every sample when
+// withPredictions is set.
+func (service *runServiceImpl) FindRunTestResults(runID uint, withPredictions bool) ([]model.StepTestResults, error) {
+	runTestResults, err := service.RunRepository.FindRunTestResultsByRun(runID)
 
-	if err := json.Unmarshal([]byte(runPipelinePayload.GraphDefinition), &stepDescriptions); err != nil {
+	if err != nil {
 		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.service.execute.demarshal.error",
+			MessageID: "run.repository.find.test-results.run.failed",
 			TemplateData: map[string]interface{}{
-				"ID":     runPipelinePayload.RunID,
+				"ID":     runID,
 				"Reason": err.Error(),
 			},
 			PluralCount: 1,
 		})
-		log.Printf(errMessage)
+
 		return nil, errors.New(errMessage)
 	}
 
-	pipelineGraph := graph.New(stepHash, graph.Directed(), graph.Acyclic())
+	return stepTestResults(runTestResults, withPredictions)
+}
 
-	stps := util.Map(stepDescriptions, func(stepDescription model.NodeDescription) steps.Step {
-		step, _ := service.NodeTypeService.NewStepInstance(runPipelinePayload.PipelineID, runPipelinePayload.RunID, stepDescription.Type, stepDescription)
+// FindPipelineTestResults returns the test results of all the runs of a pipeline, oldest first, to follow how the
+// tests of the pipeline evolve. Predictions are left out.
+func (service *runServiceImpl) FindPipelineTestResults(pipelineID uint) ([]model.StepTestResults, error) {
+	runTestResults, err := service.RunRepository.FindRunTestResultsByPipeline(pipelineID)
 
-		if step != nil {
-			return *step
-		}
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.find.test-results.pipeline.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     pipelineID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
 
-		return nil
-	})
+		return nil, errors.New(errMessage)
+	}
 
-	edgs := util.Map(stepDescriptions, func(stepDescription model.NodeDescription) steps.Edge {
-		edge, _ := service.NodeTypeService.NewEdgeInstance(runPipelinePayload.PipelineID, runPipelinePayload.RunID, stepDescription.Type, stepDescription)
+	return stepTestResults(runTestResults, false)
+}
 
-		if edge != nil {
-			return *edge
+func stepTestResults(runTestResults []model.RunTestResult, withPredictions bool) ([]model.StepTestResults, error) {
+	results := []model.StepTestResults{}
+
+	for _, runTestResult := range runTestResults {
+		result := model.StepTestResults{
+			ID:              runTestResult.ID,
+			RunID:           runTestResult.RunID,
+			StepID:          runTestResult.StepID,
+			StepName:        runTestResult.StepName,
+			CreatedAt:       runTestResult.CreatedAt,
+			PredictionCount: runTestResult.PredictionCount,
 		}
 
-		return nil
-	})
-
-	stps = util.Filter(stps, func(step steps.Step) bool {
-		return step != nil
-	})
+		if err := json.Unmarshal([]byte(runTestResult.Results), &result.Results); err != nil {
+			return nil, err
+		}
 
-	edgs = util.Filter(edgs, func(edge steps.Edge) bool {
-		return edge != nil
-	})
+		if withPredictions && runTestResult.Predictions != "" {
+			if err := json.Unmarshal([]byte(runTestResult.Predictions), &result.Results.Predictions); err != nil {
+				return nil, err
+			}
+		}
 
-	for _, step := range stps {
-		pipelineGraph.AddVertex(step)
+		results = append(results, result)
 	}
 
-	for _, edge := range edgs {
-		pipelineGraph.AddEdge(edge.GetTargetID(), edge.GetSourceID())
-	}
+	return results, nil
+}
 
-	return pipelineGraph, nil
+// processUsage is the resource usage of the processes an execution of a step ran. CPU times add up over all
+// processes, while maxRSS, in bytes, is the largest resident set of any of them and exitCode is the exit code of the
+// last one.
+type processUsage struct {
+	processes  int
+	userTime   time.Duration
+	systemTime time.Duration
+	maxRSS     int64
+	exitCode   int
+	mutex      sync.Mutex
 }
 
-func (service *runServiceImpl) HandleScheduledRunPipelineTask(ctx context.Context, t *asynq.Task) error {
-	var scheduledRunPipelinePayload ScheduledRunPipelinePayload
-	if err := json.Unmarshal(t.Payload(), &scheduledRunPipelinePayload); err != nil {
-		errStr := fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-		log.Println(errStr)
-		return errStr
-	}
+// add accounts for a process of the step that exited.
+func (usage *processUsage) add(state *os.ProcessState) {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
 
-	pipelineSchedule, err := service.PipelineService.GetPipelineSchedule(scheduledRunPipelinePayload.PipelineScheduleID)
+	usage.processes++
+	usage.userTime += state.UserTime()
+	usage.systemTime += state.SystemTime()
+	usage.exitCode = state.ExitCode()
 
-	if err != nil {
-		log.Println(err.Error())
-		return asynq.SkipRetry
+	// Maxrss is in kilobytes on Linux
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok && rusage.Maxrss*1024 > usage.maxRSS {
+		usage.maxRSS = rusage.Maxrss * 1024
 	}
+}
 
-	pipeline, err := service.PipelineService.Get(scheduledRunPipelinePayload.PipelineID)
+// record stores the usage in the status of the step, which is saved with its next status.
+func (usage *processUsage) record(runStepStatus *model.RunStepStatus) {
+	usage.mutex.Lock()
+	defer usage.mutex.Unlock()
 
-	if err != nil {
-		log.Println(err.Error())
-		return asynq.SkipRetry
+	if usage.processes == 0 {
+		return
 	}
 
-	run, err := service.Create(*pipeline)
+	runStepStatus.CPUUserMs = usage.userTime.Milliseconds()
+	runStepStatus.CPUSystemMs = usage.systemTime.Milliseconds()
+	runStepStatus.MaxRSS = usage.maxRSS
+	runStepStatus.ExitCode = null.IntFrom(int64(usage.exitCode))
+}
+
+// Timeline returns when the steps of the run executed, ordered by start, with the resources they used.
+func (service *runServiceImpl) Timeline(runID uint) (model.RunTimeline, error) {
+	run, err := service.Get(runID)
 
 	if err != nil {
-		log.Println(err.Error())
-		return asynq.SkipRetry
+		return model.RunTimeline{}, err
 	}
 
-	if pipelineSchedule.CronExpression != "" {
-		parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-		schedule, parseError := parser.Parse(pipelineSchedule.CronExpression)
+	runStepStatuses, err := service.FindRunStepStatusesByRun(runID)
 
-		if parseError != nil {
-			return parseError
+	if err != nil {
+		return model.RunTimeline{}, err
+	}
+
+	timeline := model.RunTimeline{
+		RunID:      run.ID,
+		StatusID:   run.RunStatusID,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		DurationMs: run.DurationMs,
+		Steps:      []model.RunTimelineStep{},
+	}
+
+	// A run still executing is drawn up to now, without the time it waited for feedback
+	if run.ResumedAt.Valid && !run.FinishedAt.Valid {
+		timeline.DurationMs += time.Since(run.ResumedAt.Time).Milliseconds()
+	}
+
+	for _, runStepStatus := range runStepStatuses {
+		timelineStep := model.RunTimelineStep{
+			StepID:      runStepStatus.StepID,
+			Name:        runStepStatus.Name,
+			StatusID:    runStepStatus.RunStatusID,
+			StartedAt:   runStepStatus.StartedAt,
+			FinishedAt:  runStepStatus.FinishedAt,
+			DurationMs:  runStepStatus.DurationMs,
+			CPUUserMs:   runStepStatus.CPUUserMs,
+			CPUSystemMs: runStepStatus.CPUSystemMs,
+			MaxRSS:      runStepStatus.MaxRSS,
+			ExitCode:    runStepStatus.ExitCode,
+			Attempts:    runStepStatus.Attempts,
 		}
 
-		nextExec := schedule.Next(time.Now())
-
-		task, err := NewScheduledRunPipelineTask(pipelineSchedule.PipelineID, pipelineSchedule.ID)
-
-		if err != nil {
-			return err
+		// Steps still executing are drawn up to now
+		if runStepStatus.ResumedAt.Valid && !runStepStatus.FinishedAt.Valid && runStepStatus.RunStatusID == 2 {
+			timelineStep.DurationMs += time.Since(runStepStatus.ResumedAt.Time).Milliseconds()
 		}
 
-		if _, err = service.TaskQueueClient.Enqueue(task, asynq.Queue("runs"), asynq.Timeout(0), asynq.ProcessAt(nextExec)); err != nil {
-			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "tasks.client.enqueue.failed",
-				TemplateData: map[string]interface{}{
-					"Queue":  "runs",
-					"Reason": err.Error(),
-				},
-				PluralCount: 1,
-			})
-
-			return errors.New(errMessage)
+		if run.StartedAt.Valid && runStepStatus.StartedAt.Valid {
+			timelineStep.OffsetMs = runStepStatus.StartedAt.Time.Sub(run.StartedAt.Time).Milliseconds()
 		}
-	}
-
-	runPipelinePayload := &RunPipelinePayload{
-		PipelineID:      pipeline.ID,
-		RunID:           run.ID,
-		GraphDefinition: pipeline.Definition,
-	}
-
-	return service.executeRunPipelineTask(*runPipelinePayload)
-}
 
-func (service *runServiceImpl) UpdateRunStatus(runID uint, statusID uint, stepWaitingFeedback int, errorMessage string) error {
-	run, _ := service.RunRepository.FindByID(runID)
-	runStatus, _ := service.RunRepository.GetRunStatusByID(statusID)
-	run.RunStatusID = statusID
-	run.RunStatus = *runStatus
-	run.ErrorMessage = errorMessage
-	run.StepWaitingFeedback = stepWaitingFeedback
-	run.LastRun = time.Now()
+		timeline.CPUUserMs += runStepStatus.CPUUserMs
+		timeline.CPUSystemMs += runStepStatus.CPUSystemMs
 
-	if err := service.RunRepository.Update(run); err != nil {
-		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.update.run.failed",
-			TemplateData: map[string]interface{}{
-				"ID":     runID,
-				"Reason": err.Error(),
-			},
-			PluralCount: 1,
-		})
+		if runStepStatus.MaxRSS > timeline.MaxRSS {
+			timeline.MaxRSS = runStepStatus.MaxRSS
+		}
 
-		return errors.New(errMessage)
+		timeline.Steps = append(timeline.Steps, timelineStep)
 	}
 
-	return nil
-}
+	sort.SliceStable(timeline.Steps, func(i, j int) bool {
+		first, second := timeline.Steps[i], timeline.Steps[j]
 
-func (service *runServiceImpl) updateStepRunStatus(runStepStatus *model.RunStepStatus, statusID uint, errorMessage string) error {
-	runStatus, _ := service.RunRepository.GetRunStatusByID(statusID)
-	runStepStatus.RunStatusID = statusID
-	runStepStatus.RunStatus = *runStatus
-	runStepStatus.ErrorMessage = errorMessage
-	runStepStatus.LastRun = time.Now()
+		if first.StartedAt.Valid != second.StartedAt.Valid {
+			return first.StartedAt.Valid
+		}
 
-	if err := service.RunRepository.UpdateRunStepStatus(runStepStatus); err != nil {
-		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "run.repository.update.step-status.failed",
-			TemplateData: map[string]interface{}{
-				"ID":     runStepStatus.ID,
-				"Reason": err.Error(),
-			},
-			PluralCount: 1,
-		})
+		if !first.StartedAt.Time.Equal(second.StartedAt.Time) {
+			return first.StartedAt.Time.Before(second.StartedAt.Time)
+		}
 
-		return errors.New(errMessage)
-	}
+		return first.StepID < second.StepID
+	})
 
-	return nil
+	return timeline, nil
 }
@@ -0,0 +1,970 @@
+package service
+
+import (
+	"context"
+	"di/model"
+	"di/repository"
+	"di/steps"
+	"di/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dominikbraun/graph"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// fakeRunRepository keeps in memory the run and the step statuses the engine reads and writes while scheduling steps.
+// The methods it does not implement panic through the nil embedded interface.
+type fakeRunRepository struct {
+	repository.RunRepository
+	mutex           sync.Mutex
+	run             model.Run
+	runStepStatuses []model.RunStepStatus
+	runArtifacts    []model.RunArtifact
+	runTestResults  []model.RunTestResult
+}
+
+func (fake *fakeRunRepository) FindByID(runID uint) (*model.Run, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	run := fake.run
+	return &run, nil
+}
+
+func (fake *fakeRunRepository) FindRunStepStatusesByRun(runID uint) ([]model.RunStepStatus, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return append([]model.RunStepStatus(nil), fake.runStepStatuses...), nil
+}
+
+func (fake *fakeRunRepository) CreateRunStepStatus(runStepStatus *model.RunStepStatus) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	runStepStatus.ID = uint(len(fake.runStepStatuses) + 1)
+	fake.runStepStatuses = append(fake.runStepStatuses, *runStepStatus)
+	return nil
+}
+
+func (fake *fakeRunRepository) UpdateRunStepStatus(runStepStatus *model.RunStepStatus) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	for i := range fake.runStepStatuses {
+		if fake.runStepStatuses[i].ID == runStepStatus.ID {
+			fake.runStepStatuses[i] = *runStepStatus
+			return nil
+		}
+	}
+
+	return errors.New("step status not found")
+}
+
+func (fake *fakeRunRepository) GetRunStatusByID(runStatusID uint) (*model.RunStatus, error) {
+	return &model.RunStatus{IsFinal: runStatusID != 1 && runStatusID != 2 && runStatusID != 5}, nil
+}
+
+func (fake *fakeRunRepository) CreateRunStepAttempt(runStepAttempt *model.RunStepAttempt) error {
+	return nil
+}
+
+func (fake *fakeRunRepository) CreateHumanFeedbackQuery(humanFeedbackQuery *model.HumanFeedbackQuery) error {
+	return nil
+}
+
+func (fake *fakeRunRepository) FindHumanFeedbackQueryStatusByID(queryStatusID uint) (*model.QueryStatus, error) {
+	return &model.QueryStatus{}, nil
+}
+
+func (fake *fakeRunRepository) DeleteRunMetricsByStep(runID uint, stepID int) error {
+	return nil
+}
+
+func (fake *fakeRunRepository) DeleteRunArtifactsByStep(runID uint, stepID int) error {
+	return nil
+}
+
+func (fake *fakeRunRepository) CreateRunArtifact(runArtifact *model.RunArtifact) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.runArtifacts = append(fake.runArtifacts, *runArtifact)
+	return nil
+}
+
+func (fake *fakeRunRepository) FindRunArtifactsByRun(runID uint) ([]model.RunArtifact, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return util.Filter(fake.runArtifacts, func(runArtifact model.RunArtifact) bool {
+		return runArtifact.RunID == runID
+	}), nil
+}
+
+func (fake *fakeRunRepository) FindRunMetricsByRun(runID uint) ([]model.RunMetric, error) {
+	return nil, nil
+}
+
+func (fake *fakeRunRepository) CreateRunMetric(runMetric *model.RunMetric) error {
+	return nil
+}
+
+func (fake *fakeRunRepository) DeleteRunTestResultsByStep(runID uint, stepID int) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.runTestResults = util.Filter(fake.runTestResults, func(runTestResult model.RunTestResult) bool {
+		return runTestResult.RunID != runID || runTestResult.StepID != stepID
+	})
+	return nil
+}
+
+func (fake *fakeRunRepository) CreateRunTestResult(runTestResult *model.RunTestResult) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.runTestResults = append(fake.runTestResults, *runTestResult)
+	return nil
+}
+
+// stepStatuses returns the last status recorded for every step.
+func (fake *fakeRunRepository) stepStatuses() map[int]uint {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	statuses := make(map[int]uint)
+
+	for _, runStepStatus := range fake.runStepStatuses {
+		statuses[runStepStatus.StepID] = runStepStatus.RunStatusID
+	}
+
+	return statuses
+}
+
+// fakeStep is a step that fails, panics or asks for feedback as told, recording when it was executed.
+type fakeStep struct {
+	id        int
+	firstStep bool
+	fails     bool
+	panics    bool
+	feedback  bool
+	outputs   []steps.StepFile
+	executed  *[]int
+	mutex     *sync.Mutex
+}
+
+func (step *fakeStep) GetID() int                          { return step.id }
+func (step *fakeStep) GetName() string                     { return "step" }
+func (step *fakeStep) SetData(model.NodeDescription) error { return nil }
+func (step *fakeStep) SetPipelineID(uint) error            { return nil }
+func (step *fakeStep) SetRunID(uint) error                 { return nil }
+func (step *fakeStep) GetPipelineID() uint                 { return 1 }
+func (step *fakeStep) GetRunID() uint                      { return 1 }
+func (step *fakeStep) GetIsFirstStep() bool                { return step.firstStep }
+func (step *fakeStep) GetIsStaggered() bool                { return step.feedback }
+func (step *fakeStep) GetInputs() []steps.StepFile         { return nil }
+func (step *fakeStep) GetOutputs() []steps.StepFile        { return step.outputs }
+
+func (step *fakeStep) Execute(ctx context.Context, stepLog *steps.StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+	step.mutex.Lock()
+	*step.executed = append(*step.executed, step.id)
+	step.mutex.Unlock()
+
+	if step.panics {
+		panic("step panicked")
+	}
+
+	if step.fails {
+		return nil, errors.New("step failed")
+	}
+
+	if step.feedback {
+		return []model.HumanFeedbackQueryPayload{{Epoch: 1, RunID: 1, StepID: step.id, QueryID: 1}}, nil
+	}
+
+	return nil, nil
+}
+
+func newTestLocalizer(t *testing.T) *i18n.Localizer {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("toml", toml.Unmarshal)
+
+	if _, err := bundle.LoadMessageFile("../i18n/en.toml"); err != nil {
+		t.Fatal(err)
+	}
+
+	return i18n.NewLocalizer(bundle, language.English.String())
+}
+
+// newTestGraph builds a pipeline graph of fake steps, where edges go from a step to its successor.
+func newTestGraph(t *testing.T, stps []*fakeStep, edges [][2]int) graph.Graph[int, steps.Step] {
+	pipelineGraph := graph.New(stepHash, graph.Directed(), graph.Acyclic())
+
+	for _, step := range stps {
+		if err := pipelineGraph.AddVertex(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, edge := range edges {
+		if err := pipelineGraph.AddEdge(edge[0], edge[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return pipelineGraph
+}
+
+func TestScheduleSteps(t *testing.T) {
+	tests := []struct {
+		name          string
+		steps         []fakeStep
+		edges         [][2]int
+		onFailure     map[int]string
+		wantStatuses  map[int]uint
+		wantFailed    bool
+		wantExecuted  [][2]int // pairs of steps where the first must execute before the second
+		wantWaitSteps []int
+	}{
+		{
+			name:         "chain succeeds in order",
+			steps:        []fakeStep{{id: 1}, {id: 2}, {id: 3}},
+			edges:        [][2]int{{1, 2}, {2, 3}},
+			wantStatuses: map[int]uint{1: 4, 2: 4, 3: 4},
+			wantExecuted: [][2]int{{1, 2}, {2, 3}},
+		},
+		{
+			name:         "diamond joins after both branches",
+			steps:        []fakeStep{{id: 1}, {id: 2}, {id: 3}, {id: 4}},
+			edges:        [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}},
+			wantStatuses: map[int]uint{1: 4, 2: 4, 3: 4, 4: 4},
+			wantExecuted: [][2]int{{1, 2}, {1, 3}, {2, 4}, {3, 4}},
+		},
+		{
+			name:         "failure skips descendants",
+			steps:        []fakeStep{{id: 1}, {id: 2, fails: true}, {id: 3}},
+			edges:        [][2]int{{1, 2}, {2, 3}},
+			wantStatuses: map[int]uint{1: 4, 2: 3, 3: 7},
+			wantFailed:   true,
+		},
+		{
+			name:         "continue policy only skips descendants",
+			steps:        []fakeStep{{id: 1, fails: true}, {id: 2}, {id: 3}},
+			edges:        [][2]int{{1, 2}},
+			onFailure:    map[int]string{1: onFailureContinue},
+			wantStatuses: map[int]uint{1: 3, 2: 7, 3: 4},
+		},
+		{
+			name:         "always policy runs after a failure",
+			steps:        []fakeStep{{id: 1, fails: true}, {id: 2}},
+			edges:        [][2]int{{1, 2}},
+			onFailure:    map[int]string{2: onFailureAlways},
+			wantStatuses: map[int]uint{1: 3, 2: 4},
+			wantFailed:   true,
+		},
+		{
+			name:         "panic fails the step",
+			steps:        []fakeStep{{id: 1, panics: true}, {id: 2}},
+			edges:        [][2]int{{1, 2}},
+			wantStatuses: map[int]uint{1: 3, 2: 7},
+			wantFailed:   true,
+		},
+		{
+			name:          "every step waiting for feedback is kept",
+			steps:         []fakeStep{{id: 1}, {id: 2, feedback: true}, {id: 3, feedback: true}, {id: 4}},
+			edges:         [][2]int{{1, 2}, {1, 3}, {2, 4}},
+			wantStatuses:  map[int]uint{1: 4, 2: 5, 3: 5},
+			wantWaitSteps: []int{2, 3},
+		},
+	}
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runRepository := &fakeRunRepository{run: model.Run{MaxParallelSteps: 2}}
+			runRepository.run.ID = 1
+			service := &runServiceImpl{RunRepository: runRepository, I18n: newTestLocalizer(t)}
+
+			var executed []int
+			var mutex sync.Mutex
+			var stps []*fakeStep
+			stepConfigs := make(map[int]stepExecutionConfig)
+
+			for i := range test.steps {
+				step := test.steps[i]
+				step.executed = &executed
+				step.mutex = &mutex
+				stps = append(stps, &step)
+				stepConfigs[step.id] = stepExecutionConfig{OnFailure: test.onFailure[step.id]}
+			}
+
+			pipelineGraph := newTestGraph(t, stps, test.edges)
+			workDir := t.TempDir()
+
+			logFile, err := os.Create(filepath.Join(workDir, "run.log"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer logFile.Close()
+
+			runLogger := log.New(io.Discard, "", 0)
+			state := &runExecutionState{stepConfigs: stepConfigs, runLog: io.Discard}
+
+			entryStepIDs, err := service.findEntrySteps(pipelineGraph)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := service.scheduleSteps(context.Background(), workDir, 1, pipelineGraph, entryStepIDs, logFile, runLogger, state); err != nil {
+				t.Fatal(err)
+			}
+
+			statuses := runRepository.stepStatuses()
+
+			for id, wantStatus := range test.wantStatuses {
+				if statuses[id] != wantStatus {
+					t.Errorf("step %d: got status %d, want %d", id, statuses[id], wantStatus)
+				}
+			}
+
+			if _, ok := test.wantStatuses[4]; !ok && len(test.wantWaitSteps) > 0 {
+				if _, executed := statuses[4]; executed {
+					t.Errorf("step 4 executed before the feedback of its predecessor")
+				}
+			}
+
+			if state.hasFailed() != test.wantFailed {
+				t.Errorf("got failed %v, want %v", state.hasFailed(), test.wantFailed)
+			}
+
+			order := make(map[int]int)
+
+			for i, id := range executed {
+				order[id] = i
+			}
+
+			for _, pair := range test.wantExecuted {
+				if order[pair[0]] >= order[pair[1]] {
+					t.Errorf("step %d executed before step %d: %v", pair[1], pair[0], executed)
+				}
+			}
+
+			waitSteps := service.findStepsWaitingFeedback(1)
+			sort.Ints(waitSteps)
+
+			if len(waitSteps) != len(test.wantWaitSteps) {
+				t.Fatalf("got steps waiting feedback %v, want %v", waitSteps, test.wantWaitSteps)
+			}
+
+			for i := range waitSteps {
+				if waitSteps[i] != test.wantWaitSteps[i] {
+					t.Errorf("got steps waiting feedback %v, want %v", waitSteps, test.wantWaitSteps)
+				}
+			}
+		})
+	}
+}
+
+func TestFindEntrySteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []fakeStep
+		edges   [][2]int
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:  "single root",
+			steps: []fakeStep{{id: 1}, {id: 2}, {id: 3}},
+			edges: [][2]int{{1, 2}, {2, 3}},
+			want:  []int{1},
+		},
+		{
+			name:  "root of every component",
+			steps: []fakeStep{{id: 1}, {id: 2}, {id: 3}, {id: 4}},
+			edges: [][2]int{{1, 2}, {3, 4}},
+			want:  []int{1, 3},
+		},
+		{
+			name:  "flagged root",
+			steps: []fakeStep{{id: 1, firstStep: true}, {id: 2}},
+			edges: [][2]int{{1, 2}},
+			want:  []int{1},
+		},
+		{
+			name:  "flagged step with predecessors is a root too",
+			steps: []fakeStep{{id: 1}, {id: 2, firstStep: true}, {id: 3}},
+			edges: [][2]int{{1, 2}, {2, 3}},
+			want:  []int{1, 2},
+		},
+		{
+			name:    "no step",
+			wantErr: true,
+		},
+	}
+
+	service := &runServiceImpl{I18n: newTestLocalizer(t)}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stps []*fakeStep
+
+			for i := range test.steps {
+				stps = append(stps, &test.steps[i])
+			}
+
+			got, err := service.findEntrySteps(newTestGraph(t, stps, test.edges))
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePipelineGraph(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    bool
+	}{
+		{
+			name:       "steps and edge",
+			definition: `[{"id": "1", "type": "shellScript", "data": {"id": "1"}}, {"id": "2", "type": "shellScript", "data": {"id": "2"}}, {"id": "e1-2", "type": "smoothstep", "source": "1", "target": "2"}]`,
+		},
+		{
+			name:       "unknown type",
+			definition: `[{"id": "1", "type": "unknown", "data": {"id": "1"}}]`,
+			wantErr:    true,
+		},
+		{
+			name:       "duplicate step",
+			definition: `[{"id": "1", "type": "shellScript", "data": {"id": "1"}}, {"id": "2", "type": "shellScript", "data": {"id": "1"}}]`,
+			wantErr:    true,
+		},
+		{
+			name:       "edge to a missing step",
+			definition: `[{"id": "1", "type": "shellScript", "data": {"id": "1"}}, {"id": "e1-2", "type": "smoothstep", "source": "1", "target": "2"}]`,
+			wantErr:    true,
+		},
+	}
+
+	localizer := newTestLocalizer(t)
+	service := &runServiceImpl{
+		I18n: localizer,
+		NodeTypeService: &nodeServiceImpl{
+			I18n:             localizer,
+			StepTypeRegistry: initStepTypeRegistry(),
+			EdgeTypeRegistry: initEdgeTypeRegistry(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := service.createPipelineGraph(RunPipelinePayload{PipelineID: 1, RunID: 1, GraphDefinition: test.definition})
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCleanStepOutputs(t *testing.T) {
+	workDir := t.TempDir()
+
+	for _, path := range []string{"trained_models/upstream.pt", "data.csv"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(workDir, path), []byte("before"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := &fakeStep{id: 1, outputs: []steps.StepFile{
+		{Name: "trained_models", Path: "trained_models/"},
+		{Name: "epochs", Path: "epochs/"},
+		{Name: "data", Path: "data.csv"},
+		{Name: "repository", Path: ""},
+	}}
+
+	outputSnapshots := snapshotStepOutputs(workDir, step)
+
+	// What a failed attempt leaves behind
+	for _, path := range []string{"trained_models/attempt.pt", "epochs/1/image.png", "data.csv", "unrelated.txt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(workDir, path), []byte("attempt"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cleanStepOutputs(workDir, step, outputSnapshots); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		wantExists bool
+	}{
+		{path: "trained_models/upstream.pt", wantExists: true},
+		{path: "trained_models/attempt.pt", wantExists: false},
+		{path: "epochs", wantExists: false},
+		{path: "data.csv", wantExists: false},
+		{path: "unrelated.txt", wantExists: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := os.Stat(filepath.Join(workDir, test.path))
+
+			if exists := err == nil; exists != test.wantExists {
+				t.Errorf("got exists %v, want %v", exists, test.wantExists)
+			}
+		})
+	}
+}
+
+func TestCreateStepCacheKey(t *testing.T) {
+	uploadDir := t.TempDir() + "/"
+	t.Setenv("FILE_UPLOAD_DIR", uploadDir)
+
+	type keyInputs struct {
+		pipelineID   uint
+		userID       uint
+		script       string
+		upstreamHash string
+	}
+
+	base := keyInputs{pipelineID: 1, userID: 1, script: "echo 1", upstreamHash: "a"}
+
+	createKey := func(t *testing.T, inputs keyInputs) string {
+		scriptDir := filepath.Join(uploadDir, "pipelines", fmt.Sprint(inputs.pipelineID))
+
+		if err := os.MkdirAll(scriptDir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(scriptDir, "script.sh"), []byte(inputs.script), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		runRepository := &fakeRunRepository{}
+
+		if inputs.upstreamHash != "" {
+			runRepository.runStepStatuses = []model.RunStepStatus{{RunID: 1, StepID: 1, RunStatusID: 4, OutputHash: inputs.upstreamHash}}
+		}
+
+		service := &runServiceImpl{RunRepository: runRepository}
+		run := &model.Run{PipelineID: inputs.pipelineID, Pipeline: model.Pipeline{UserID: inputs.userID}}
+		run.ID = 1
+
+		pipelineGraph := newTestGraph(t, []*fakeStep{{id: 1}, {id: 2}}, [][2]int{{1, 2}})
+
+		config := stepExecutionConfig{Cache: true}
+		config.Description.Type = "shellScript"
+		config.Description.Data.StepConfig.Filename = null.StringFrom("script.sh")
+
+		return service.createStepCacheKey(context.Background(), run, pipelineGraph, 2, config)
+	}
+
+	baseKey := createKey(t, base)
+
+	if baseKey == "" {
+		t.Fatal("got an empty key for a step whose inputs are all known")
+	}
+
+	tests := []struct {
+		name    string
+		inputs  keyInputs
+		wantKey string // "base" for the base key, "" for no key, "other" for a key different from the base one
+	}{
+		{name: "same inputs", inputs: base, wantKey: "base"},
+		{name: "other pipeline", inputs: keyInputs{pipelineID: 2, userID: 1, script: "echo 1", upstreamHash: "a"}, wantKey: "other"},
+		{name: "other owner", inputs: keyInputs{pipelineID: 1, userID: 2, script: "echo 1", upstreamHash: "a"}, wantKey: "other"},
+		{name: "other uploaded script", inputs: keyInputs{pipelineID: 1, userID: 1, script: "echo 2", upstreamHash: "a"}, wantKey: "other"},
+		{name: "other upstream outputs", inputs: keyInputs{pipelineID: 1, userID: 1, script: "echo 1", upstreamHash: "b"}, wantKey: "other"},
+		{name: "unknown upstream outputs", inputs: keyInputs{pipelineID: 1, userID: 1, script: "echo 1"}, wantKey: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := createKey(t, test.inputs)
+
+			switch test.wantKey {
+			case "base":
+				if key != baseKey {
+					t.Errorf("got key %q, want the base key %q", key, baseKey)
+				}
+			case "other":
+				if key == "" || key == baseKey {
+					t.Errorf("got key %q, want a key other than the base key", key)
+				}
+			default:
+				if key != "" {
+					t.Errorf("got key %q, want no key", key)
+				}
+			}
+		})
+	}
+}
+
+func TestIsStepOutput(t *testing.T) {
+	step := &fakeStep{id: 1, outputs: []steps.StepFile{
+		{Name: "trained_models", Path: "trained_models/"},
+		{Name: "score", Path: "score.json"},
+	}}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "trained_models/model.pt", want: true},
+		{path: "score.json", want: true},
+		{path: "score.json.bak", want: false},
+		{path: "trained_models_old/model.pt", want: false},
+		{path: "epochs/1.png", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := isStepOutput(step, test.path); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUploadedScriptPath(t *testing.T) {
+	t.Setenv("FILE_UPLOAD_DIR", "/uploads/")
+
+	tests := []struct {
+		name       string
+		stepType   string
+		scriptType string
+		want       string
+	}{
+		{name: "uploaded shell script", stepType: "shellScript", scriptType: "upload", want: "/uploads/pipelines/1/script"},
+		{name: "inline shell script", stepType: "shellScript", scriptType: "inline"},
+		{name: "work dir shell script", stepType: "shellScript", scriptType: "file"},
+		{name: "uploaded custom model", stepType: "customPyTorchModel", scriptType: "file", want: "/uploads/pipelines/1/script"},
+		{name: "inline custom model", stepType: "customPyTorchModel", scriptType: "inline"},
+		{name: "custom HITL", stepType: "customHITL", want: "/uploads/pipelines/1/script"},
+		{name: "trainer", stepType: "trainer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stepDescription model.NodeDescription
+			stepDescription.Type = test.stepType
+			stepDescription.Data.NameAndType.ScriptType = test.scriptType
+			stepDescription.Data.StepConfig.Filename = null.StringFrom("script")
+
+			if got := uploadedScriptPath(1, stepDescription); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecordStepArtifacts(t *testing.T) {
+	workDir := t.TempDir()
+	startedAt := time.Now().Add(-time.Minute)
+
+	for _, path := range []string{"trained_models/upstream.pt", "trained_models/model.pt", "score.json"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(workDir, path)), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(workDir, path), []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Files left by upstream steps before the step started
+	for _, path := range []string{"trained_models/upstream.pt", "score.json"} {
+		before := startedAt.Add(-time.Hour)
+
+		if err := os.Chtimes(filepath.Join(workDir, path), before, before); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := &fakeStep{id: 1, outputs: []steps.StepFile{
+		{Name: "trained_models", Path: "trained_models/"},
+		{Name: "score", Path: "score.json"},
+		{Name: "epochs", Path: "epochs/", Optional: true},
+	}}
+
+	runRepository := &fakeRunRepository{}
+	service := &runServiceImpl{RunRepository: runRepository, I18n: newTestLocalizer(t)}
+	service.recordStepArtifacts(workDir, 1, step, startedAt, log.New(io.Discard, "", 0))
+
+	var paths []string
+
+	for _, runArtifact := range runRepository.runArtifacts {
+		paths = append(paths, filepath.ToSlash(runArtifact.Path))
+	}
+
+	sort.Strings(paths)
+
+	if want := []string{"score.json", "trained_models/model.pt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got artifacts %v, want %v", paths, want)
+	}
+}
+
+func TestStampExecutionTimes(t *testing.T) {
+	var startedAt, resumedAt, finishedAt null.Time
+	var durationMs int64
+
+	stampExecutionTimes(&startedAt, &resumedAt, &finishedAt, &durationMs, 2, false)
+
+	if !startedAt.Valid || !resumedAt.Valid || finishedAt.Valid {
+		t.Fatalf("got started %v, resumed %v, finished %v after starting", startedAt, resumedAt, finishedAt)
+	}
+
+	// An hour executing before waiting for feedback
+	startedAt = null.TimeFrom(startedAt.Time.Add(-time.Hour))
+	resumedAt = startedAt
+	stampExecutionTimes(&startedAt, &resumedAt, &finishedAt, &durationMs, 5, false)
+
+	if resumedAt.Valid || durationMs < time.Hour.Milliseconds() {
+		t.Fatalf("got duration %dms, resumed %v while waiting, want the hour executed", durationMs, resumedAt)
+	}
+
+	// The time until the feedback is given doesn't count
+	firstStartedAt := startedAt
+	stampExecutionTimes(&startedAt, &resumedAt, &finishedAt, &durationMs, 2, false)
+
+	if startedAt != firstStartedAt || !resumedAt.Valid {
+		t.Fatalf("got started %v, resumed %v after resuming, want the start kept", startedAt, resumedAt)
+	}
+
+	stampExecutionTimes(&startedAt, &resumedAt, &finishedAt, &durationMs, 4, true)
+
+	if !finishedAt.Valid || resumedAt.Valid {
+		t.Fatalf("got finished %v, resumed %v after finishing", finishedAt, resumedAt)
+	}
+
+	if durationMs < time.Hour.Milliseconds() || durationMs > time.Hour.Milliseconds()+time.Minute.Milliseconds() {
+		t.Errorf("got duration %dms, want the hour executed", durationMs)
+	}
+
+	// A new execution starts the clock over
+	stampExecutionTimes(&startedAt, &resumedAt, &finishedAt, &durationMs, 2, false)
+
+	if durationMs != 0 || finishedAt.Valid || !resumedAt.Valid || startedAt == firstStartedAt {
+		t.Errorf("got duration %dms, started %v, finished %v after executing again", durationMs, startedAt, finishedAt)
+	}
+}
+
+func TestTrainedModelFiles(t *testing.T) {
+	workDir := t.TempDir()
+	modelsDir := filepath.Join(workDir, steps.TrainedModelsDir)
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	startedAt := time.Now().Add(-time.Minute)
+	finishedAt := time.Now().Add(time.Minute)
+
+	files := map[string]time.Time{
+		"saved.pt":    time.Now(),
+		"earlier.pt":  startedAt.Add(-time.Hour),
+		"writing.pt":  finishedAt.Add(time.Second),
+		"summary.txt": time.Now(),
+	}
+	for name, modTime := range files {
+		path := filepath.Join(modelsDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := trainedModelFiles(workDir, &steps.Trainer{ID: 1}, startedAt, finishedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(modelsDir, "saved.pt")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("trainer paths = %v, want %v", paths, want)
+	}
+
+	paths, err = trainedModelFiles(workDir, &steps.Tester{ID: 2}, startedAt, finishedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("tester paths = %v, want none", paths)
+	}
+}
+
+// fakePipelineService returns the pipeline it holds. The methods it does not implement panic through the nil
+// embedded interface.
+type fakePipelineService struct {
+	PipelineService
+	pipeline model.Pipeline
+}
+
+func (fake *fakePipelineService) Get(id uint) (*model.Pipeline, error) {
+	return &fake.pipeline, nil
+}
+
+func TestResolveModelReferences(t *testing.T) {
+	t.Setenv("FILE_UPLOAD_DIR", "/uploads/")
+
+	trainedService := &fakeTrainedModelService{versions: map[string]model.Trained{
+		"resnet/production": {Model: gorm.Model{ID: 4}, Name: "resnet_v2", Path: "/uploads/trained/resnet_v2.pt"},
+	}}
+	service := &runServiceImpl{
+		I18n:            newTestLocalizer(t),
+		PipelineService: &fakePipelineService{pipeline: model.Pipeline{UserID: 1}},
+		TrainedService:  trainedService,
+	}
+
+	definition := `[{"id": "1", "type": "trained", "data": {"id": "1", "stepConfig": {"modelName": "resnet"}}}]`
+
+	resolved, err := service.resolveModelReferences(1, definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nodeDescriptions []model.NodeDescription
+	if err := json.Unmarshal([]byte(resolved), &nodeDescriptions); err != nil {
+		t.Fatal(err)
+	}
+
+	stepConfig := nodeDescriptions[0].Data.StepConfig
+	if stepConfig.TrainedID != 4 || stepConfig.TrainedName != "resnet_v2" || stepConfig.TrainedPath != "trained/resnet_v2.pt" || stepConfig.ModelName != "" {
+		t.Errorf("resolved step config = %+v, want version 4 without the model name", stepConfig)
+	}
+
+	// A new production version is not picked up once the run resolved its references
+	trainedService.versions["resnet/production"] = model.Trained{Model: gorm.Model{ID: 5}, Name: "resnet_v3"}
+
+	again, err := service.resolveModelReferences(1, resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != resolved {
+		t.Errorf("resolving again = %s, want %s", again, resolved)
+	}
+}
+
+func TestValidatePipelineDefinitionModelReferences(t *testing.T) {
+	localizer := newTestLocalizer(t)
+	service := &runServiceImpl{
+		I18n:            localizer,
+		PipelineService: &fakePipelineService{pipeline: model.Pipeline{UserID: 1}},
+		NodeTypeService: &nodeServiceImpl{
+			I18n:             localizer,
+			StepTypeRegistry: initStepTypeRegistry(),
+			EdgeTypeRegistry: initEdgeTypeRegistry(),
+			TrainedService: &fakeTrainedModelService{versions: map[string]model.Trained{
+				"resnet/production": {UserID: 1},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		modelName string
+		wantErr   bool
+	}{
+		{name: "resolved reference", modelName: "resnet"},
+		{name: "unresolved reference", modelName: "vgg", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition := `[{"id": "1", "type": "trained", "data": {"id": "1", "nameAndType": {"name": "trained"}, "stepConfig": {"modelName": "` + test.modelName + `"}}}]`
+
+			if err := service.validatePipelineDefinition(definition, 1); (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordStepTestResults(t *testing.T) {
+	workDir := t.TempDir()
+	runRepository := &fakeRunRepository{}
+	service := &runServiceImpl{I18n: newTestLocalizer(t), RunRepository: runRepository}
+	runLogger := log.New(io.Discard, "", 0)
+
+	// Testers of the same run write their results side by side
+	testers := []*steps.Tester{{ID: 1, Name: "test a"}, {ID: 2, Name: "test b"}}
+
+	for _, tester := range testers {
+		path := filepath.Join(workDir, steps.TestResultsPath(tester.ID))
+
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(fmt.Sprintf(`{"metrics": {"accuracy": 0.%d}}`, tester.ID)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tester := range testers {
+		service.recordStepTestResults(workDir, 1, tester, runLogger)
+	}
+
+	// A step executed again replaces its results
+	service.recordStepTestResults(workDir, 1, testers[0], runLogger)
+
+	got := make(map[int]string)
+
+	for _, runTestResult := range runRepository.runTestResults {
+		if _, ok := got[runTestResult.StepID]; ok {
+			t.Errorf("step %d has more than one test result", runTestResult.StepID)
+		}
+		got[runTestResult.StepID] = runTestResult.Results
+	}
+
+	want := map[int]string{
+		1: `{"metrics":{"accuracy":0.1}}`,
+		2: `{"metrics":{"accuracy":0.2}}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("test results = %v, want %v", got, want)
+	}
+}
@@ -3,12 +3,15 @@ package service
 import (
 	"di/model"
 	"di/steps"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/dominikbraun/graph"
 	"github.com/iancoleman/strcase"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
@@ -17,13 +20,21 @@ type nodeServiceImpl struct {
 	I18n             *i18n.Localizer
 	StepTypeRegistry map[string]reflect.Type
 	EdgeTypeRegistry map[string]reflect.Type
+	DatasetService   DatasetService
+	TrainerService   TrainerService
+	TesterService    TesterService
+	TrainedService   TrainedModelService
 }
 
-func NewNodeService(i18n *i18n.Localizer) StepService {
+func NewNodeService(i18n *i18n.Localizer, datasetService *DatasetService, trainerService *TrainerService, testerService *TesterService, trainedService *TrainedModelService) StepService {
 	return &nodeServiceImpl{
 		I18n:             i18n,
 		StepTypeRegistry: initStepTypeRegistry(),
 		EdgeTypeRegistry: initEdgeTypeRegistry(),
+		DatasetService:   *datasetService,
+		TrainerService:   *trainerService,
+		TesterService:    *testerService,
+		TrainedService:   *trainedService,
 	}
 }
 
@@ -46,9 +57,17 @@ func (nodeService *nodeServiceImpl) NewStepInstance(pipelineID uint, runID uint,
 	stepPtr := reflect.New(stepTypeStructName)
 	setupStep := stepPtr.Interface().(steps.Step)
 
-	setupStep.SetData(nodeDescription)
-	setupStep.SetPipelineID(pipelineID)
-	setupStep.SetRunID(runID)
+	if err := setupStep.SetData(nodeDescription); err != nil {
+		return nil, err
+	}
+
+	if err := setupStep.SetPipelineID(pipelineID); err != nil {
+		return nil, err
+	}
+
+	if err := setupStep.SetRunID(runID); err != nil {
+		return nil, err
+	}
 
 	return &setupStep, nil
 }
@@ -76,6 +95,11 @@ func (nodeService *nodeServiceImpl) NewEdgeInstance(pipelineID uint, runID uint,
 	return &setupEdge, nil
 }
 
+// IsEdgeType reports whether a node description of the given type is an edge rather than a step.
+func (nodeService *nodeServiceImpl) IsEdgeType(nodeType string) bool {
+	return nodeService.EdgeTypeRegistry[nodeType] != nil
+}
+
 func initStepTypeRegistry() map[string]reflect.Type {
 	var stepTypeRegistry = make(map[string]reflect.Type)
 
@@ -108,3 +132,365 @@ func initEdgeTypeRegistry() map[string]reflect.Type {
 
 	return edgeTypeRegistry
 }
+
+// ValidatePipeline checks the definition of a pipeline owned by the user without executing it. Diagnostics with
+// error severity prevent the pipeline from being executed, while warnings only point out likely mistakes.
+func (nodeService *nodeServiceImpl) ValidatePipeline(definition string, parameters string, userID uint) []model.PipelineDiagnostic {
+	var diagnostics []model.PipelineDiagnostic
+
+	addDiagnostic := func(severity string, code string, stepID string, messageID string, templateData map[string]interface{}) {
+		diagnostics = append(diagnostics, model.PipelineDiagnostic{
+			Severity: severity,
+			Code:     code,
+			StepID:   stepID,
+			Message: nodeService.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID:    messageID,
+				TemplateData: templateData,
+				PluralCount:  1,
+			}),
+		})
+	}
+
+	var nodeDescriptions []map[string]interface{}
+
+	if err := json.Unmarshal([]byte(definition), &nodeDescriptions); err != nil {
+		addDiagnostic("error", "invalid-definition", "", "pipeline.validate.definition.invalid", map[string]interface{}{
+			"Reason": err.Error(),
+		})
+
+		return diagnostics
+	}
+
+	// Steps are validated with the parameter defaults, the values a run uses are only known when it's created.
+	// Parameters without a default are only required then.
+	values, err := resolvePipelineParameters(nodeService.I18n, parameters, nil, false)
+
+	if err != nil {
+		diagnostics = append(diagnostics, model.PipelineDiagnostic{Severity: "error", Code: "invalid-parameter", Message: err.Error()})
+		return diagnostics
+	}
+
+	definition, err = substitutePipelineParameters(nodeService.I18n, definition, values)
+
+	if err != nil {
+		diagnostics = append(diagnostics, model.PipelineDiagnostic{Severity: "error", Code: "undefined-parameter", Message: err.Error()})
+		return diagnostics
+	}
+
+	var stepDescriptions []model.NodeDescription
+
+	if err := json.Unmarshal([]byte(definition), &stepDescriptions); err != nil {
+		addDiagnostic("error", "invalid-definition", "", "pipeline.validate.definition.invalid", map[string]interface{}{
+			"Reason": err.Error(),
+		})
+
+		return diagnostics
+	}
+
+	pipelineGraph := graph.New(stepHash, graph.Directed(), graph.Acyclic())
+	stepDescriptionsByID := make(map[int]model.NodeDescription)
+	var edgeDescriptions []model.NodeDescription
+
+	for _, stepDescription := range stepDescriptions {
+		if nodeService.IsEdgeType(stepDescription.Type) {
+			edgeDescriptions = append(edgeDescriptions, stepDescription)
+			continue
+		}
+
+		if nodeService.StepTypeRegistry[stepDescription.Type] == nil {
+			addDiagnostic("error", "unknown-type", stepDescription.Data.ID, "pipeline.validate.step.type.unknown", map[string]interface{}{
+				"ID":   stepDescription.ID,
+				"Type": stepDescription.Type,
+			})
+			continue
+		}
+
+		step, err := nodeService.NewStepInstance(0, 0, stepDescription.Type, stepDescription)
+
+		if err != nil {
+			addDiagnostic("error", "invalid-step", stepDescription.Data.ID, "pipeline.validate.step.invalid", map[string]interface{}{
+				"ID":     stepDescription.ID,
+				"Reason": err.Error(),
+			})
+			continue
+		}
+
+		if err := pipelineGraph.AddVertex(*step); err != nil {
+			addDiagnostic("error", "duplicate-step", stepDescription.Data.ID, "pipeline.validate.step.duplicate", map[string]interface{}{
+				"ID":     stepDescription.Data.ID,
+				"Reason": err.Error(),
+			})
+			continue
+		}
+
+		stepDescriptionsByID[(*step).GetID()] = stepDescription
+	}
+
+	for _, edgeDescription := range edgeDescriptions {
+		edge, err := nodeService.NewEdgeInstance(0, 0, edgeDescription.Type, edgeDescription)
+
+		if err != nil {
+			addDiagnostic("error", "invalid-edge", "", "pipeline.validate.edge.invalid", map[string]interface{}{
+				"ID":     edgeDescription.ID,
+				"Reason": err.Error(),
+			})
+			continue
+		}
+
+		_, sourceErr := pipelineGraph.Vertex((*edge).GetSourceID())
+		_, targetErr := pipelineGraph.Vertex((*edge).GetTargetID())
+
+		if sourceErr != nil || targetErr != nil {
+			addDiagnostic("error", "dangling-edge", "", "pipeline.validate.edge.dangling", map[string]interface{}{
+				"ID":     edgeDescription.ID,
+				"Source": edgeDescription.SourceID,
+				"Target": edgeDescription.TargetID,
+			})
+			continue
+		}
+
+		if err := pipelineGraph.AddEdge((*edge).GetTargetID(), (*edge).GetSourceID()); err != nil {
+			if errors.Is(err, graph.ErrEdgeCreatesCycle) {
+				addDiagnostic("error", "cycle", "", "pipeline.validate.edge.cycle", map[string]interface{}{
+					"ID":     edgeDescription.ID,
+					"Source": edgeDescription.SourceID,
+					"Target": edgeDescription.TargetID,
+				})
+			}
+		}
+	}
+
+	predecessorMap, err := pipelineGraph.PredecessorMap()
+
+	if err != nil {
+		return diagnostics
+	}
+
+	adjacencyMap, err := pipelineGraph.AdjacencyMap()
+
+	if err != nil {
+		return diagnostics
+	}
+
+	var stepIDs []int
+
+	for id := range predecessorMap {
+		stepIDs = append(stepIDs, id)
+	}
+
+	sort.Ints(stepIDs)
+
+	for _, id := range stepIDs {
+		step, _ := pipelineGraph.Vertex(id)
+		stepID := fmt.Sprint(id)
+
+		if step.GetIsFirstStep() && len(predecessorMap[id]) > 0 {
+			addDiagnostic("warning", "invalid-first-step", stepID, "run.service.execute.entry-step.invalid", map[string]interface{}{
+				"ID":   id,
+				"Name": step.GetName(),
+			})
+		}
+
+		producedFiles := make(map[string]bool)
+
+		for upstreamStepID := range reachableSteps(predecessorMap, id) {
+			if upstreamStepID == id {
+				continue
+			}
+
+			upstreamStep, _ := pipelineGraph.Vertex(upstreamStepID)
+
+			for _, output := range upstreamStep.GetOutputs() {
+				producedFiles[output.Name] = true
+			}
+		}
+
+		for _, input := range step.GetInputs() {
+			if !input.Optional && !producedFiles[input.Name] {
+				addDiagnostic("error", "missing-input", stepID, "run.service.execute.step.input.missing", map[string]interface{}{
+					"ID":    id,
+					"Name":  step.GetName(),
+					"Input": input.Name,
+				})
+			}
+		}
+
+		stepDescription := stepDescriptionsByID[id]
+
+		for _, field := range missingStepConfig(stepDescription) {
+			addDiagnostic("error", "missing-config", stepID, "pipeline.validate.step.config.missing", map[string]interface{}{
+				"ID":    id,
+				"Name":  step.GetName(),
+				"Field": field,
+			})
+		}
+
+		deletedReferences, foreignReferences := nodeService.findUnavailableReferences(stepDescription.Data.StepConfig, userID)
+
+		for _, reference := range deletedReferences {
+			addDiagnostic("error", "deleted-reference", stepID, "pipeline.validate.step.reference.deleted", map[string]interface{}{
+				"ID":        id,
+				"Name":      step.GetName(),
+				"Reference": reference,
+			})
+		}
+
+		for _, reference := range foreignReferences {
+			addDiagnostic("error", "foreign-reference", stepID, "pipeline.validate.step.reference.foreign", map[string]interface{}{
+				"ID":        id,
+				"Name":      step.GetName(),
+				"Reference": reference,
+			})
+		}
+
+		// A version may reach the stage before the pipeline runs, so triggering a run is what the reference blocks
+		if modelName, modelStage, ok := nodeService.findUnresolvedModelReference(stepDescription.Data.StepConfig, userID); !ok {
+			addDiagnostic("warning", "unresolved-reference", stepID, "pipeline.validate.step.reference.unresolved", map[string]interface{}{
+				"ID":    id,
+				"Name":  step.GetName(),
+				"Model": modelName,
+				"Stage": modelStage,
+			})
+		}
+	}
+
+	entryStepIDs := entrySteps(pipelineGraph, predecessorMap)
+
+	if len(stepIDs) > 0 && len(entryStepIDs) == 0 {
+		addDiagnostic("error", "no-entry-step", "", "run.service.execute.entry-step.missing", nil)
+	}
+
+	// A run starts from the same entry steps, so only the steps it does not reach are never executed.
+	reachable := make(map[int]bool)
+
+	for _, entryStepID := range entryStepIDs {
+		for id := range reachableSteps(adjacencyMap, entryStepID) {
+			reachable[id] = true
+		}
+	}
+
+	for _, id := range stepIDs {
+		if !reachable[id] {
+			step, _ := pipelineGraph.Vertex(id)
+
+			addDiagnostic("warning", "unreachable-step", fmt.Sprint(id), "pipeline.validate.step.unreachable", map[string]interface{}{
+				"ID":   id,
+				"Name": step.GetName(),
+			})
+		}
+	}
+
+	return diagnostics
+}
+
+// missingStepConfig returns the json names of the config fields the step type requires but are not set.
+func missingStepConfig(stepDescription model.NodeDescription) []string {
+	var missing []string
+	stepConfig := stepDescription.Data.StepConfig
+
+	switch stepDescription.Type {
+	case "checkoutRepo":
+		if stepConfig.RepoURL.ValueOrZero() == "" {
+			missing = append(missing, "repoURL")
+		}
+	case "shellScript", "customPyTorchModel":
+		if stepConfig.InlineScript.ValueOrZero() == "" && stepConfig.Filename.ValueOrZero() == "" {
+			missing = append(missing, "script")
+		}
+	case "customHITL":
+		if stepConfig.Filename.ValueOrZero() == "" {
+			missing = append(missing, "filename")
+		}
+	case "dataset":
+		if stepConfig.DatasetID == 0 {
+			missing = append(missing, "datasetID")
+		}
+	case "trainer":
+		if stepConfig.TrainerID == 0 {
+			missing = append(missing, "trainerID")
+		}
+	case "tester":
+		if stepConfig.TesterID == 0 {
+			missing = append(missing, "testerID")
+		}
+	case "trained":
+		if stepConfig.TrainedID == 0 && stepConfig.ModelName == "" {
+			missing = append(missing, "trainedID")
+		}
+	case "scikitTrainingDataset", "scikitTestingDataset":
+		if stepDescription.Data.NameAndType.Dataset == "" {
+			missing = append(missing, "dataset")
+		}
+	}
+
+	return missing
+}
+
+// findUnavailableReferences returns the Dataset, Trainer, Tester and Trained records the step config refers to
+// that no longer exist, and the ones that belong to another user than the owner of the pipeline.
+func (nodeService *nodeServiceImpl) findUnavailableReferences(stepConfig model.StepDataConfig, userID uint) ([]string, []string) {
+	var deleted []string
+	var foreign []string
+
+	if stepConfig.DatasetID != 0 {
+		reference := fmt.Sprintf("Dataset %s (%d)", stepConfig.DatasetName, stepConfig.DatasetID)
+
+		if dataset, err := nodeService.DatasetService.Get(stepConfig.DatasetID); err != nil {
+			deleted = append(deleted, reference)
+		} else if dataset.UserID != userID {
+			foreign = append(foreign, reference)
+		}
+	}
+
+	if stepConfig.TrainerID != 0 {
+		reference := fmt.Sprintf("Trainer %s (%d)", stepConfig.TrainerName, stepConfig.TrainerID)
+
+		if trainer, err := nodeService.TrainerService.Get(stepConfig.TrainerID); err != nil {
+			deleted = append(deleted, reference)
+		} else if trainer.UserID != userID {
+			foreign = append(foreign, reference)
+		}
+	}
+
+	if stepConfig.TesterID != 0 {
+		reference := fmt.Sprintf("Tester %s (%d)", stepConfig.TesterName, stepConfig.TesterID)
+
+		if tester, err := nodeService.TesterService.Get(stepConfig.TesterID); err != nil {
+			deleted = append(deleted, reference)
+		} else if tester.UserID != userID {
+			foreign = append(foreign, reference)
+		}
+	}
+
+	if stepConfig.TrainedID != 0 {
+		reference := fmt.Sprintf("Trained %s (%d)", stepConfig.TrainedName, stepConfig.TrainedID)
+
+		if trained, err := nodeService.TrainedService.Get(stepConfig.TrainedID); err != nil {
+			deleted = append(deleted, reference)
+		} else if trained.UserID != userID {
+			foreign = append(foreign, reference)
+		}
+	}
+
+	return deleted, foreign
+}
+
+// findUnresolvedModelReference checks that the registered model the step config refers to by name has a version in
+// the stage it asks for, production when empty, among the models of the user. ok is false when it has none.
+func (nodeService *nodeServiceImpl) findUnresolvedModelReference(stepConfig model.StepDataConfig, userID uint) (string, string, bool) {
+	if stepConfig.ModelName == "" {
+		return "", "", true
+	}
+
+	modelStage := stepConfig.ModelStage
+
+	if modelStage == "" {
+		modelStage = model.TrainedStageProduction
+	}
+
+	if _, err := nodeService.TrainedService.GetLatestInStage(userID, stepConfig.ModelName, modelStage); err != nil {
+		return stepConfig.ModelName, modelStage, false
+	}
+
+	return stepConfig.ModelName, modelStage, true
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"di/model"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeDatasetService returns the datasets it holds, indexed by id. The methods it does not implement panic through
+// the nil embedded interface.
+type fakeDatasetService struct {
+	DatasetService
+	datasets map[uint]model.Dataset
+}
+
+func (fake *fakeDatasetService) Get(id uint) (*model.Dataset, error) {
+	dataset, ok := fake.datasets[id]
+
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+
+	return &dataset, nil
+}
+
+func TestValidatePipelineReferences(t *testing.T) {
+	datasetService := &fakeDatasetService{datasets: map[uint]model.Dataset{
+		1: {UserID: 1},
+		2: {UserID: 2},
+	}}
+
+	nodeService := &nodeServiceImpl{
+		I18n:             newTestLocalizer(t),
+		StepTypeRegistry: initStepTypeRegistry(),
+		EdgeTypeRegistry: initEdgeTypeRegistry(),
+		DatasetService:   datasetService,
+	}
+
+	tests := []struct {
+		name      string
+		datasetID string
+		wantCodes []string
+	}{
+		{name: "own dataset", datasetID: "1"},
+		{name: "dataset of another user", datasetID: "2", wantCodes: []string{"foreign-reference"}},
+		{name: "deleted dataset", datasetID: "3", wantCodes: []string{"deleted-reference"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition := `[{"id": "1", "type": "dataset", "data": {"id": "1", "nameAndType": {"name": "dataset"}, "stepConfig": {"datasetID": ` + test.datasetID + `}}}]`
+
+			var codes []string
+
+			for _, diagnostic := range nodeService.ValidatePipeline(definition, "", 1) {
+				if diagnostic.Severity == "error" {
+					codes = append(codes, diagnostic.Code)
+				}
+			}
+
+			if len(codes) != len(test.wantCodes) {
+				t.Fatalf("got error codes %v, want %v", codes, test.wantCodes)
+			}
+
+			for i := range codes {
+				if codes[i] != test.wantCodes[i] {
+					t.Errorf("got error codes %v, want %v", codes, test.wantCodes)
+				}
+			}
+		})
+	}
+}
+
+// fakeTrainedModelService returns the versions it holds, indexed by model name and stage. The methods it does not
+// implement panic through the nil embedded interface.
+type fakeTrainedModelService struct {
+	TrainedModelService
+	versions map[string]model.Trained
+}
+
+func (fake *fakeTrainedModelService) GetLatestInStage(ownerId uint, name string, stage string) (*model.Trained, error) {
+	trained, ok := fake.versions[name+"/"+stage]
+
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+
+	return &trained, nil
+}
+
+func TestValidatePipelineModelReferences(t *testing.T) {
+	nodeService := &nodeServiceImpl{
+		I18n:             newTestLocalizer(t),
+		StepTypeRegistry: initStepTypeRegistry(),
+		EdgeTypeRegistry: initEdgeTypeRegistry(),
+		TrainedService: &fakeTrainedModelService{versions: map[string]model.Trained{
+			"resnet/production": {UserID: 1},
+			"resnet/staging":    {UserID: 1},
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		stepConfig string
+		wantCodes  []string
+	}{
+		{name: "production version", stepConfig: `{"modelName": "resnet"}`},
+		{name: "staging version", stepConfig: `{"modelName": "resnet", "modelStage": "staging"}`},
+		{name: "no version in stage", stepConfig: `{"modelName": "resnet", "modelStage": "archived"}`, wantCodes: []string{"warning/unresolved-reference"}},
+		{name: "unknown model", stepConfig: `{"modelName": "vgg"}`, wantCodes: []string{"warning/unresolved-reference"}},
+		{name: "no model", stepConfig: `{}`, wantCodes: []string{"error/missing-config"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			definition := `[{"id": "1", "type": "trained", "data": {"id": "1", "nameAndType": {"name": "trained", "isFirstStep": true}, "stepConfig": ` + test.stepConfig + `}}]`
+
+			var codes []string
+
+			for _, diagnostic := range nodeService.ValidatePipeline(definition, "", 1) {
+				codes = append(codes, diagnostic.Severity+"/"+diagnostic.Code)
+			}
+
+			if !reflect.DeepEqual(codes, test.wantCodes) {
+				t.Errorf("got codes %v, want %v", codes, test.wantCodes)
+			}
+		})
+	}
+}
+
+func TestValidatePipelineReachability(t *testing.T) {
+	nodeService := &nodeServiceImpl{
+		I18n:             newTestLocalizer(t),
+		StepTypeRegistry: initStepTypeRegistry(),
+		EdgeTypeRegistry: initEdgeTypeRegistry(),
+	}
+
+	tests := []struct {
+		name       string
+		definition string
+		wantCodes  []string
+	}{
+		{
+			name:       "root next to a flagged step",
+			definition: `[{"id": "1", "type": "shellScript", "data": {"id": "1", "nameAndType": {"isFirstStep": true}, "stepConfig": {"script": "true"}}}, {"id": "2", "type": "shellScript", "data": {"id": "2", "stepConfig": {"script": "true"}}}]`,
+		},
+		{
+			name:       "flagged step with upstream steps",
+			definition: `[{"id": "1", "type": "shellScript", "data": {"id": "1", "stepConfig": {"script": "true"}}}, {"id": "2", "type": "shellScript", "data": {"id": "2", "nameAndType": {"isFirstStep": true}, "stepConfig": {"script": "true"}}}, {"id": "e1-2", "type": "smoothstep", "source": "2", "target": "1"}]`,
+			wantCodes:  []string{"invalid-first-step"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var codes []string
+
+			for _, diagnostic := range nodeService.ValidatePipeline(test.definition, "", 1) {
+				codes = append(codes, diagnostic.Code)
+			}
+
+			if !reflect.DeepEqual(codes, test.wantCodes) {
+				t.Errorf("got codes %v, want %v", codes, test.wantCodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,773 @@
+package service
+
+import (
+	"context"
+	"di/model"
+	"di/steps"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"gopkg.in/guregu/null.v4"
+)
+
+// defaultSweepSamples is the number of trials of a random search that doesn't set one.
+const defaultSweepSamples = 10
+
+// defaultSweepConcurrency is the number of trials a sweep executes at the same time when it doesn't set one.
+const defaultSweepConcurrency = 2
+
+// maxSweepTrials bounds the number of child runs a sweep may create.
+const maxSweepTrials = 500
+
+// sweepLockTimeout bounds the time the lock of a sweep is held, and waited for.
+const sweepLockTimeout = 30 * time.Second
+
+// sweepLockRetryInterval is the time between two attempts to take the lock of a sweep.
+const sweepLockRetryInterval = 100 * time.Millisecond
+
+// Sweep creates a parent run grouping one child run per configuration of the search space, each one a copy of the
+// pipeline with the swept step config overridden, and starts the first child runs.
+func (service *runServiceImpl) Sweep(pipeline model.Pipeline, sweepReq model.SweepReq) (model.Run, error) {
+	if err := service.checkSweepStep(pipeline.Definition, sweepReq); err != nil {
+		return model.Run{}, err
+	}
+
+	trials, err := service.createSweepTrials(sweepReq)
+
+	if err != nil {
+		return model.Run{}, err
+	}
+
+	parameters, err := resolvePipelineParameters(service.I18n, pipeline.Parameters, sweepReq.Parameters, true)
+
+	if err != nil {
+		return model.Run{}, err
+	}
+
+	definitions := make([]string, len(trials))
+
+	for i, trial := range trials {
+		definition, err := overrideStepConfig(pipeline.Definition, sweepReq.StepID, trial)
+
+		if err != nil {
+			return model.Run{}, err
+		}
+
+		resolvedDefinition, err := substitutePipelineParameters(service.I18n, definition, parameters)
+
+		if err != nil {
+			return model.Run{}, err
+		}
+
+		if err := service.validatePipelineDefinition(resolvedDefinition, pipeline.ID); err != nil {
+			return model.Run{}, err
+		}
+
+		definitions[i] = definition
+	}
+
+	runReq := model.CreateRunReq{MaxParallelSteps: sweepReq.MaxParallelSteps, Parameters: sweepReq.Parameters}
+
+	parentRun, err := service.Create(pipeline, runReq)
+
+	if err != nil {
+		return parentRun, err
+	}
+
+	// The runs created so far are deleted when the sweep can't be created as a whole
+	createdRunIDs := []uint{parentRun.ID}
+
+	abort := func(err error) (model.Run, error) {
+		for _, runID := range createdRunIDs {
+			if err := service.Delete(runID); err != nil {
+				log.Println(err.Error())
+			}
+		}
+
+		return model.Run{}, err
+	}
+
+	sweep, err := json.Marshal(sweepReq)
+
+	if err != nil {
+		return abort(err)
+	}
+
+	parentRun.Sweep = string(sweep)
+
+	if err := service.Update(&parentRun); err != nil {
+		return abort(err)
+	}
+
+	for i, trial := range trials {
+		childRun, err := service.Create(pipeline, runReq)
+
+		if err != nil {
+			return abort(err)
+		}
+
+		createdRunIDs = append(createdRunIDs, childRun.ID)
+		sweepConfig, err := json.Marshal(trial)
+
+		if err != nil {
+			return abort(err)
+		}
+
+		childRun.ParentRunID = parentRun.ID
+		childRun.Definition = definitions[i]
+		childRun.SweepConfig = string(sweepConfig)
+
+		if err := service.Update(&childRun); err != nil {
+			return abort(err)
+		}
+	}
+
+	if err := service.UpdateRunStatus(parentRun.ID, 2, nil, ""); err != nil {
+		return abort(err)
+	}
+
+	if err := service.advanceSweep(parentRun.ID); err != nil {
+		return abort(err)
+	}
+
+	return parentRun, nil
+}
+
+func (service *runServiceImpl) FindSweepTrials(runID uint) ([]model.Run, error) {
+	runs, err := service.RunRepository.FindByParent(runID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.repository.find.run.parent.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return runs, errors.New(errMessage)
+	}
+
+	return runs, err
+}
+
+// advanceSweep starts the child runs of a sweep that haven't run yet, in order, each one as a run task of its own on
+// the queue of the sweep, so that at most MaxConcurrency of them execute at the same time. Once every child run is
+// done, the sweep is finished. It is called when the sweep is created and whenever one of its child runs finishes,
+// while holding the lock of the sweep so that child runs finishing at the same time don't start too many others.
+func (service *runServiceImpl) advanceSweep(parentRunID uint) error {
+	unlock, err := service.lockSweep(parentRunID)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	parentRun, err := service.RunRepository.FindByID(parentRunID)
+
+	if err != nil {
+		return err
+	}
+
+	// A cancelled or finished sweep starts nothing more
+	if parentRun.RunStatusID != 2 {
+		return nil
+	}
+
+	var sweepReq model.SweepReq
+
+	if err := json.Unmarshal([]byte(parentRun.Sweep), &sweepReq); err != nil {
+		return err
+	}
+
+	childRuns, err := service.FindSweepTrials(parentRunID)
+
+	if err != nil {
+		return err
+	}
+
+	concurrency := int(sweepReq.MaxConcurrency)
+
+	if concurrency == 0 {
+		concurrency = defaultSweepConcurrency
+	}
+
+	pendingRuns, executing := sweepTrialsToStart(childRuns, concurrency)
+
+	for _, childRun := range pendingRuns {
+		started, err := service.startSweepTrial(*parentRun, childRun)
+
+		if err != nil {
+			log.Println(err.Error())
+
+			if err := service.UpdateRunStatus(childRun.ID, 3, nil, err.Error()); err != nil {
+				log.Println(err.Error())
+			}
+
+			continue
+		}
+
+		if started {
+			executing++
+		}
+	}
+
+	if executing > 0 {
+		return nil
+	}
+
+	// Every child run is done, including the ones that just failed to start
+	if childRuns, err = service.FindSweepTrials(parentRunID); err != nil {
+		log.Println(err.Error())
+		return nil
+	}
+
+	if _, executing := sweepTrialsToStart(childRuns, concurrency); executing > 0 {
+		return nil
+	}
+
+	service.finishSweep(parentRunID, sweepReq, childRuns)
+
+	return nil
+}
+
+// sweepTrialsToStart returns the child runs of a sweep that haven't run yet and can start without more than
+// concurrency of them executing at the same time, and the number of child runs executing or waiting for feedback.
+func sweepTrialsToStart(childRuns []model.Run, concurrency int) ([]model.Run, int) {
+	var pendingRuns []model.Run
+	executing := 0
+
+	for _, childRun := range childRuns {
+		switch childRun.RunStatusID {
+		case 1:
+			pendingRuns = append(pendingRuns, childRun)
+		case 2, 5:
+			executing++
+		}
+	}
+
+	available := concurrency - executing
+
+	if available <= 0 {
+		return nil, executing
+	}
+
+	if len(pendingRuns) > available {
+		pendingRuns = pendingRuns[:available]
+	}
+
+	return pendingRuns, executing
+}
+
+// startSweepTrial enqueues the run task of a child run of a sweep on the queue of the sweep. A child run cancelled
+// in the meantime isn't started, in which case false is returned.
+func (service *runServiceImpl) startSweepTrial(parentRun model.Run, childRun model.Run) (bool, error) {
+	started, err := service.updateRunStatusIn(childRun.ID, []uint{1}, 2, nil, "")
+
+	if err != nil || !started {
+		return false, err
+	}
+
+	runPipelineTask, err := service.NewRunPipelineTask(childRun.PipelineID, childRun.ID, childRun.Definition)
+
+	if err != nil {
+		return false, err
+	}
+
+	queue := runQueue(parentRun.Pipeline, HeavyQueue)
+	taskInfo, err := service.TaskQueueClient.Enqueue(runPipelineTask, asynq.Queue(queue), asynq.Timeout(runTaskTimeout))
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "tasks.client.enqueue.failed",
+			TemplateData: map[string]interface{}{
+				"Queue":  queue,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return false, errors.New(errMessage)
+	}
+
+	return true, service.updateRunTaskID(childRun.ID, taskInfo.ID, taskInfo.Queue)
+}
+
+// finishSweepTrial records the score the swept step of a finished child run of a sweep wrote, and lets the sweep start
+// its next child run or finish. A run that isn't a child run of a sweep, or isn't done, is left alone.
+func (service *runServiceImpl) finishSweepTrial(runID uint) {
+	run, err := service.RunRepository.FindByID(runID)
+
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	if run.ParentRunID == 0 || run.RunStatusID == 1 || run.RunStatusID == 2 || run.RunStatusID == 5 {
+		return
+	}
+
+	if run.RunStatusID == 4 {
+		parentRun, err := service.RunRepository.FindByID(run.ParentRunID)
+
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+
+		var sweepReq model.SweepReq
+
+		if err := json.Unmarshal([]byte(parentRun.Sweep), &sweepReq); err != nil {
+			log.Println(err.Error())
+			return
+		}
+
+		if score, err := readSweepScore(run.PipelineID, run.ID, sweepReq.StepID); err == nil {
+			run.SweepScore = null.FloatFrom(score)
+
+			if err := service.Update(run); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+
+	if err := service.advanceSweep(run.ParentRunID); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// sweepLockKey is the Redis key held while the child runs of a sweep are started or the sweep is finished.
+func sweepLockKey(parentRunID uint) string {
+	return fmt.Sprintf("run:%d:sweep:lock", parentRunID)
+}
+
+// lockSweep waits for the lock of the sweep and returns the function releasing it. The lock expires after
+// sweepLockTimeout in case the instance holding it dies.
+func (service *runServiceImpl) lockSweep(parentRunID uint) (func(), error) {
+	if service.RedisClient == nil {
+		return func() {}, nil
+	}
+
+	ctx := context.Background()
+	key := sweepLockKey(parentRunID)
+	deadline := time.Now().Add(sweepLockTimeout)
+
+	for {
+		acquired, err := service.RedisClient.SetNX(ctx, key, os.Getpid(), sweepLockTimeout).Result()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if acquired {
+			return func() {
+				if err := service.RedisClient.Del(ctx, key).Err(); err != nil {
+					log.Println(err.Error())
+				}
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.sweep.lock.failed",
+				TemplateData: map[string]interface{}{
+					"ID": parentRunID,
+				},
+				PluralCount: 1,
+			})
+
+			return nil, errors.New(errMessage)
+		}
+
+		time.Sleep(sweepLockRetryInterval)
+	}
+}
+
+// finishSweep sets the status of the parent run from the status of its children, once they are all done, and records
+// the best one. A sweep cancelled in the meantime is left cancelled.
+func (service *runServiceImpl) finishSweep(parentRunID uint, sweepReq model.SweepReq, childRuns []model.Run) {
+	var bestRun *model.Run
+	succeeded := 0
+
+	for i := range childRuns {
+		if childRuns[i].RunStatusID != 4 {
+			continue
+		}
+
+		succeeded++
+
+		if !childRuns[i].SweepScore.Valid {
+			continue
+		}
+
+		if bestRun == nil ||
+			(sweepReq.Objective == "minimize" && childRuns[i].SweepScore.Float64 < bestRun.SweepScore.Float64) ||
+			(sweepReq.Objective != "minimize" && childRuns[i].SweepScore.Float64 > bestRun.SweepScore.Float64) {
+			bestRun = &childRuns[i]
+		}
+	}
+
+	if succeeded == 0 {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.failed",
+			TemplateData: map[string]interface{}{
+				"ID": parentRunID,
+			},
+			PluralCount: 1,
+		})
+
+		if _, err := service.updateRunStatusIn(parentRunID, []uint{2}, 3, nil, errMessage); err != nil {
+			log.Println(err.Error())
+		}
+
+		return
+	}
+
+	var message string
+
+	if bestRun != nil {
+		parentRun, err := service.RunRepository.FindByID(parentRunID)
+
+		if err == nil {
+			parentRun.BestRunID = bestRun.ID
+			err = service.Update(parentRun)
+		}
+
+		if err != nil {
+			log.Println(err.Error())
+		}
+
+		message = service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.best",
+			TemplateData: map[string]interface{}{
+				"ID":     bestRun.ID,
+				"Config": bestRun.SweepConfig,
+				"Score":  bestRun.SweepScore.Float64,
+			},
+			PluralCount: 1,
+		})
+	} else {
+		message = service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.score.missing",
+			TemplateData: map[string]interface{}{
+				"ID": parentRunID,
+			},
+			PluralCount: 1,
+		})
+	}
+
+	statusID := uint(4)
+
+	if succeeded < len(childRuns) {
+		statusID = 8
+	}
+
+	if _, err := service.updateRunStatusIn(parentRunID, []uint{2}, statusID, nil, message); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// checkSweepStep checks that the swept step is a scikit model of the pipeline and every swept field is a step config field.
+func (service *runServiceImpl) checkSweepStep(definition string, sweepReq model.SweepReq) error {
+	var stepDescriptions []model.NodeDescription
+
+	if err := json.Unmarshal([]byte(definition), &stepDescriptions); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "pipeline.validate.definition.invalid",
+			TemplateData: map[string]interface{}{
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	isScikitModel := false
+
+	for _, stepDescription := range stepDescriptions {
+		if stepDescription.Data.ID != sweepReq.StepID {
+			continue
+		}
+
+		for _, modelType := range steps.ScikitUnsupervisedModelTypes {
+			if stepDescription.Type == modelType {
+				isScikitModel = true
+			}
+		}
+	}
+
+	if !isScikitModel {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.step.error",
+			TemplateData: map[string]interface{}{
+				"ID": sweepReq.StepID,
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	configFields := make(map[string]bool)
+	configType := reflect.TypeOf(model.StepDataConfig{})
+
+	for i := 0; i < configType.NumField(); i++ {
+		configFields[configType.Field(i).Tag.Get("json")] = true
+	}
+
+	for field := range sweepReq.Space {
+		if !configFields[field] {
+			errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "run.service.sweep.field.error",
+				TemplateData: map[string]interface{}{
+					"Field": field,
+				},
+				PluralCount: 1,
+			})
+
+			return errors.New(errMessage)
+		}
+	}
+
+	return nil
+}
+
+// createSweepTrials returns the step config values of every trial of the sweep.
+func (service *runServiceImpl) createSweepTrials(sweepReq model.SweepReq) ([]map[string]interface{}, error) {
+	var fields []string
+
+	for field := range sweepReq.Space {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	if len(fields) == 0 {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID:   "run.service.sweep.space.empty",
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	invalidSpace := func(field string) error {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.space.error",
+			TemplateData: map[string]interface{}{
+				"Field":    field,
+				"Strategy": sweepReq.Strategy,
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	tooManyTrials := func() error {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.trials.error",
+			TemplateData: map[string]interface{}{
+				"Max": maxSweepTrials,
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	for _, field := range fields {
+		if parameterType := sweepReq.Space[field].Type; parameterType != "" && parameterType != "float" && parameterType != "int" {
+			return nil, invalidSpace(field)
+		}
+	}
+
+	var trials []map[string]interface{}
+
+	switch sweepReq.Strategy {
+	case "grid", "":
+		trials = []map[string]interface{}{{}}
+
+		for _, field := range fields {
+			values := sweepReq.Space[field].Values
+
+			if len(values) == 0 {
+				return nil, invalidSpace(field)
+			}
+
+			var expandedTrials []map[string]interface{}
+
+			for _, trial := range trials {
+				for _, value := range values {
+					expandedTrial := map[string]interface{}{field: sweepValue(sweepReq.Space[field], value)}
+
+					for previousField, previousValue := range trial {
+						expandedTrial[previousField] = previousValue
+					}
+
+					expandedTrials = append(expandedTrials, expandedTrial)
+				}
+			}
+
+			trials = expandedTrials
+
+			if len(trials) > maxSweepTrials {
+				return nil, tooManyTrials()
+			}
+		}
+	case "random":
+		samples := int(sweepReq.Samples)
+
+		if samples == 0 {
+			samples = defaultSweepSamples
+		}
+
+		if samples > maxSweepTrials {
+			return nil, tooManyTrials()
+		}
+
+		seed := sweepReq.Seed
+
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+
+		random := rand.New(rand.NewSource(seed))
+
+		for i := 0; i < samples; i++ {
+			trial := make(map[string]interface{})
+
+			for _, field := range fields {
+				space := sweepReq.Space[field]
+
+				if len(space.Values) > 0 {
+					trial[field] = sweepValue(space, space.Values[random.Intn(len(space.Values))])
+				} else if !space.Min.Valid || !space.Max.Valid || space.Min.Float64 > space.Max.Float64 {
+					return nil, invalidSpace(field)
+				} else if space.Type == "int" {
+					// Every integer of the range is as likely
+					low, high := math.Ceil(space.Min.Float64), math.Floor(space.Max.Float64)
+
+					if low > high {
+						return nil, invalidSpace(field)
+					}
+
+					trial[field] = int64(low) + random.Int63n(int64(high-low)+1)
+				} else {
+					trial[field] = space.Min.Float64 + random.Float64()*(space.Max.Float64-space.Min.Float64)
+				}
+			}
+
+			trials = append(trials, trial)
+		}
+	default:
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "run.service.sweep.strategy.error",
+			TemplateData: map[string]interface{}{
+				"Strategy": sweepReq.Strategy,
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return trials, nil
+}
+
+// sweepValue returns a value of the search space of a field as the type of the field, rounding the values of an int
+// field to the nearest integer.
+func sweepValue(space model.SweepParameter, value interface{}) interface{} {
+	if number, ok := value.(float64); ok && space.Type == "int" {
+		return int64(math.Round(number))
+	}
+
+	return value
+}
+
+// overrideStepConfig sets the given config fields of a step of the definition. Values are inserted the same way
+// as pipeline parameters.
+func overrideStepConfig(definition string, stepID string, values map[string]interface{}) (string, error) {
+	var nodeDescriptions []map[string]interface{}
+
+	if err := json.Unmarshal([]byte(definition), &nodeDescriptions); err != nil {
+		return "", err
+	}
+
+	for _, nodeDescription := range nodeDescriptions {
+		data, _ := nodeDescription["data"].(map[string]interface{})
+
+		if data == nil || fmt.Sprint(data["id"]) != stepID {
+			continue
+		}
+
+		stepConfig, _ := data["stepConfig"].(map[string]interface{})
+
+		if stepConfig == nil {
+			stepConfig = make(map[string]interface{})
+			data["stepConfig"] = stepConfig
+		}
+
+		for field, value := range values {
+			if _, ok := value.(bool); ok {
+				stepConfig[field] = value
+			} else {
+				stepConfig[field] = formatParameterValue(value)
+			}
+		}
+	}
+
+	overriddenDefinition, err := json.Marshal(nodeDescriptions)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(overriddenDefinition), nil
+}
+
+// readSweepScore returns the score the swept step of a child run wrote to the run work dir.
+func readSweepScore(pipelineID uint, runID uint, stepID string) (float64, error) {
+	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
+
+	if !exists {
+		return 0, errors.New("PIPELINES_WORK_DIR is not defined")
+	}
+
+	id, err := strconv.Atoi(stepID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := os.ReadFile(filepath.Join(pipelinesWorkDir, fmt.Sprint(pipelineID), fmt.Sprint(runID), steps.ScikitScorePath(id)))
+
+	if err != nil {
+		return 0, err
+	}
+
+	var score struct {
+		Score float64 `json:"score"`
+	}
+
+	if err := json.Unmarshal(content, &score); err != nil {
+		return 0, err
+	}
+
+	return score.Score, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"di/model"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestCreateSweepTrials(t *testing.T) {
+	service := &runServiceImpl{I18n: newTestLocalizer(t)}
+
+	tests := []struct {
+		name       string
+		sweepReq   model.SweepReq
+		wantTrials []map[string]interface{}
+		wantCount  int
+		wantErr    bool
+	}{
+		{
+			name: "grid of every combination",
+			sweepReq: model.SweepReq{Space: map[string]model.SweepParameter{
+				"alpha":    {Values: []interface{}{0.1, 1.0}},
+				"max_iter": {Type: "int", Values: []interface{}{10.0, 99.6}},
+			}},
+			wantTrials: []map[string]interface{}{
+				{"alpha": 0.1, "max_iter": int64(100)},
+				{"alpha": 0.1, "max_iter": int64(10)},
+				{"alpha": 1.0, "max_iter": int64(100)},
+				{"alpha": 1.0, "max_iter": int64(10)},
+			},
+		},
+		{
+			name: "random samples",
+			sweepReq: model.SweepReq{Strategy: "random", Samples: 20, Seed: 1, Space: map[string]model.SweepParameter{
+				"alpha":    {Min: null.FloatFrom(0.5), Max: null.FloatFrom(1)},
+				"max_iter": {Type: "int", Min: null.FloatFrom(1.5), Max: null.FloatFrom(3.5)},
+			}},
+			wantCount: 20,
+		},
+		{name: "empty space", sweepReq: model.SweepReq{}, wantErr: true},
+		{name: "unknown type", sweepReq: model.SweepReq{Space: map[string]model.SweepParameter{"alpha": {Type: "string", Values: []interface{}{"a"}}}}, wantErr: true},
+		{name: "grid without values", sweepReq: model.SweepReq{Space: map[string]model.SweepParameter{"alpha": {Min: null.FloatFrom(0), Max: null.FloatFrom(1)}}}, wantErr: true},
+		{name: "random without range", sweepReq: model.SweepReq{Strategy: "random", Space: map[string]model.SweepParameter{"alpha": {Min: null.FloatFrom(1)}}}, wantErr: true},
+		{name: "int range without integers", sweepReq: model.SweepReq{Strategy: "random", Space: map[string]model.SweepParameter{"max_iter": {Type: "int", Min: null.FloatFrom(1.2), Max: null.FloatFrom(1.8)}}}, wantErr: true},
+		{name: "too many samples", sweepReq: model.SweepReq{Strategy: "random", Samples: maxSweepTrials + 1, Space: map[string]model.SweepParameter{"alpha": {Values: []interface{}{1.0}}}}, wantErr: true},
+		{name: "unknown strategy", sweepReq: model.SweepReq{Strategy: "bayes", Space: map[string]model.SweepParameter{"alpha": {Values: []interface{}{1.0}}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trials, err := service.createSweepTrials(test.sweepReq)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			if test.wantTrials != nil {
+				// Trials are compared in the order of their printed form
+				sort.Slice(trials, func(i, j int) bool { return fmt.Sprint(trials[i]) < fmt.Sprint(trials[j]) })
+
+				if !reflect.DeepEqual(trials, test.wantTrials) {
+					t.Errorf("got trials %v, want %v", trials, test.wantTrials)
+				}
+			}
+
+			if test.wantCount != 0 && len(trials) != test.wantCount {
+				t.Errorf("got %d trials, want %d", len(trials), test.wantCount)
+			}
+
+			for _, trial := range trials {
+				if alpha, ok := trial["alpha"].(float64); ok && test.sweepReq.Space["alpha"].Min.Valid && (alpha < 0.5 || alpha > 1) {
+					t.Errorf("got alpha %v out of its range", alpha)
+				}
+
+				if maxIter, ok := trial["max_iter"]; ok && test.sweepReq.Space["max_iter"].Min.Valid {
+					if value, ok := maxIter.(int64); !ok || value < 2 || value > 3 {
+						t.Errorf("got max_iter %v, want an int in [2, 3]", maxIter)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSweepTrialsToStart(t *testing.T) {
+	childRuns := func(statusIDs ...uint) []model.Run {
+		runs := make([]model.Run, len(statusIDs))
+
+		for i, statusID := range statusIDs {
+			runs[i].ID = uint(i + 1)
+			runs[i].RunStatusID = statusID
+		}
+
+		return runs
+	}
+
+	tests := []struct {
+		name          string
+		childRuns     []model.Run
+		concurrency   int
+		wantIDs       []uint
+		wantExecuting int
+	}{
+		{name: "first child runs", childRuns: childRuns(1, 1, 1), concurrency: 2, wantIDs: []uint{1, 2}},
+		{name: "a child run finished", childRuns: childRuns(4, 2, 1, 1), concurrency: 2, wantIDs: []uint{3}, wantExecuting: 1},
+		{name: "waiting for feedback holds its place", childRuns: childRuns(5, 2, 1), concurrency: 2, wantExecuting: 2},
+		{name: "failed, cancelled and interrupted child runs are done", childRuns: childRuns(3, 6, 10, 1), concurrency: 1, wantIDs: []uint{4}},
+		{name: "every child run is done", childRuns: childRuns(4, 3), concurrency: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pendingRuns, executing := sweepTrialsToStart(test.childRuns, test.concurrency)
+
+			var ids []uint
+
+			for _, run := range pendingRuns {
+				ids = append(ids, run.ID)
+			}
+
+			if !reflect.DeepEqual(ids, test.wantIDs) || executing != test.wantExecuting {
+				t.Errorf("got runs %v and %d executing, want runs %v and %d executing", ids, executing, test.wantIDs, test.wantExecuting)
+			}
+		})
+	}
+}
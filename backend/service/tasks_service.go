@@ -1,8 +1,13 @@
 package service
 
 import (
+	"di/model"
 	"di/steps"
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/dominikbraun/graph"
 	"github.com/hibiken/asynq"
@@ -15,6 +20,26 @@ const (
 	ScheduledRunPipelineTask = "pipeline:scheduled_run"
 )
 
+// Run tasks are routed to a queue by what triggered them, unless the pipeline sets a queue of its own.
+const (
+	InteractiveQueue = "interactive"
+	ScheduledQueue   = "scheduled"
+	HeavyQueue       = "heavy"
+	// legacyRunsQueue is the queue every run task used before they were split. The worker keeps draining it.
+	legacyRunsQueue = "runs"
+)
+
+// RunQueues lists the queues run tasks may be routed to.
+var RunQueues = []string{InteractiveQueue, ScheduledQueue, HeavyQueue}
+
+const (
+	defaultWorkerConcurrency = 10
+	defaultWorkerQueues      = "interactive:6,scheduled:3,heavy:1"
+	// defaultWorkerQueueConcurrency reserves workers to the interactive queue, so that runs started or resumed by
+	// users never wait behind a batch of scheduled or heavy runs
+	defaultWorkerQueueConcurrency = "interactive:3"
+)
+
 type taskServiceImpl struct {
 	I18n            *i18n.Localizer
 	NodeTypeService StepService
@@ -57,12 +82,55 @@ func (service *taskServiceImpl) SetupAsynqWorker() {
 
 	redisConnection := asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort}
 
-	worker := asynq.NewServer(redisConnection, asynq.Config{
-		Concurrency: 10,
-		Queues: map[string]int{
-			"runs": 1,
-		},
-	})
+	concurrency := defaultWorkerConcurrency
+
+	if workerConcurrency, exists := os.LookupEnv("WORKER_CONCURRENCY"); exists {
+		value, err := strconv.Atoi(workerConcurrency)
+
+		if err != nil || value <= 0 {
+			panic("WORKER_CONCURRENCY must be a positive number!")
+		}
+
+		concurrency = value
+	}
+
+	workerQueues, exists := os.LookupEnv("WORKER_QUEUES")
+
+	if !exists {
+		workerQueues = defaultWorkerQueues
+	}
+
+	queues, err := parseQueueSettings(workerQueues)
+
+	if err != nil {
+		panic("WORKER_QUEUES is not valid! " + err.Error())
+	}
+
+	strictPriority, _ := strconv.ParseBool(os.Getenv("WORKER_STRICT_PRIORITY"))
+
+	workerQueueConcurrency, exists := os.LookupEnv("WORKER_QUEUE_CONCURRENCY")
+
+	if !exists {
+		workerQueueConcurrency = defaultWorkerQueueConcurrency
+	}
+
+	queueConcurrencies, err := parseQueueSettings(workerQueueConcurrency)
+
+	if err != nil {
+		panic("WORKER_QUEUE_CONCURRENCY is not valid! " + err.Error())
+	}
+
+	workerConfigs, err := createWorkerConfigs(concurrency, queues, queueConcurrencies, strictPriority)
+
+	if err != nil {
+		panic("The worker queues are not valid! " + err.Error())
+	}
+
+	var workers []*asynq.Server
+
+	for _, workerConfig := range workerConfigs {
+		workers = append(workers, asynq.NewServer(redisConnection, workerConfig))
+	}
 
 	mux := asynq.NewServeMux()
 
@@ -76,11 +144,121 @@ func (service *taskServiceImpl) SetupAsynqWorker() {
 		service.RunService.HandleScheduledRunPipelineTask,
 	)
 
-	if err := worker.Run(mux); err != nil {
+	for _, worker := range workers[1:] {
+		go func(worker *asynq.Server) {
+			if err := worker.Run(mux); err != nil {
+				panic("Failed to config Asynq")
+			}
+		}(worker)
+	}
+
+	if err := workers[0].Run(mux); err != nil {
 		panic("Failed to config Asynq")
 	}
 }
 
+// createWorkerConfigs splits the worker concurrency, the total number of tasks processed at the same time, between
+// the queues. Queues with a concurrency of their own are served by a dedicated worker, so they can't take over the
+// shared one, which processes the other queues by their weight with the rest of the concurrency. Every run queue
+// must be served.
+func createWorkerConfigs(concurrency int, queueWeights map[string]int, queueConcurrencies map[string]int, strictPriority bool) ([]asynq.Config, error) {
+	sharedQueues := make(map[string]int)
+
+	for queue, weight := range queueWeights {
+		sharedQueues[queue] = weight
+	}
+
+	if _, exists := sharedQueues[legacyRunsQueue]; !exists {
+		sharedQueues[legacyRunsQueue] = 1
+	}
+
+	var dedicatedQueues []string
+
+	for queue := range queueConcurrencies {
+		dedicatedQueues = append(dedicatedQueues, queue)
+	}
+
+	sort.Strings(dedicatedQueues)
+
+	var workerConfigs []asynq.Config
+	sharedConcurrency := concurrency
+
+	for _, queue := range dedicatedQueues {
+		delete(sharedQueues, queue)
+		sharedConcurrency -= queueConcurrencies[queue]
+
+		workerConfigs = append(workerConfigs, asynq.Config{
+			Concurrency: queueConcurrencies[queue],
+			Queues: map[string]int{
+				queue: 1,
+			},
+		})
+	}
+
+	if sharedConcurrency < 0 || (sharedConcurrency == 0 && len(sharedQueues) > 0) {
+		return nil, fmt.Errorf("the queue concurrencies leave no concurrency out of %d for the queues %v", concurrency, sharedQueues)
+	}
+
+	if len(sharedQueues) > 0 {
+		workerConfigs = append(workerConfigs, asynq.Config{
+			Concurrency:    sharedConcurrency,
+			Queues:         sharedQueues,
+			StrictPriority: strictPriority,
+		})
+	}
+
+	for _, queue := range RunQueues {
+		if _, shared := sharedQueues[queue]; !shared && queueConcurrencies[queue] == 0 {
+			return nil, fmt.Errorf("no worker processes the %s queue", queue)
+		}
+	}
+
+	return workerConfigs, nil
+}
+
+// parseQueueSettings parses a list of queue:value pairs separated by commas, like interactive:6,heavy:1.
+func parseQueueSettings(settings string) (map[string]int, error) {
+	queues := make(map[string]int)
+
+	for _, setting := range strings.Split(settings, ",") {
+		if strings.TrimSpace(setting) == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(strings.TrimSpace(setting), ":")
+		number, err := strconv.Atoi(value)
+
+		if !found || name == "" || err != nil || number <= 0 {
+			return nil, fmt.Errorf("%s is not a queue:number pair", setting)
+		}
+
+		queues[name] = number
+	}
+
+	return queues, nil
+}
+
+// runQueue returns the queue a run task of the pipeline is enqueued in: the pipeline queue when it sets one,
+// and the queue of the trigger otherwise.
+func runQueue(pipeline model.Pipeline, triggerQueue string) string {
+	if pipeline.Queue != "" {
+		return pipeline.Queue
+	}
+
+	return triggerQueue
+}
+
+// IsRunQueue tells whether run tasks can be routed to the queue.
+func IsRunQueue(queue string) bool {
+	for _, runQueue := range RunQueues {
+		if queue == runQueue {
+			return true
+		}
+	}
+
+	return false
+}
+
 func stepHash(step steps.Step) int {
 	return graph.IntHash(int(step.GetID()))
 }
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestCreateWorkerConfigs(t *testing.T) {
+	tests := []struct {
+		name               string
+		concurrency        int
+		queueWeights       map[string]int
+		queueConcurrencies map[string]int
+		want               []asynq.Config
+		wantErr            bool
+	}{
+		{
+			name:         "shared worker only",
+			concurrency:  10,
+			queueWeights: map[string]int{"interactive": 6, "scheduled": 3, "heavy": 1},
+			want: []asynq.Config{
+				{Concurrency: 10, Queues: map[string]int{"interactive": 6, "scheduled": 3, "heavy": 1, "runs": 1}},
+			},
+		},
+		{
+			name:               "dedicated queue takes from the total",
+			concurrency:        10,
+			queueWeights:       map[string]int{"interactive": 6, "scheduled": 3, "heavy": 1},
+			queueConcurrencies: map[string]int{"heavy": 2},
+			want: []asynq.Config{
+				{Concurrency: 2, Queues: map[string]int{"heavy": 1}},
+				{Concurrency: 8, Queues: map[string]int{"interactive": 6, "scheduled": 3, "runs": 1}},
+			},
+		},
+		{
+			name:               "dedicated queue not weighted",
+			concurrency:        4,
+			queueWeights:       map[string]int{"interactive": 1, "scheduled": 1},
+			queueConcurrencies: map[string]int{"heavy": 1},
+			want: []asynq.Config{
+				{Concurrency: 1, Queues: map[string]int{"heavy": 1}},
+				{Concurrency: 3, Queues: map[string]int{"interactive": 1, "scheduled": 1, "runs": 1}},
+			},
+		},
+		{
+			name:         "run queue without worker",
+			concurrency:  10,
+			queueWeights: map[string]int{"interactive": 6, "scheduled": 3},
+			wantErr:      true,
+		},
+		{
+			name:               "dedicated queues exceed the total",
+			concurrency:        4,
+			queueWeights:       map[string]int{"interactive": 1, "scheduled": 1},
+			queueConcurrencies: map[string]int{"heavy": 5},
+			wantErr:            true,
+		},
+		{
+			name:               "no concurrency left for the shared queues",
+			concurrency:        4,
+			queueWeights:       map[string]int{"interactive": 1, "scheduled": 1},
+			queueConcurrencies: map[string]int{"heavy": 4},
+			wantErr:            true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := createWorkerConfigs(test.concurrency, test.queueWeights, test.queueConcurrencies, false)
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultWorkerConfigsReserveInteractive(t *testing.T) {
+	queueWeights, err := parseQueueSettings(defaultWorkerQueues)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queueConcurrencies, err := parseQueueSettings(defaultWorkerQueueConcurrency)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workerConfigs, err := createWorkerConfigs(defaultWorkerConcurrency, queueWeights, queueConcurrencies, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved := 0
+
+	for _, workerConfig := range workerConfigs {
+		if _, ok := workerConfig.Queues[InteractiveQueue]; !ok {
+			continue
+		}
+
+		// The worker serving the interactive queue serves no other queue, which could take its capacity
+		if len(workerConfig.Queues) != 1 {
+			t.Errorf("the interactive queue shares a worker with %v", workerConfig.Queues)
+		}
+
+		reserved += workerConfig.Concurrency
+	}
+
+	if reserved == 0 {
+		t.Error("no worker is reserved to the interactive queue")
+	}
+}
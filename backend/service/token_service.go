@@ -43,6 +43,20 @@ type idTokenCustomClaims struct {
 	jwt.StandardClaims
 }
 
+// runEventsTokenAudience tells the run events tokens apart from the ID tokens they are signed like.
+const runEventsTokenAudience = "run-events"
+
+// runEventsTokenExpirationSecs is the lifetime of a run events token. It only has to outlive opening the stream.
+const runEventsTokenExpirationSecs = 60
+
+// runEventsTokenCustomClaims are the claims of the short lived token an EventSource, which can't send an
+// Authorization header, passes in the query to subscribe to the events of a run.
+type runEventsTokenCustomClaims struct {
+	User  *model.User `json:"user"`
+	RunID uint        `json:"runId"`
+	jwt.StandardClaims
+}
+
 func GetTokenServiceConfig(redisClient *redis.Client) (*TokenServiceConfig, error) {
 
 	priv, err := ioutil.ReadFile("./jwt/rsa_private.pem")
@@ -213,6 +227,57 @@ func (service *tokenService) ValidateIDToken(tokenString string) (*model.User, e
 	return claims.User, nil
 }
 
+func (service *tokenService) NewRunEventsToken(u *model.User, runID uint) (string, error) {
+	timestamp := time.Now().Unix()
+
+	claims := runEventsTokenCustomClaims{
+		User:  u,
+		RunID: runID,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  runEventsTokenAudience,
+			IssuedAt:  timestamp,
+			ExpiresAt: timestamp + runEventsTokenExpirationSecs,
+		},
+	}
+
+	signedTokenString, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(service.PrivKey)
+
+	if err != nil {
+		errorMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "token.service.run-events-token.generate.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"UID":    u.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errorMessage)
+		return "", errors.New(errorMessage)
+	}
+
+	return signedTokenString, nil
+}
+
+func (service *tokenService) ValidateRunEventsToken(tokenString string, runID uint) (*model.User, error) {
+	claims, err := validateRunEventsToken(tokenString, runID, service.PubKey)
+
+	if err != nil {
+		errorMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "token.service.run-events-token.validate.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     runID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+		log.Printf(errorMessage)
+		return nil, errors.New(errorMessage)
+	}
+
+	return claims.User, nil
+}
+
 func (service *tokenService) ValidateRefreshToken(tokenString string) (*model.RefreshToken, error) {
 	claims, err := validateRefreshToken(tokenString, service.PubKey)
 
@@ -337,6 +402,31 @@ func validateIDToken(tokenString string, key *rsa.PublicKey) (*idTokenCustomClai
 		return nil, fmt.Errorf("ID token valid but couldn't parse claims")
 	}
 
+	if claims.Audience == runEventsTokenAudience {
+		return nil, fmt.Errorf("a run events token is not an ID token")
+	}
+
+	return claims, nil
+}
+
+func validateRunEventsToken(tokenString string, runID uint, key *rsa.PublicKey) (*runEventsTokenCustomClaims, error) {
+	claims := &runEventsTokenCustomClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || !claims.VerifyAudience(runEventsTokenAudience, true) {
+		return nil, fmt.Errorf("run events token is invalid")
+	}
+
+	if claims.RunID != runID || claims.User == nil {
+		return nil, fmt.Errorf("run events token was not issued for run %d", runID)
+	}
+
 	return claims, nil
 }
 
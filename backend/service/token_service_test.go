@@ -0,0 +1,54 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"di/model"
+	"testing"
+)
+
+func TestRunEventsToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &tokenService{
+		I18n:             newTestLocalizer(t),
+		PrivKey:          key,
+		PubKey:           &key.PublicKey,
+		IDExpirationSecs: 60,
+	}
+	user := &model.User{Username: "alice"}
+	user.ID = 7
+
+	token, err := service.NewRunEventsToken(user, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := service.ValidateRunEventsToken(token, 3)
+	if err != nil {
+		t.Fatalf("ValidateRunEventsToken() error = %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("ValidateRunEventsToken() user = %d, want %d", got.ID, user.ID)
+	}
+
+	if _, err := service.ValidateRunEventsToken(token, 4); err == nil {
+		t.Error("ValidateRunEventsToken() accepted the token of another run")
+	}
+
+	if _, err := service.ValidateIDToken(token); err == nil {
+		t.Error("ValidateIDToken() accepted a run events token")
+	}
+
+	idToken, err := generateIDToken(user, key, service.IDExpirationSecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ValidateRunEventsToken(idToken, 3); err == nil {
+		t.Error("ValidateRunEventsToken() accepted an ID token")
+	}
+}
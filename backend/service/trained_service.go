@@ -4,9 +4,13 @@ import (
 	"di/model"
 	"di/repository"
 	"di/util"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/hibiken/asynq"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -153,3 +157,324 @@ func (service *trainedServiceImpl) Delete(id uint) error {
 
 	return nil
 }
+
+// RegisterVersion stores version as the next version of the model with the given name of the user, registering the
+// name on its first version. A file with the same checksum already registered from the same run is not registered
+// again, in which case the existing version is returned and created is false.
+func (service *trainedServiceImpl) RegisterVersion(userId uint, name string, version *model.Trained) (*model.Trained, bool, error) {
+	registeredModel, err := service.TrainedRepository.FindRegisteredModelByName(userId, name)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		registeredModel = &model.RegisteredModel{UserID: userId, Name: name}
+		err = service.TrainedRepository.CreateRegisteredModel(registeredModel)
+
+		// Another run may have registered the name meanwhile
+		if err != nil {
+			registeredModel, err = service.TrainedRepository.FindRegisteredModelByName(userId, name)
+		}
+	}
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.create.registered-model.failed",
+			TemplateData: map[string]interface{}{
+				"Name":   name,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, false, errors.New(errMessage)
+	}
+
+	runVersions, err := service.TrainedRepository.FindVersionsByRun(registeredModel.ID, version.RunID)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.version.failed",
+			TemplateData: map[string]interface{}{
+				"Name":   name,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, false, errors.New(errMessage)
+	}
+
+	for _, runVersion := range runVersions {
+		if runVersion.Checksum == version.Checksum {
+			return &runVersion, false, nil
+		}
+	}
+
+	// Trained names are unique, so a run producing the model more than once numbers the later files
+	version.Name = fmt.Sprintf("%s_run%d", name, version.RunID)
+
+	if len(runVersions) > 0 {
+		version.Name = fmt.Sprintf("%s_%d", version.Name, len(runVersions)+1)
+	}
+
+	version.UserID = userId
+	version.RegisteredModelID = registeredModel.ID
+
+	if err := service.TrainedRepository.CreateVersion(version); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.create.version.failed",
+			TemplateData: map[string]interface{}{
+				"Name":   name,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, false, errors.New(errMessage)
+	}
+
+	return version, true, nil
+}
+
+// GetRegisteredModels returns the registered models of the user, each with its latest version only.
+func (service *trainedServiceImpl) GetRegisteredModels(ownerId uint) ([]model.RegisteredModel, error) {
+	registeredModels, err := service.TrainedRepository.FindRegisteredModelsByOwner(ownerId)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.registered-model.owner.failed",
+			TemplateData: map[string]interface{}{
+				"OwnerID": ownerId,
+				"Reason":  err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	for i := range registeredModels {
+		versions, err := service.GetVersions(registeredModels[i].ID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(versions) > 0 {
+			registeredModels[i].Versions = versions[:1]
+		}
+	}
+
+	return registeredModels, nil
+}
+
+func (service *trainedServiceImpl) GetRegisteredModel(id uint) (*model.RegisteredModel, error) {
+	registeredModel, err := service.TrainedRepository.FindRegisteredModelByID(id)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.registered-model.id.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     id,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return registeredModel, nil
+}
+
+// GetVersions returns the versions of a registered model, newest first.
+func (service *trainedServiceImpl) GetVersions(registeredModelId uint) ([]model.Trained, error) {
+	versions, err := service.TrainedRepository.FindVersions(registeredModelId)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.versions.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     registeredModelId,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return versions, nil
+}
+
+// GetLatestInStage returns the newest version in the stage of the model with the given name of the user.
+func (service *trainedServiceImpl) GetLatestInStage(ownerId uint, name string, stage string) (*model.Trained, error) {
+	registeredModel, err := service.TrainedRepository.FindRegisteredModelByName(ownerId, name)
+
+	var versions []model.Trained
+
+	if err == nil {
+		versions, err = service.TrainedRepository.FindVersionsByStage(registeredModel.ID, stage)
+	}
+
+	if err == nil && len(versions) == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.version.stage.failed",
+			TemplateData: map[string]interface{}{
+				"Name":   name,
+				"Stage":  stage,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return &versions[0], nil
+}
+
+// UnmetThresholds checks the metrics of a version against the thresholds of its registered model, returning the
+// thresholds the version does not meet. A metric the version lacks does not meet its threshold.
+func (service *trainedServiceImpl) UnmetThresholds(trained *model.Trained) ([]string, error) {
+	var unmet []string
+
+	registeredModel, err := service.GetRegisteredModel(trained.RegisteredModelID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if registeredModel.Thresholds == "" {
+		return unmet, nil
+	}
+
+	var thresholds []model.MetricThreshold
+
+	if err := json.Unmarshal([]byte(registeredModel.Thresholds), &thresholds); err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]float64)
+
+	if trained.Metrics != "" {
+		if err := json.Unmarshal([]byte(trained.Metrics), &metrics); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, threshold := range thresholds {
+		var names []string
+
+		for name := range metrics {
+			if name == threshold.Metric || (!strings.Contains(threshold.Metric, "/") && strings.HasSuffix(name, "/"+threshold.Metric)) {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			unmet = append(unmet, fmt.Sprintf("%s %s %v (missing)", threshold.Metric, threshold.Operator, threshold.Value))
+			continue
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !meetsThreshold(metrics[name], threshold) {
+				unmet = append(unmet, fmt.Sprintf("%s %s %v (%v)", name, threshold.Operator, threshold.Value, metrics[name]))
+			}
+		}
+	}
+
+	return unmet, nil
+}
+
+func meetsThreshold(value float64, threshold model.MetricThreshold) bool {
+	switch threshold.Operator {
+	case ">":
+		return value > threshold.Value
+	case ">=":
+		return value >= threshold.Value
+	case "<":
+		return value < threshold.Value
+	case "<=":
+		return value <= threshold.Value
+	case "==":
+		return value == threshold.Value
+	}
+
+	return false
+}
+
+// SetThresholds replaces the thresholds the versions of a registered model must meet to be promoted to production.
+func (service *trainedServiceImpl) SetThresholds(registeredModel *model.RegisteredModel, thresholds []model.MetricThreshold) error {
+	registeredModel.Thresholds = ""
+
+	if len(thresholds) > 0 {
+		thresholdsJSON, _ := json.Marshal(thresholds)
+		registeredModel.Thresholds = string(thresholdsJSON)
+	}
+
+	if err := service.TrainedRepository.UpdateRegisteredModel(registeredModel); err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.update.registered-model.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     registeredModel.ID,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return errors.New(errMessage)
+	}
+
+	return nil
+}
+
+// TransitionStage moves a version to the stage on behalf of the user, archiving the production version it replaces,
+// and returns the transitions recorded.
+func (service *trainedServiceImpl) TransitionStage(trained *model.Trained, stage string, userId uint, comment string) ([]model.ModelStageTransition, error) {
+	transitions, err := service.TrainedRepository.TransitionStage(trained, &model.ModelStageTransition{
+		UserID:  userId,
+		ToStage: stage,
+		Comment: comment,
+	})
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.update.stage.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     trained.ID,
+				"Stage":  stage,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return transitions, nil
+}
+
+// GetStageTransitions returns the stage transitions of the versions of a registered model, newest first.
+func (service *trainedServiceImpl) GetStageTransitions(registeredModelId uint) ([]model.ModelStageTransition, error) {
+	transitions, err := service.TrainedRepository.FindStageTransitions(registeredModelId)
+
+	if err != nil {
+		errMessage := service.I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "trained.repository.find.stage-transitions.failed",
+			TemplateData: map[string]interface{}{
+				"ID":     registeredModelId,
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	return transitions, nil
+}
@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
@@ -9,9 +10,15 @@ import (
 	"strconv"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
+// checkoutDir is the dir of the work dir the repository is checked out into.
+const checkoutDir = "repository/"
+
 type CheckoutRepo struct {
 	ID          int
 	PipelineID  uint
@@ -66,9 +73,47 @@ func (step *CheckoutRepo) GetIsStaggered() bool {
 	return false
 }
 
-func (step CheckoutRepo) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *CheckoutRepo) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *CheckoutRepo) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "repository", Path: checkoutDir},
+	}
+}
+
+// ResolveCommit returns the hash of the commit the HEAD of the remote repository points to, i.e. the commit Execute
+// checks out, without cloning the repository.
+func (step *CheckoutRepo) ResolveCommit(ctx context.Context) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{step.RepoURL}})
+
+	references, err := remote.ListContext(ctx, &git.ListOptions{})
+
+	if err != nil {
+		return "", err
+	}
+
+	head := plumbing.HEAD
+
+	for _, reference := range references {
+		if reference.Name() == plumbing.HEAD && reference.Type() == plumbing.SymbolicReference {
+			head = reference.Target()
+		}
+	}
+
+	for _, reference := range references {
+		if reference.Name() == head && reference.Type() == plumbing.HashReference {
+			return reference.Hash().String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("could not resolve the HEAD of %s", step.RepoURL)
+}
+
+func (step CheckoutRepo) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -87,9 +132,9 @@ func (step CheckoutRepo) Execute(logFile *os.File, feedbackRects [][]model.Human
 
 	currentPipelineWorkDir := pipelinesWorkDir + "/" + fmt.Sprint(step.PipelineID) + "/" + fmt.Sprint(step.RunID) + "/"
 
-	if _, err := git.PlainClone(currentPipelineWorkDir, false, &git.CloneOptions{
+	if _, err := git.PlainCloneContext(ctx, currentPipelineWorkDir+checkoutDir, false, &git.CloneOptions{
 		URL:      step.RepoURL,
-		Progress: logFile,
+		Progress: stepLog,
 	}); err != nil {
 		// if err == git.ErrRepositoryAlreadyExists {
 		// 	return err
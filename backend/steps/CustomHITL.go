@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"di/util"
 	"encoding/csv"
@@ -9,7 +10,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -80,9 +80,21 @@ func (step *CustomHITL) GetIsStaggered() bool {
 	return true
 }
 
-func (step CustomHITL) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *CustomHITL) GetInputs() []StepFile {
+	return []StepFile{
+		{Name: "dataset", Path: "datasets/handler.py", Optional: true},
+	}
+}
+
+func (step *CustomHITL) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "epochs", Path: "epochs/"},
+	}
+}
+
+func (step CustomHITL) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -184,13 +196,13 @@ func (step CustomHITL) Execute(logFile *os.File, feedbackRects [][]model.HumanFe
 		args = append(args, fmt.Sprintf("%d", epochNumber.Int64))
 	}
 
-	cmd := exec.Command("python3", args...)
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
 	// var stdout, stderr bytes.Buffer
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	if cmdErr != nil {
 		return nil, cmdErr
@@ -286,7 +298,7 @@ func (step CustomHITL) getCreatedFeedbackQueries(oldResumeEpoch null.Int, curren
 	return feedback, nil
 }
 
-func (step CustomHITL) createTrainFile(logFile *os.File, I18n *i18n.Localizer) error {
+func (step CustomHITL) createTrainFile(stepLog *StepLog, I18n *i18n.Localizer) error {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
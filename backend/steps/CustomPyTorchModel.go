@@ -1,14 +1,13 @@
 package steps
 
 import (
-	"bytes"
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 
@@ -73,9 +72,17 @@ func (step *CustomPyTorchModel) GetIsStaggered() bool {
 	return false
 }
 
-func (step CustomPyTorchModel) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *CustomPyTorchModel) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *CustomPyTorchModel) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "custom_model", Path: "custom_models/"},
+	}
+}
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+func (step CustomPyTorchModel) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -167,15 +174,12 @@ func (step CustomPyTorchModel) Execute(logFile *os.File, feedbackRects [][]model
 		return nil, errors.New(errMessage)
 	}
 
-	cmd := exec.Command("python3", filename)
+	cmd := newCommand(ctx, "python3", filename)
 	cmd.Dir = currentPipelineWorkDir
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	err := cmd.Run()
-	runLogger.Println(stderr.String())
-	runLogger.Println(stdout.String())
+	err := runCommand(ctx, cmd, stepLog)
 
 	return nil, err
 }
@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
@@ -71,7 +72,17 @@ func (step *Dataset) GetIsStaggered() bool {
 	return false
 }
 
-func (step Dataset) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *Dataset) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *Dataset) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "dataset", Path: "datasets/handler.py"},
+	}
+}
+
+func (step Dataset) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
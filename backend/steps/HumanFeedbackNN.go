@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"di/util"
 	"encoding/csv"
@@ -8,7 +9,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -99,9 +99,22 @@ func (step *HumanFeedbackNN) GetIsStaggered() bool {
 	return true
 }
 
-func (step HumanFeedbackNN) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *HumanFeedbackNN) GetInputs() []StepFile {
+	return []StepFile{
+		{Name: "dataset", Path: "datasets/handler.py", Optional: true},
+		{Name: "base_model", Path: "base_models/trained_model.pt", Optional: true},
+	}
+}
+
+func (step *HumanFeedbackNN) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "epochs", Path: "epochs/"},
+	}
+}
+
+func (step HumanFeedbackNN) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -217,13 +230,13 @@ func (step HumanFeedbackNN) Execute(logFile *os.File, feedbackRects [][]model.Hu
 		args = append(args, fmt.Sprintf("%d", epochNumber.Int64))
 	}
 
-	cmd := exec.Command("python3", args...)
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
 	// var stdout, stderr bytes.Buffer
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	if cmdErr != nil {
 		return nil, cmdErr
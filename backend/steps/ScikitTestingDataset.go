@@ -1,13 +1,12 @@
 package steps
 
 import (
-	"bytes"
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -68,9 +67,20 @@ func (step *ScikitTestingDataset) GetIsStaggered() bool {
 	return false
 }
 
-func (step ScikitTestingDataset) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *ScikitTestingDataset) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *ScikitTestingDataset) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "testing_data", Path: "filtered_testing_data.csv"},
+		{Name: "testing_target", Path: "filtered_testing_target.csv"},
+	}
+}
+
+func (step ScikitTestingDataset) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -133,22 +143,22 @@ func (step ScikitTestingDataset) Execute(logFile *os.File, feedbackRects [][]mod
 
 	if step.DataConfig.LowerXRangeIndex.Valid {
 		args = append(args, "-l1")
-		args = append(args, string(step.DataConfig.LowerXRangeIndex.Int64))
+		args = append(args, fmt.Sprint(step.DataConfig.LowerXRangeIndex.Int64))
 	}
 
 	if step.DataConfig.UpperXRangeIndex.Valid {
 		args = append(args, "-u1")
-		args = append(args, string(step.DataConfig.UpperXRangeIndex.Int64))
+		args = append(args, fmt.Sprint(step.DataConfig.UpperXRangeIndex.Int64))
 	}
 
 	if step.DataConfig.LowerYRangeIndex.Valid {
 		args = append(args, "-l2")
-		args = append(args, string(step.DataConfig.LowerYRangeIndex.Int64))
+		args = append(args, fmt.Sprint(step.DataConfig.LowerYRangeIndex.Int64))
 	}
 
 	if step.DataConfig.UpperYRangeIndex.Valid {
 		args = append(args, "-u2")
-		args = append(args, string(step.DataConfig.UpperYRangeIndex.Int64))
+		args = append(args, fmt.Sprint(step.DataConfig.UpperYRangeIndex.Int64))
 	}
 
 	if step.DataConfig.DataFilePath.Valid {
@@ -161,15 +171,12 @@ func (step ScikitTestingDataset) Execute(logFile *os.File, feedbackRects [][]mod
 		args = append(args, currentPipelineWorkDir+string(step.DataConfig.TargetFilePath.String))
 	}
 
-	cmd := exec.Command("python3", args...)
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
-	runLogger.Println(stderr.String())
-	runLogger.Println(stdout.String())
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	return nil, cmdErr
 }
@@ -1,13 +1,12 @@
 package steps
 
 import (
-	"bytes"
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -106,9 +105,31 @@ func (step *ScikitUnsupervisedModel) GetIsStaggered() bool {
 	return false
 }
 
-func (step ScikitUnsupervisedModel) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *ScikitUnsupervisedModel) GetInputs() []StepFile {
+	return []StepFile{
+		{Name: "training_data", Path: "filtered_training_data.csv"},
+		{Name: "training_target", Path: "filtered_training_target.csv"},
+		{Name: "testing_data", Path: "filtered_testing_data.csv"},
+		{Name: "testing_target", Path: "filtered_testing_target.csv", Optional: true},
+	}
+}
+
+func (step *ScikitUnsupervisedModel) GetOutputs() []StepFile {
+	// The model is only scored when the testing target is available
+	return []StepFile{
+		{Name: "score", Path: ScikitScorePath(step.ID), Optional: true},
+	}
+}
+
+// ScikitScorePath returns the path, relative to the run work dir, the scikit model step with the given ID writes its
+// score to.
+func ScikitScorePath(stepID int) string {
+	return "scores/" + strconv.Itoa(stepID) + ".json"
+}
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+func (step ScikitUnsupervisedModel) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	var args []string
 
@@ -135,15 +156,12 @@ func (step ScikitUnsupervisedModel) Execute(logFile *os.File, feedbackRects [][]
 
 	currentPipelineWorkDir := pipelinesWorkDir + "/" + fmt.Sprint(step.PipelineID) + "/" + fmt.Sprint(step.RunID) + "/"
 
-	cmd := exec.Command("python3", args...)
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
-	runLogger.Println(stderr.String())
-	runLogger.Println(stdout.String())
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	return nil, cmdErr
 }
@@ -197,6 +215,29 @@ func (step ScikitUnsupervisedModel) appendArgs(args []string, I18n *i18n.Localiz
 	args = append(args, "--testing_data_path")
 	args = append(args, currentPipelineWorkDir+"filtered_testing_data.csv")
 
+	// The model is scored only when a testing dataset step also produced the testing target
+	if _, err := os.Stat(currentPipelineWorkDir + "filtered_testing_target.csv"); err == nil {
+		args = append(args, "--testing_target_path")
+		args = append(args, currentPipelineWorkDir+"filtered_testing_target.csv")
+
+		if err := os.MkdirAll(currentPipelineWorkDir+"scores/", os.ModePerm); err != nil {
+			errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "os.cmd.mkdir.dir.failed",
+				TemplateData: map[string]interface{}{
+					"Path":   currentPipelineWorkDir + "scores/",
+					"Reason": err.Error(),
+				},
+				PluralCount: 1,
+			})
+
+			runLogger.Println(errMessage)
+			return nil, errors.New(errMessage)
+		}
+
+		args = append(args, "--score_path")
+		args = append(args, currentPipelineWorkDir+ScikitScorePath(step.ID))
+	}
+
 	if step.DataConfig.Fit_intercept.Valid {
 		args = append(args, "--fit_intercept")
 	}
@@ -207,7 +248,7 @@ func (step ScikitUnsupervisedModel) appendArgs(args []string, I18n *i18n.Localiz
 
 	if step.DataConfig.N_jobs.Valid {
 		args = append(args, "--n_jobs")
-		args = append(args, string(step.DataConfig.N_jobs.Int64))
+		args = append(args, fmt.Sprint(step.DataConfig.N_jobs.Int64))
 	}
 
 	if step.DataConfig.Positive.Valid && step.DataConfig.Positive.Bool {
@@ -1,14 +1,13 @@
 package steps
 
 import (
-	"bytes"
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
@@ -74,9 +73,15 @@ func (step *ShellScript) GetIsStaggered() bool {
 	return false
 }
 
-func (step ShellScript) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *ShellScript) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *ShellScript) GetOutputs() []StepFile {
+	return nil
+}
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+func (step ShellScript) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -95,7 +100,10 @@ func (step ShellScript) Execute(logFile *os.File, feedbackRects [][]model.HumanF
 
 	filename := step.Filename
 
-	if step.ScriptType == "inline" {
+	if step.ScriptType == "file" {
+		// The script comes from the repository checked out by an upstream step
+		filename = checkoutDir + step.Filename
+	} else if step.ScriptType == "inline" {
 		filename = pipelinesWorkDir + "file_" + fmt.Sprintf("%d", time.Now().Unix())
 		scriptFile, err := os.Create(filename)
 		defer scriptFile.Close()
@@ -150,15 +158,12 @@ func (step ShellScript) Execute(logFile *os.File, feedbackRects [][]model.HumanF
 		}
 	}
 
-	cmd := exec.Command("python3", filename)
+	cmd := newCommand(ctx, "python3", filename)
 	cmd.Dir = currentPipelineWorkDir
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	err := cmd.Run()
-	runLogger.Println(stderr.String())
-	runLogger.Println(stdout.String())
+	err := runCommand(ctx, cmd, stepLog)
 
 	return nil, err
 }
@@ -1,12 +1,12 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -80,7 +80,21 @@ func (step *Tester) GetIsStaggered() bool {
 	return true
 }
 
-func (step Tester) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *Tester) GetInputs() []StepFile {
+	return []StepFile{
+		{Name: "dataset", Path: "datasets/handler.py", Optional: true},
+		{Name: "base_model", Path: "base_models/trained_model.pt", Optional: true},
+		{Name: "trained_models", Path: TrainedModelsDir, Optional: true},
+	}
+}
+
+func (step *Tester) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "test_results", Path: TestResultsPath(step.ID), Optional: true},
+	}
+}
+
+func (step Tester) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -112,12 +126,33 @@ func (step Tester) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedba
 		args = append(args, step.CustomArguments.String)
 	}
 
-	cmd := exec.Command("python3", args...)
+	// Results left by a previous execution must not pass for the results of this one
+	testResultsPath := filepath.Join(currentPipelineWorkDir, TestResultsPath(step.ID))
+
+	if err := os.Remove(testResultsPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(testResultsPath), os.ModePerm); err != nil {
+		errMessage := I18n.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "os.cmd.mkdir.dir.failed",
+			TemplateData: map[string]interface{}{
+				"Path":   filepath.Dir(testResultsPath),
+				"Reason": err.Error(),
+			},
+			PluralCount: 1,
+		})
+
+		return nil, errors.New(errMessage)
+	}
+
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Env = append(os.Environ(), TestResultsFileEnv+"="+testResultsPath)
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	if cmdErr != nil {
 		return nil, cmdErr
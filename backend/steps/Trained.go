@@ -1,7 +1,9 @@
 package steps
 
 import (
+	"context"
 	"di/model"
+	"di/util"
 	"errors"
 	"fmt"
 	"io"
@@ -71,7 +73,17 @@ func (step *Trained) GetIsStaggered() bool {
 	return false
 }
 
-func (step Trained) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *Trained) GetInputs() []StepFile {
+	return nil
+}
+
+func (step *Trained) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "base_model", Path: "base_models/trained_model.pt"},
+	}
+}
+
+func (step Trained) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -103,8 +115,13 @@ func (step Trained) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedb
 		return nil, errors.New(errMessage)
 	}
 
-	relativeFilePath := strings.Split(step.Filepath, "/files")[1]
-	path := filepath.Join(fileUploadDir, relativeFilePath)
+	// The path is relative to the upload dir, older definitions hold the /files URL the upload dir was served at
+	path, err := util.ResolvePath(fileUploadDir, strings.TrimPrefix(step.Filepath, "/files"))
+
+	if err != nil {
+		errMessage := fmt.Sprintf("Error finding the file of the trained model %s (%d): %q is not an uploaded file: %v", step.TrainedName, step.TrainedID, step.Filepath, err)
+		return nil, errors.New(errMessage)
+	}
 
 	sourceFile, err := os.Open(path)
 
@@ -1,6 +1,7 @@
 package steps
 
 import (
+	"context"
 	"di/model"
 	"di/util"
 	"encoding/csv"
@@ -9,7 +10,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -84,9 +84,26 @@ func (step *Trainer) GetIsStaggered() bool {
 	return step.IsStaggered
 }
 
-func (step Trainer) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
+func (step *Trainer) GetInputs() []StepFile {
+	return []StepFile{
+		{Name: "dataset", Path: "datasets/handler.py"},
+		{Name: "base_model", Path: "base_models/trained_model.pt", Optional: true},
+	}
+}
+
+// TrainedModelsDir is the dir of the work dir of a run the trainers save the models they train to, as .pt files.
+const TrainedModelsDir = "trained_models/"
+
+func (step *Trainer) GetOutputs() []StepFile {
+	return []StepFile{
+		{Name: "trained_models", Path: TrainedModelsDir},
+		{Name: "epochs", Path: "epochs/"},
+	}
+}
+
+func (step Trainer) Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error) {
 
-	runLogger := log.New(logFile, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
+	runLogger := log.New(stepLog, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Llongfile)
 
 	pipelinesWorkDir, exists := os.LookupEnv("PIPELINES_WORK_DIR")
 
@@ -195,12 +212,12 @@ func (step Trainer) Execute(logFile *os.File, feedbackRects [][]model.HumanFeedb
 		}
 	}
 
-	cmd := exec.Command("python3", args...)
+	cmd := newCommand(ctx, "python3", args...)
 	cmd.Dir = currentPipelineWorkDir
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Stdout = stepLog.Stdout
+	cmd.Stderr = stepLog.Stderr
 
-	cmdErr := cmd.Run()
+	cmdErr := runCommand(ctx, cmd, stepLog)
 
 	if cmdErr != nil {
 		return nil, cmdErr
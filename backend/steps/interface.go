@@ -1,8 +1,8 @@
 package steps
 
 import (
+	"context"
 	"di/model"
-	"os"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
@@ -10,7 +10,7 @@ import (
 type Step interface {
 	GetID() int
 	GetName() string
-	Execute(logFile *os.File, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error)
+	Execute(ctx context.Context, stepLog *StepLog, feedbackRects [][]model.HumanFeedbackRect, I18n *i18n.Localizer) ([]model.HumanFeedbackQueryPayload, error)
 	SetData(stepDescription model.NodeDescription) error
 	SetPipelineID(pipelineID uint) error
 	SetRunID(runID uint) error
@@ -18,6 +18,17 @@ type Step interface {
 	GetRunID() uint
 	GetIsFirstStep() bool
 	GetIsStaggered() bool
+	GetInputs() []StepFile
+	GetOutputs() []StepFile
+}
+
+// StepFile is a file, or a dir when Path ends with a slash, that a step reads from or writes to the run work dir.
+// Path is relative to the work dir, where an empty Path stands for the whole work dir. Steps refer to each other's
+// files by Name, so an input is satisfied by an output of the same name of some upstream step.
+type StepFile struct {
+	Name     string
+	Path     string
+	Optional bool
 }
 
 type Edge interface {
@@ -0,0 +1,246 @@
+package steps
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Streams a step log line comes from. System lines are the messages the platform itself logs about the step.
+const (
+	StdoutStream = "stdout"
+	StderrStream = "stderr"
+	SystemStream = "system"
+)
+
+// StepLog is where a step writes its output. Every line goes to the step log file tagged with its time and stream,
+// and untagged to the aggregated run log. StepLog itself is the writer of the system stream. It reports the metrics
+// the processes of the step print or write to the metrics file once ReportMetrics is called, and passes the state of
+// every process that exited to OnProcess.
+type StepLog struct {
+	Stdout      io.Writer
+	Stderr      io.Writer
+	OnProcess   func(state *os.ProcessState)
+	system      *streamWriter
+	file        *os.File
+	runLog      io.Writer
+	metricsPath string
+	// The metrics printed by the step wait in queuedMetrics to be reported outside of the lock on the log
+	queuedMetrics []StepMetric
+	metricsQueued chan struct{}
+	metricsStop   chan struct{}
+	metricsDone   chan struct{}
+	mutex         sync.Mutex
+}
+
+// metricsInterval is how often the metrics file of a running step is read, the metrics written to it without a time
+// are timed when they are read.
+const metricsInterval = time.Second
+
+// maxLineSize caps the size of a line of a step log, longer output without a line break is split into several lines.
+const maxLineSize = 64 * 1024
+
+type streamWriter struct {
+	stepLog *StepLog
+	stream  string
+	buffer  []byte
+	// carriageReturn tells the last line ended with \r, so a \n starting the next write ends the same line
+	carriageReturn bool
+}
+
+// NewStepLog opens the step log file at path. A step resuming an execution, such as after feedback, appends to the
+// log of the execution, otherwise the log and metrics of a previous execution are dropped.
+func NewStepLog(path string, runLog io.Writer, resume bool) (*StepLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	metricsPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".metrics.jsonl"
+
+	if !resume {
+		flag |= os.O_TRUNC
+
+		if err := os.Remove(metricsPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, flag, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stepLog := &StepLog{file: file, runLog: runLog, metricsPath: metricsPath}
+	stepLog.Stdout = &streamWriter{stepLog: stepLog, stream: StdoutStream}
+	stepLog.Stderr = &streamWriter{stepLog: stepLog, stream: StderrStream}
+	stepLog.system = &streamWriter{stepLog: stepLog, stream: SystemStream}
+
+	return stepLog, nil
+}
+
+func (stepLog *StepLog) Write(p []byte) (int, error) {
+	return stepLog.system.Write(p)
+}
+
+// Close writes the last unterminated line of every stream, reports the last metrics and closes the step log file.
+func (stepLog *StepLog) Close() error {
+	for _, writer := range []io.Writer{stepLog.Stdout, stepLog.Stderr, stepLog.system} {
+		writer.(*streamWriter).flush()
+	}
+
+	if stepLog.metricsStop != nil {
+		close(stepLog.metricsStop)
+		<-stepLog.metricsDone
+	}
+
+	return stepLog.file.Close()
+}
+
+// ReportMetrics passes the metrics the step prints and writes to its metrics file to onMetric, from a goroutine of its
+// own so that storing them doesn't hold up the output of the step, and the errors reading the metrics file to onError.
+// The metrics file is removed once the step log is closed, so its metrics are not reported again when the step resumes.
+func (stepLog *StepLog) ReportMetrics(onMetric func(metric StepMetric), onError func(err error)) {
+	stepLog.mutex.Lock()
+	stepLog.metricsQueued = make(chan struct{}, 1)
+	stepLog.metricsStop = make(chan struct{})
+	stepLog.metricsDone = make(chan struct{})
+	stepLog.mutex.Unlock()
+
+	go stepLog.reportMetrics(onMetric, onError)
+}
+
+func (stepLog *StepLog) reportMetrics(onMetric func(metric StepMetric), onError func(err error)) {
+	defer close(stepLog.metricsDone)
+
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+
+	var offset int64
+
+	for {
+		stopped := false
+
+		select {
+		case <-stepLog.metricsQueued:
+		case <-ticker.C:
+		case <-stepLog.metricsStop:
+			stopped = true
+		}
+
+		stepLog.mutex.Lock()
+		metrics := stepLog.queuedMetrics
+		stepLog.queuedMetrics = nil
+		stepLog.mutex.Unlock()
+
+		// The processes of the step are done once it stops, so a last line without a line break is complete too
+		fileMetrics, nextOffset, err := readMetrics(stepLog.metricsPath, offset, stopped)
+
+		if err != nil {
+			onError(err)
+		} else {
+			offset = nextOffset
+			metrics = append(metrics, fileMetrics...)
+		}
+
+		for _, metric := range metrics {
+			onMetric(metric)
+		}
+
+		if stopped {
+			if err := os.Remove(stepLog.metricsPath); err != nil && !os.IsNotExist(err) {
+				onError(err)
+			}
+
+			return
+		}
+	}
+}
+
+// MetricsPath returns the path of the metrics file the processes of the step can report metrics to.
+func (stepLog *StepLog) MetricsPath() string {
+	return stepLog.metricsPath
+}
+
+// processExited passes the state of a process the step ran to OnProcess.
+func (stepLog *StepLog) processExited(state *os.ProcessState) {
+	if state != nil && stepLog.OnProcess != nil {
+		stepLog.OnProcess(state)
+	}
+}
+
+func (stepLog *StepLog) writeLine(stream string, line []byte) {
+	stepLog.mutex.Lock()
+	defer stepLog.mutex.Unlock()
+
+	// Log write errors are ignored so a full disk does not fail the step that is writing
+	stepLog.file.WriteString(time.Now().Format(time.RFC3339Nano) + " " + stream + " " + string(line) + "\n")
+	io.WriteString(stepLog.runLog, string(line)+"\n")
+
+	if stream == StdoutStream && stepLog.metricsQueued != nil && bytes.HasPrefix(line, []byte(MetricLinePrefix)) {
+		if metric, ok := ParseMetric(string(line[len(MetricLinePrefix):])); ok {
+			stepLog.queuedMetrics = append(stepLog.queuedMetrics, metric)
+
+			select {
+			case stepLog.metricsQueued <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Write logs the complete lines of p. Lines end with \n, \r\n or \r, which progress bars redraw a line with, so every
+// redraw is logged as it is written.
+func (writer *streamWriter) Write(p []byte) (int, error) {
+	writer.buffer = append(writer.buffer, p...)
+
+	for len(writer.buffer) > 0 {
+		if writer.carriageReturn {
+			writer.carriageReturn = false
+
+			if writer.buffer[0] == '\n' {
+				writer.buffer = writer.buffer[1:]
+				continue
+			}
+		}
+
+		index := bytes.IndexAny(writer.buffer, "\r\n")
+
+		if index < 0 || index > maxLineSize {
+			if len(writer.buffer) < maxLineSize {
+				break
+			}
+
+			writer.stepLog.writeLine(writer.stream, writer.buffer[:maxLineSize])
+			writer.buffer = writer.buffer[maxLineSize:]
+			continue
+		}
+
+		line := writer.buffer[:index]
+
+		// A redraw starting with \r leaves nothing to log before it
+		if writer.buffer[index] == '\r' {
+			writer.carriageReturn = true
+		}
+
+		if writer.buffer[index] == '\n' || len(line) > 0 {
+			writer.stepLog.writeLine(writer.stream, line)
+		}
+
+		writer.buffer = writer.buffer[index+1:]
+	}
+
+	return len(p), nil
+}
+
+func (writer *streamWriter) flush() {
+	if len(writer.buffer) > 0 {
+		writer.stepLog.writeLine(writer.stream, writer.buffer)
+		writer.buffer = nil
+	}
+}
@@ -0,0 +1,171 @@
+package steps
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStreamWriter(t *testing.T) {
+	long := strings.Repeat("x", maxLineSize+10)
+
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{name: "lines", writes: []string{"a\nb", "c\n"}, want: []string{"a", "bc"}},
+		{name: "crlf", writes: []string{"a\r\nb\r", "\nc\n"}, want: []string{"a", "b", "c"}},
+		{name: "progress bar", writes: []string{"\r 10%", "\r 50%", "\r100%\n"}, want: []string{" 10%", " 50%", "100%"}},
+		{name: "empty line", writes: []string{"a\n\nb\n"}, want: []string{"a", "", "b"}},
+		{name: "long line", writes: []string{long, "\n"}, want: []string{long[:maxLineSize], long[maxLineSize:]}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runLog bytes.Buffer
+			stepLog, err := NewStepLog(filepath.Join(t.TempDir(), "1.log"), &runLog, false)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer stepLog.Close()
+
+			for _, write := range test.writes {
+				stepLog.Stdout.Write([]byte(write))
+			}
+
+			if got := strings.Split(strings.TrimSuffix(runLog.String(), "\n"), "\n"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got lines %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewStepLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.log")
+
+	for i := 0; i < 2; i++ {
+		stepLog, err := NewStepLog(path, &bytes.Buffer{}, false)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stepLog.Stdout.Write([]byte("line\n"))
+		stepLog.Close()
+	}
+
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Count(string(content), "\n"); lines != 1 {
+		t.Errorf("got %d lines, want only the line of the last execution", lines)
+	}
+
+	stepLog, err := NewStepLog(path, &bytes.Buffer{}, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stepLog.Stdout.Write([]byte("line\n"))
+	stepLog.Close()
+
+	content, err = os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("got %d lines, want the resumed execution appended", lines)
+	}
+}
+
+func TestReportMetrics(t *testing.T) {
+	stepLog, err := NewStepLog(filepath.Join(t.TempDir(), "1.log"), &bytes.Buffer{}, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var names []string
+
+	stepLog.ReportMetrics(func(metric StepMetric) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if metric.Time.IsZero() {
+			t.Errorf("got metric %s without a time", metric.Name)
+		}
+
+		names = append(names, metric.Name)
+	}, func(err error) {
+		t.Error(err)
+	})
+
+	stepLog.Stdout.Write([]byte(MetricLinePrefix + `{"name": "loss", "value": 0.5}` + "\n"))
+
+	// The last line of the file is only complete once the step is done
+	metricsFile := `{"name": "accuracy", "value": 0.9}` + "\n" + `not a metric` + "\n" + `{"name": "f1", "value": 0.8}`
+
+	if err := os.WriteFile(stepLog.MetricsPath(), []byte(metricsFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stepLog.Close()
+
+	sort.Strings(names)
+
+	if want := []string{"accuracy", "f1", "loss"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got metrics %q, want %q", names, want)
+	}
+
+	if _, err := os.Stat(stepLog.MetricsPath()); !os.IsNotExist(err) {
+		t.Errorf("got metrics file kept, want it removed")
+	}
+}
+
+func TestReadMetrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.metrics.jsonl")
+	first := `{"name": "loss", "value": 0.5, "epoch": 1}` + "\n"
+
+	if err := os.WriteFile(path, []byte(first+`{"name": "loss", "val`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, offset, err := readMetrics(path, 0, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != 1 || metrics[0].Iteration.Int64 != 1 || offset != int64(len(first)) {
+		t.Fatalf("got %d metrics up to %d, want the complete line up to %d", len(metrics), offset, len(first))
+	}
+
+	if err := os.WriteFile(path, []byte(first+`{"name": "loss", "value": 0.25, "epoch": 2}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, _, err = readMetrics(path, offset, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(metrics) != 1 || metrics[0].Value != 0.25 {
+		t.Errorf("got metrics %+v, want only the line written since the offset", metrics)
+	}
+}
@@ -0,0 +1,93 @@
+package steps
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// MetricLinePrefix tags the stdout lines a step prints to report a metric, followed by the metric as JSON, e.g.
+// ##metric {"name": "loss", "value": 0.25, "epoch": 3}. The same JSON objects, one per line, can be appended to the
+// file the METRICS_FILE environment variable of the step processes points to.
+const MetricLinePrefix = "##metric "
+
+// MetricsFileEnv is the environment variable holding the path of the metrics file of the running step.
+const MetricsFileEnv = "METRICS_FILE"
+
+// StepMetric is a value of a metric reported by a step. Iteration is the epoch or iteration the value belongs to.
+type StepMetric struct {
+	Name      string    `json:"name"`
+	Value     float64   `json:"value"`
+	Iteration null.Int  `json:"iteration"`
+	Epoch     null.Int  `json:"epoch"`
+	Time      time.Time `json:"time"`
+}
+
+// ParseMetric parses a metric as JSON, telling whether it is a valid one. Epoch is taken as the iteration when no
+// iteration is given, and the metric is timed now when it carries no time.
+func ParseMetric(text string) (StepMetric, bool) {
+	var metric StepMetric
+
+	if err := json.Unmarshal([]byte(text), &metric); err != nil || metric.Name == "" || math.IsNaN(metric.Value) {
+		return metric, false
+	}
+
+	if !metric.Iteration.Valid {
+		metric.Iteration = metric.Epoch
+	}
+
+	if metric.Time.IsZero() {
+		metric.Time = time.Now()
+	}
+
+	return metric, true
+}
+
+// readMetrics parses the metrics of the complete lines of a metrics file from the byte offset offset on, skipping the
+// lines that are not metrics, and returns the offset after the last line read. With final set a last line without a
+// line break is read too. A missing file has no metrics.
+func readMetrics(path string, offset int64, final bool) ([]StepMetric, int64, error) {
+	var metrics []StepMetric
+
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return metrics, offset, nil
+	}
+
+	if err != nil {
+		return metrics, offset, err
+	}
+
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return metrics, offset, err
+	}
+
+	reader := bufio.NewReader(file)
+
+	for {
+		text, err := reader.ReadString('\n')
+
+		if err != nil && err != io.EOF {
+			return metrics, offset, err
+		}
+
+		if text == "" || (!strings.HasSuffix(text, "\n") && !final) {
+			return metrics, offset, nil
+		}
+
+		offset += int64(len(text))
+
+		if metric, ok := ParseMetric(strings.TrimSpace(text)); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+}
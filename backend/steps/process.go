@@ -0,0 +1,45 @@
+package steps
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// commandWaitDelay bounds how long a killed command may keep its output pipes open.
+const commandWaitDelay = 10 * time.Second
+
+// newCommand returns a command that runs in its own process group. When ctx is done, the whole group is killed,
+// so the processes spawned by the step scripts don't outlive the step.
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = commandWaitDelay
+
+	return cmd
+}
+
+// runCommand runs cmd, pointing it to the metrics file of stepLog and passing its exit state to stepLog, and, when it
+// was killed because ctx is done, returns the context error instead of the exit status.
+func runCommand(ctx context.Context, cmd *exec.Cmd, stepLog *StepLog) error {
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+
+	cmd.Env = append(cmd.Env, MetricsFileEnv+"="+stepLog.MetricsPath())
+
+	err := cmd.Run()
+
+	stepLog.processExited(cmd.ProcessState)
+
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
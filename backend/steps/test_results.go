@@ -0,0 +1,97 @@
+package steps
+
+import (
+	"di/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// TestResultsFileEnv is the environment variable holding the path of the test results file of the running step.
+const TestResultsFileEnv = "TEST_RESULTS_FILE"
+
+// TestResultsPath returns the path, relative to the run work dir, the Tester step with the given ID writes its
+// model.TestResults to as JSON. The script is given the path by the TEST_RESULTS_FILE environment variable.
+func TestResultsPath(stepID int) string {
+	return "test_results/" + strconv.Itoa(stepID) + ".json"
+}
+
+// ReadTestResults parses and checks the test results file at path, deriving the normalized matrix, total and
+// accuracy of its confusion matrix. A confusion matrix without labels is labelled by the index of its rows. A missing
+// file has no results, in which case nil is returned.
+func ReadTestResults(path string) (*model.TestResults, error) {
+	content, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var testResults model.TestResults
+
+	if err := json.Unmarshal(content, &testResults); err != nil {
+		return nil, err
+	}
+
+	if testResults.ConfusionMatrix != nil {
+		if err := completeConfusionMatrix(testResults.ConfusionMatrix); err != nil {
+			return nil, err
+		}
+	}
+
+	return &testResults, nil
+}
+
+func completeConfusionMatrix(confusionMatrix *model.ConfusionMatrix) error {
+	size := len(confusionMatrix.Matrix)
+
+	if len(confusionMatrix.Labels) == 0 {
+		for i := 0; i < size; i++ {
+			confusionMatrix.Labels = append(confusionMatrix.Labels, strconv.Itoa(i))
+		}
+	}
+
+	if len(confusionMatrix.Labels) != size {
+		return fmt.Errorf("the confusion matrix has %d rows for %d labels", size, len(confusionMatrix.Labels))
+	}
+
+	confusionMatrix.Normalized = make([][]float64, size)
+	confusionMatrix.Total = 0
+	var correct float64
+
+	for i, row := range confusionMatrix.Matrix {
+		if len(row) != size {
+			return errors.New("the confusion matrix is not square")
+		}
+
+		var rowTotal float64
+
+		for _, count := range row {
+			rowTotal += count
+		}
+
+		confusionMatrix.Normalized[i] = make([]float64, size)
+
+		for j, count := range row {
+			if rowTotal > 0 {
+				confusionMatrix.Normalized[i][j] = count / rowTotal
+			}
+		}
+
+		confusionMatrix.Total += rowTotal
+		correct += row[i]
+	}
+
+	confusionMatrix.Accuracy = 0
+
+	if confusionMatrix.Total > 0 {
+		confusionMatrix.Accuracy = correct / confusionMatrix.Total
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package steps
+
+import (
+	"di/model"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadTestResults(t *testing.T) {
+	dir := t.TempDir()
+
+	testResults, err := ReadTestResults(filepath.Join(dir, TestResultsPath(1)))
+	if err != nil || testResults != nil {
+		t.Fatalf("ReadTestResults() of a missing file = %v, %v, want nil, nil", testResults, err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    *model.TestResults
+		wantErr bool
+	}{
+		{
+			name:    "metrics",
+			content: `{"metrics": {"accuracy": 0.9}}`,
+			want:    &model.TestResults{Metrics: map[string]float64{"accuracy": 0.9}},
+		},
+		{
+			name:    "confusion matrix",
+			content: `{"confusionMatrix": {"labels": ["cat", "dog"], "matrix": [[3, 1], [0, 4]]}}`,
+			want: &model.TestResults{ConfusionMatrix: &model.ConfusionMatrix{
+				Labels:     []string{"cat", "dog"},
+				Matrix:     [][]float64{{3, 1}, {0, 4}},
+				Normalized: [][]float64{{0.75, 0.25}, {0, 1}},
+				Total:      8,
+				Accuracy:   0.875,
+			}},
+		},
+		{name: "invalid json", content: `{"metrics":`, wantErr: true},
+		{name: "confusion matrix not square", content: `{"confusionMatrix": {"matrix": [[1, 2], [3]]}}`, wantErr: true},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, TestResultsPath(i))
+
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadTestResults(path)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("ReadTestResults() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ReadTestResults() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompleteConfusionMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  model.ConfusionMatrix
+		want    model.ConfusionMatrix
+		wantErr bool
+	}{
+		{
+			name:   "labelled by row index",
+			matrix: model.ConfusionMatrix{Matrix: [][]float64{{2, 0}, {1, 1}}},
+			want: model.ConfusionMatrix{
+				Labels:     []string{"0", "1"},
+				Matrix:     [][]float64{{2, 0}, {1, 1}},
+				Normalized: [][]float64{{1, 0}, {0.5, 0.5}},
+				Total:      4,
+				Accuracy:   0.75,
+			},
+		},
+		{
+			name:   "empty row",
+			matrix: model.ConfusionMatrix{Labels: []string{"a", "b"}, Matrix: [][]float64{{0, 0}, {0, 2}}},
+			want: model.ConfusionMatrix{
+				Labels:     []string{"a", "b"},
+				Matrix:     [][]float64{{0, 0}, {0, 2}},
+				Normalized: [][]float64{{0, 0}, {0, 1}},
+				Total:      2,
+				Accuracy:   1,
+			},
+		},
+		{
+			name:   "stale totals are recomputed",
+			matrix: model.ConfusionMatrix{Matrix: [][]float64{{1}}, Total: 10, Accuracy: 0.1},
+			want: model.ConfusionMatrix{
+				Labels:     []string{"0"},
+				Matrix:     [][]float64{{1}},
+				Normalized: [][]float64{{1}},
+				Total:      1,
+				Accuracy:   1,
+			},
+		},
+		{name: "labels and rows differ", matrix: model.ConfusionMatrix{Labels: []string{"a"}, Matrix: [][]float64{{1, 0}, {0, 1}}}, wantErr: true},
+		{name: "not square", matrix: model.ConfusionMatrix{Matrix: [][]float64{{1, 0}, {1}}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := completeConfusionMatrix(&test.matrix)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("completeConfusionMatrix() = %+v, want an error", test.matrix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.matrix, test.want) {
+				t.Errorf("completeConfusionMatrix() = %+v, want %+v", test.matrix, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPathOutsideRoot is returned for paths that, once cleaned and their symlinks followed, leave the root they are
+// relative to.
+var ErrPathOutsideRoot = errors.New("path is outside of the root directory")
+
+// FileEntry is a file or directory of a tree. Path is relative to the root of the tree, and the size of a directory
+// is the size of all the files under it.
+type FileEntry struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	IsDir    bool        `json:"isDir"`
+	Size     int64       `json:"size"`
+	MimeType string      `json:"mimeType,omitempty"`
+	ModTime  time.Time   `json:"modTime"`
+	Children []FileEntry `json:"children,omitempty"`
+}
+
+// ResolvePath returns the absolute path of relative under root, failing with ErrPathOutsideRoot when the path, or
+// the file a symlink along it points to, is not under root.
+func ResolvePath(root string, relative string) (string, error) {
+	root, err := filepath.EvalSymlinks(root)
+
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(filepath.Clean("/"+relative)))
+
+	resolved, err := filepath.EvalSymlinks(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", ErrPathOutsideRoot
+	}
+
+	return resolved, nil
+}
+
+// ListFiles returns the tree of the files under relative in root, going down depth levels of directories, or all of
+// them when depth is negative. Symlinks and other special files are left out.
+func ListFiles(root string, relative string, depth int) (FileEntry, error) {
+	path, err := ResolvePath(root, relative)
+
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	return listFileEntry(path, strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+relative)), "/"), info, depth)
+}
+
+func listFileEntry(path string, relative string, info os.FileInfo, depth int) (FileEntry, error) {
+	entry := FileEntry{
+		Name:    info.Name(),
+		Path:    relative,
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}
+
+	if !info.IsDir() {
+		entry.Size = info.Size()
+		entry.MimeType = FileMimeType(path)
+		return entry, nil
+	}
+
+	dirEntries, err := os.ReadDir(path)
+
+	if err != nil {
+		return entry, err
+	}
+
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() && !dirEntry.Type().IsRegular() {
+			continue
+		}
+
+		childInfo, err := dirEntry.Info()
+
+		if err != nil {
+			continue
+		}
+
+		childPath := filepath.Join(path, dirEntry.Name())
+		childRelative := strings.TrimPrefix(relative+"/"+dirEntry.Name(), "/")
+
+		if depth == 0 {
+			entry.Size += childSize(childPath, childInfo)
+			continue
+		}
+
+		child, err := listFileEntry(childPath, childRelative, childInfo, depth-1)
+
+		if err != nil {
+			return entry, err
+		}
+
+		entry.Size += child.Size
+		entry.Children = append(entry.Children, child)
+	}
+
+	sort.Slice(entry.Children, func(i, j int) bool {
+		if entry.Children[i].IsDir != entry.Children[j].IsDir {
+			return entry.Children[i].IsDir
+		}
+
+		return entry.Children[i].Name < entry.Children[j].Name
+	})
+
+	return entry, nil
+}
+
+func childSize(path string, info os.FileInfo) int64 {
+	if !info.IsDir() {
+		return info.Size()
+	}
+
+	var size int64
+
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
+
+// FileMimeType guesses the MIME type of a file from its extension, or from its first bytes when the extension is
+// unknown.
+func FileMimeType(path string) string {
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	defer file.Close()
+
+	buffer := make([]byte, 512)
+	n, _ := io.ReadFull(file, buffer)
+
+	return http.DetectContentType(buffer[:n])
+}
+
+// walkArchiveFiles calls add with the name in the archive of every directory and regular file under path, named
+// after the base name of path.
+func walkArchiveFiles(path string, add func(name string, filePath string, info os.FileInfo) error) error {
+	base := filepath.Dir(path)
+
+	return filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(base, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		return add(filepath.ToSlash(name), filePath, info)
+	})
+}
+
+// WriteTarGz writes the file or directory at path as a gzipped tar archive.
+func WriteTarGz(writer io.Writer, path string) error {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := walkArchiveFiles(path, func(name string, filePath string, info os.FileInfo) error {
+		header, err := tar.FileInfoHeader(info, "")
+
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		// A file still being written by a running step is archived as it was when its header was written
+		return copyFile(tarWriter, filePath, header.Size)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+// WriteZip writes the file or directory at path as a zip archive.
+func WriteZip(writer io.Writer, path string) error {
+	zipWriter := zip.NewWriter(writer)
+
+	err := walkArchiveFiles(path, func(name string, filePath string, info os.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+
+		if err != nil {
+			return err
+		}
+
+		header.Name = name
+
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		fileWriter, err := zipWriter.CreateHeader(header)
+
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		return copyFile(fileWriter, filePath, -1)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// copyFile copies the file at path to writer, up to limit bytes unless limit is negative.
+func copyFile(writer io.Writer, path string, limit int64) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if limit >= 0 {
+		reader = io.LimitReader(file, limit)
+	}
+
+	_, err = io.Copy(writer, reader)
+
+	return err
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(root, "epochs"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "epochs", "1.png"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(outside, "secret"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(root, "epochs"), filepath.Join(root, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		relative string
+		want     string
+		wantErr  error
+		notExist bool
+	}{
+		{name: "root", relative: "", want: resolvedRoot},
+		{name: "file", relative: "epochs/1.png", want: filepath.Join(resolvedRoot, "epochs", "1.png")},
+		{name: "leading slash", relative: "/epochs", want: filepath.Join(resolvedRoot, "epochs")},
+		{name: "dot dot is cleaned", relative: "../../epochs", want: filepath.Join(resolvedRoot, "epochs")},
+		{name: "symlink inside root", relative: "latest/1.png", want: filepath.Join(resolvedRoot, "epochs", "1.png")},
+		{name: "symlink outside root", relative: "escape", wantErr: ErrPathOutsideRoot},
+		{name: "missing file", relative: "epochs/2.png", notExist: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ResolvePath(root, test.relative)
+
+			if test.notExist {
+				if !os.IsNotExist(err) {
+					t.Fatalf("got error %v, want a not exist error", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
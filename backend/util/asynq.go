@@ -6,7 +6,8 @@ import (
 	"github.com/hibiken/asynq"
 )
 
-func GetAsynqClient() *asynq.Client {
+// getAsynqRedisClientOpt returns the connection to the Redis asynq keeps its tasks in.
+func getAsynqRedisClientOpt() asynq.RedisClientOpt {
 
 	redisHost, exists := os.LookupEnv("REDIS_HOST")
 
@@ -20,29 +21,17 @@ func GetAsynqClient() *asynq.Client {
 		panic("REDIS_PORT is not defined!")
 	}
 
-	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort})
-	scheduledTasks, _ := inspector.ListScheduledTasks("runs")
-	activeTasks, _ := inspector.ListActiveTasks("runs")
+	return asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort}
+}
 
-	print(scheduledTasks)
-	print(activeTasks)
+func GetAsynqClient() *asynq.Client {
+	return asynq.NewClient(getAsynqRedisClientOpt())
+}
 
-	return asynq.NewClient(asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort})
+func GetAsynqInspector() *asynq.Inspector {
+	return asynq.NewInspector(getAsynqRedisClientOpt())
 }
 
 func GetAsynqScheduler() *asynq.Scheduler {
-
-	redisHost, exists := os.LookupEnv("REDIS_HOST")
-
-	if !exists {
-		panic("REDIS_HOST is not defined!")
-	}
-
-	redisPort, exists := os.LookupEnv("REDIS_PORT")
-
-	if !exists {
-		panic("REDIS_PORT is not defined!")
-	}
-
-	return asynq.NewScheduler(asynq.RedisClientOpt{Addr: redisHost + ":" + redisPort}, &asynq.SchedulerOpts{})
+	return asynq.NewScheduler(getAsynqRedisClientOpt(), &asynq.SchedulerOpts{})
 }
@@ -97,6 +97,16 @@ func CreateOrUpdateSchema(db *gorm.DB) error {
 		return err
 	}
 
+	if err := db.AutoMigrate(&model.RegisteredModel{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
+	if err := db.AutoMigrate(&model.ModelStageTransition{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
 	if err := db.AutoMigrate(&model.PipelineSchedule{}); err != nil {
 		log.Fatalln(err)
 		return err
@@ -127,11 +137,41 @@ func CreateOrUpdateSchema(db *gorm.DB) error {
 		return err
 	}
 
+	if err := migrateStepsWaitingFeedback(db); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
 	if err := db.AutoMigrate(&model.RunStepStatus{}); err != nil {
 		log.Fatalln(err)
 		return err
 	}
 
+	if err := db.AutoMigrate(&model.RunStepAttempt{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
+	if err := db.AutoMigrate(&model.StepCacheEntry{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
+	if err := db.AutoMigrate(&model.RunArtifact{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
+	if err := db.AutoMigrate(&model.RunMetric{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
+	if err := db.AutoMigrate(&model.RunTestResult{}); err != nil {
+		log.Fatalln(err)
+		return err
+	}
+
 	if err := db.AutoMigrate(&model.HumanFeedbackQuery{}); err != nil {
 		log.Fatalln(err)
 		return err
@@ -145,6 +185,25 @@ func CreateOrUpdateSchema(db *gorm.DB) error {
 	return nil
 }
 
+// migrateStepsWaitingFeedback moves the step a run waited for feedback for, stored in step_waiting_feedback before a
+// run could wait for several steps, into the steps_waiting_feedback list and drops the old column. 0 meant none.
+func migrateStepsWaitingFeedback(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&model.Run{}, "step_waiting_feedback") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE runs SET steps_waiting_feedback = '[' || step_waiting_feedback || ']' " +
+			"WHERE step_waiting_feedback <> 0 AND (steps_waiting_feedback IS NULL OR steps_waiting_feedback IN ('', 'null'))")
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Migrator().DropColumn(&model.Run{}, "step_waiting_feedback")
+	})
+}
+
 func createDefaultRunStatuses(db *gorm.DB) error {
 	defaultRunStatuses := []*model.RunStatus{
 		{Name: "Not Run", IsFinal: false},
@@ -152,6 +211,11 @@ func createDefaultRunStatuses(db *gorm.DB) error {
 		{Name: "Error", IsFinal: true},
 		{Name: "Success", IsFinal: true},
 		{Name: "Waiting Feedback", IsFinal: false},
+		{Name: "Cancelled", IsFinal: true},
+		{Name: "Skipped", IsFinal: true},
+		{Name: "Partial Success", IsFinal: true},
+		{Name: "Cached", IsFinal: true},
+		{Name: "Interrupted", IsFinal: true},
 	}
 
 	for index, status := range defaultRunStatuses {
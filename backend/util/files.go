@@ -0,0 +1,125 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type FileState struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// SnapshotDir returns the size and modification time of every regular file under dir, indexed by relative path.
+func SnapshotDir(dir string) (map[string]FileState, error) {
+	snapshot := make(map[string]FileState)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		snapshot[relativePath] = FileState{Size: info.Size(), ModTime: info.ModTime()}
+
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// ChangedFiles returns the sorted relative paths of the files created or modified between both snapshots.
+func ChangedFiles(before map[string]FileState, after map[string]FileState) []string {
+	var changed []string
+
+	for path, state := range after {
+		if previous, ok := before[path]; !ok || previous.Size != state.Size || !previous.ModTime.Equal(state.ModTime) {
+			changed = append(changed, path)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// HashFile returns the hex encoded SHA-256 checksum of the file content.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CopyFile copies the source file to destination, creating the parent dirs of destination when needed.
+func CopyFile(source string, destination string) error {
+	sourceFile, err := os.Open(source)
+
+	if err != nil {
+		return err
+	}
+
+	defer sourceFile.Close()
+
+	if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+		return err
+	}
+
+	destinationFile, err := os.Create(destination)
+
+	if err != nil {
+		return err
+	}
+
+	defer destinationFile.Close()
+
+	_, err = io.Copy(destinationFile, sourceFile)
+
+	return err
+}
+
+// HiddenDirsFileSystem serves the files of FileSystem but those under Dirs, which it reports as not found.
+type HiddenDirsFileSystem struct {
+	http.FileSystem
+	Dirs []string
+}
+
+func (fileSystem HiddenDirsFileSystem) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+
+	for _, dir := range fileSystem.Dirs {
+		if name == "/"+dir || strings.HasPrefix(name, "/"+dir+"/") {
+			return nil, os.ErrNotExist
+		}
+	}
+
+	return fileSystem.FileSystem.Open(name)
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHiddenDirsFileSystem(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"trained/1", "datasets"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range []string{"trained/1/model.pt", "datasets/data.csv", "trained.csv"} {
+		if err := os.WriteFile(filepath.Join(root, path), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileSystem := HiddenDirsFileSystem{FileSystem: http.Dir(root), Dirs: []string{"trained"}}
+
+	tests := []struct {
+		name   string
+		hidden bool
+	}{
+		{name: "/datasets/data.csv"},
+		{name: "/trained.csv"},
+		{name: "/trained", hidden: true},
+		{name: "/trained/1/model.pt", hidden: true},
+		{name: "/datasets/../trained/1/model.pt", hidden: true},
+		{name: "trained/1/model.pt", hidden: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := fileSystem.Open(test.name)
+
+			if err == nil {
+				file.Close()
+			}
+
+			if hidden := errors.Is(err, os.ErrNotExist); hidden != test.hidden || (!test.hidden && err != nil) {
+				t.Errorf("got error %v, want hidden %v", err, test.hidden)
+			}
+		})
+	}
+}
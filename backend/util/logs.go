@@ -0,0 +1,419 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// StepLogMarker is the run log message that starts the output of a step. Steps running in parallel interleave their
+// output, so filtering the run log by step is only exact for steps that ran alone; the step log files always are.
+const StepLogMarker = "Executing step %s (%d) ..."
+
+var stepLogMarkerRegexp = regexp.MustCompile(`Executing step (.*) \((\d+)\) \.\.\.$`)
+
+type LogLine struct {
+	Offset   int64  `json:"offset"`
+	StepID   string `json:"stepId"`
+	StepName string `json:"stepName"`
+	Text     string `json:"text"`
+}
+
+type LogPage struct {
+	Lines []LogLine `json:"lines"`
+	// Offset is the byte offset of the first line of the page, the cursor to read the previous page with
+	Offset int64 `json:"offset"`
+	// NextOffset is the byte offset right after the last line read, the cursor to read the next page with
+	NextOffset int64 `json:"nextOffset"`
+	HasMore    bool  `json:"hasMore"`
+	Size       int64 `json:"size"`
+	// stepID and stepName are the step the lines after the page belong to, carried over by ReadLogAfter
+	stepID   string
+	stepName string
+}
+
+// Text returns the lines of the page joined as they are in the log file.
+func (page LogPage) Text() string {
+	var text strings.Builder
+
+	for _, line := range page.Lines {
+		text.WriteString(line.Text)
+		text.WriteString("\n")
+	}
+
+	return text.String()
+}
+
+// logReadChunkSize is the size of the chunks a log file is read backwards in.
+const logReadChunkSize = 64 * 1024
+
+// ReadLog reads up to limit complete lines of a log file. When offset is not negative the lines starting at offset
+// are read, otherwise the last lines ending before the byte offset before, or the end of the file when before is
+// negative. A non empty step keeps only the lines of the step with that ID or name. A trailing line still being
+// written is left for the next read.
+func ReadLog(path string, offset int64, before int64, limit int, step string) (LogPage, error) {
+	page := LogPage{Lines: []LogLine{}}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return page, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return page, err
+	}
+
+	page.Size = info.Size()
+
+	if offset > page.Size {
+		offset = page.Size
+	}
+
+	if before < 0 || before > page.Size {
+		before = page.Size
+	}
+
+	if offset >= 0 {
+		// Only the output since the last step marker is read back to know the step the first line belongs to
+		stepID, stepName, err := findLogStep(file, offset)
+
+		if err != nil {
+			return page, err
+		}
+
+		return page, readLogForward(file, &page, offset, limit, step, stepID, stepName)
+	}
+
+	if step != "" {
+		return page, readLogStepTail(file, &page, before, limit, step)
+	}
+
+	return page, readLogTail(file, &page, before, limit)
+}
+
+// ReadLogAfter reads up to limit complete lines of a log file following the lines of the previous page, such as the
+// lines written since it was read. The step the lines belong to is carried over from the previous page, so the log
+// isn't read again from the last step marker.
+func ReadLogAfter(path string, previous LogPage, limit int, step string) (LogPage, error) {
+	page := LogPage{Lines: []LogLine{}}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return page, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return page, err
+	}
+
+	page.Size = info.Size()
+	offset := previous.NextOffset
+
+	if offset > page.Size {
+		offset = page.Size
+	}
+
+	return page, readLogForward(file, &page, offset, limit, step, previous.stepID, previous.stepName)
+}
+
+// readLogForward reads the lines starting at offset, which belong to the step stepID until the next step marker.
+func readLogForward(file *os.File, page *LogPage, offset int64, limit int, step string, stepID string, stepName string) error {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	page.Offset = offset
+	page.NextOffset = offset
+	page.stepID = stepID
+	page.stepName = stepName
+
+	reader := bufio.NewReader(file)
+	position := offset
+
+	for {
+		text, err := reader.ReadString('\n')
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if !strings.HasSuffix(text, "\n") {
+			return nil
+		}
+
+		line := LogLine{
+			Offset: position,
+			Text:   strings.TrimRight(text, "\r\n"),
+		}
+
+		if len(page.Lines) == limit {
+			page.HasMore = true
+			return nil
+		}
+
+		position += int64(len(text))
+		page.NextOffset = position
+
+		if match := stepLogMarkerRegexp.FindStringSubmatch(line.Text); match != nil {
+			page.stepName = match[1]
+			page.stepID = match[2]
+		}
+
+		line.StepID = page.stepID
+		line.StepName = page.stepName
+
+		if step != "" && step != line.StepID && step != line.StepName {
+			continue
+		}
+
+		page.Lines = append(page.Lines, line)
+	}
+}
+
+// readLogTail reads the last lines ending before the byte offset before.
+func readLogTail(file *os.File, page *LogPage, before int64, limit int) error {
+	// One more line than the limit tells whether there are earlier lines
+	var lines []LogLine
+
+	err := scanLinesBackward(file, before, func(offset int64, text string) bool {
+		if len(lines) == 0 {
+			page.NextOffset = offset + int64(len(text))
+		}
+
+		lines = append(lines, LogLine{
+			Offset: offset,
+			Text:   strings.TrimRight(text, "\r\n"),
+		})
+
+		return len(lines) <= limit
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(lines) > limit {
+		lines = lines[:limit]
+		page.HasMore = true
+	}
+
+	page.Offset = page.NextOffset
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	first := lines[len(lines)-1]
+	stepID, stepName, err := findLogStep(file, first.Offset)
+
+	if err != nil {
+		return err
+	}
+
+	page.Offset = first.Offset
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+
+		if match := stepLogMarkerRegexp.FindStringSubmatch(line.Text); match != nil {
+			stepName = match[1]
+			stepID = match[2]
+		}
+
+		line.StepID = stepID
+		line.StepName = stepName
+		page.Lines = append(page.Lines, line)
+	}
+
+	page.stepID = stepID
+	page.stepName = stepName
+
+	return nil
+}
+
+// readLogStepTail reads the last lines of step ending before the byte offset before. The step of a line is only
+// known once the marker starting it is read, so the lines are kept by step until then.
+func readLogStepTail(file *os.File, page *LogPage, before int64, limit int, step string) error {
+	// The lines of step read so far and the lines of the step being read, both last first
+	var lines, stepLines []LogLine
+	stepFound := false
+
+	err := scanLinesBackward(file, before, func(offset int64, text string) bool {
+		if page.NextOffset == 0 {
+			page.NextOffset = offset + int64(len(text))
+		}
+
+		line := LogLine{
+			Offset: offset,
+			Text:   strings.TrimRight(text, "\r\n"),
+		}
+
+		match := stepLogMarkerRegexp.FindStringSubmatch(line.Text)
+
+		// Only the lines up to one more than the limit are needed
+		if len(lines)+len(stepLines) <= limit {
+			stepLines = append(stepLines, line)
+		}
+
+		if match == nil {
+			return true
+		}
+
+		if !stepFound {
+			page.stepName = match[1]
+			page.stepID = match[2]
+			stepFound = true
+		}
+
+		if step == match[1] || step == match[2] {
+			for _, stepLine := range stepLines {
+				stepLine.StepName = match[1]
+				stepLine.StepID = match[2]
+				lines = append(lines, stepLine)
+			}
+		}
+
+		stepLines = nil
+
+		return len(lines) <= limit
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(lines) > limit {
+		lines = lines[:limit]
+		page.HasMore = true
+	}
+
+	page.Offset = page.NextOffset
+
+	if len(lines) > 0 {
+		page.Offset = lines[len(lines)-1].Offset
+	}
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		page.Lines = append(page.Lines, lines[i])
+	}
+
+	return nil
+}
+
+// findLogStep returns the ID and name of the step of the last step marker ending before the byte offset end.
+func findLogStep(file *os.File, end int64) (string, string, error) {
+	var stepID, stepName string
+
+	err := scanLinesBackward(file, end, func(offset int64, text string) bool {
+		match := stepLogMarkerRegexp.FindStringSubmatch(strings.TrimRight(text, "\r\n"))
+
+		if match == nil {
+			return true
+		}
+
+		stepName = match[1]
+		stepID = match[2]
+
+		return false
+	})
+
+	return stepID, stepName, err
+}
+
+// scanLinesBackward calls fn with the offset and text of the complete lines ending before the byte offset end, last
+// first, until fn returns false. The file is read backwards in chunks, so only the lines scanned are read.
+func scanLinesBackward(file io.ReaderAt, end int64, fn func(offset int64, text string) bool) error {
+	// pending holds the bytes from position to the end of the last line not yet scanned
+	var pending []byte
+	position := end
+	trimmed := false
+
+	for position > 0 {
+		size := int64(logReadChunkSize)
+
+		if size > position {
+			size = position
+		}
+
+		chunk := make([]byte, size)
+
+		if _, err := file.ReadAt(chunk, position-size); err != nil && err != io.EOF {
+			return err
+		}
+
+		position -= size
+		pending = append(chunk, pending...)
+
+		// A trailing line still being written is not scanned
+		if !trimmed {
+			last := bytes.LastIndexByte(pending, '\n')
+
+			if last < 0 {
+				pending = pending[:0]
+				continue
+			}
+
+			pending = pending[:last+1]
+			trimmed = true
+		}
+
+		for len(pending) > 0 {
+			start := bytes.LastIndexByte(pending[:len(pending)-1], '\n')
+
+			if start < 0 {
+				break
+			}
+
+			if !fn(position+int64(start)+1, string(pending[start+1:])) {
+				return nil
+			}
+
+			pending = pending[:start+1]
+		}
+	}
+
+	if len(pending) > 0 {
+		fn(0, string(pending))
+	}
+
+	return nil
+}
+
+// StepLogPath returns the path of the log file of a step, kept in the steps dir next to the run log.
+func StepLogPath(runLogPath string, stepID int) string {
+	return filepath.Join(filepath.Dir(runLogPath), "steps", fmt.Sprintf("%d.log", stepID))
+}
+
+// SyncWriter serializes the writes to a writer shared by goroutines, such as the run log the steps running in
+// parallel write to, so that a write is never interleaved with another one.
+type SyncWriter struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func NewSyncWriter(writer io.Writer) *SyncWriter {
+	return &SyncWriter{writer: writer}
+}
+
+func (syncWriter *SyncWriter) Write(p []byte) (int, error) {
+	syncWriter.mutex.Lock()
+	defer syncWriter.mutex.Unlock()
+
+	return syncWriter.writer.Write(p)
+}
@@ -0,0 +1,253 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLog = "setup\n" +
+	"Executing step Load (1) ...\n" +
+	"a1\n" +
+	"a2\n" +
+	"Executing step Train (2) ...\r\n" +
+	"b1\n" +
+	"b2\n" +
+	"b3\n" +
+	"partial"
+
+func writeTestLog(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "run.log")
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// logLines formats the lines of a page as stepID:text to compare them
+func logLines(page LogPage) []string {
+	lines := []string{}
+
+	for _, line := range page.Lines {
+		lines = append(lines, line.StepID+":"+line.Text)
+	}
+
+	return lines
+}
+
+func TestReadLog(t *testing.T) {
+	path := writeTestLog(t, testLog)
+	offsetOf := func(text string) int64 {
+		return int64(strings.Index(testLog, text))
+	}
+	end := offsetOf("partial")
+
+	tests := []struct {
+		name           string
+		offset         int64
+		before         int64
+		limit          int
+		step           string
+		want           []string
+		wantOffset     int64
+		wantNextOffset int64
+		wantHasMore    bool
+	}{
+		{
+			name:           "tail",
+			offset:         -1,
+			before:         -1,
+			limit:          3,
+			want:           []string{"2:b1", "2:b2", "2:b3"},
+			wantOffset:     offsetOf("b1"),
+			wantNextOffset: end,
+			wantHasMore:    true,
+		},
+		{
+			name:           "tail tags lines with the step started before them",
+			offset:         -1,
+			before:         offsetOf("Executing step Train"),
+			limit:          1,
+			want:           []string{"1:a2"},
+			wantOffset:     offsetOf("a2"),
+			wantNextOffset: offsetOf("Executing step Train"),
+			wantHasMore:    true,
+		},
+		{
+			name:           "tail of whole log",
+			offset:         -1,
+			before:         -1,
+			limit:          100,
+			want:           []string{":setup", "1:Executing step Load (1) ...", "1:a1", "1:a2", "2:Executing step Train (2) ...", "2:b1", "2:b2", "2:b3"},
+			wantOffset:     0,
+			wantNextOffset: end,
+		},
+		{
+			name:           "tail of step",
+			offset:         -1,
+			before:         -1,
+			limit:          2,
+			step:           "1",
+			want:           []string{"1:a1", "1:a2"},
+			wantOffset:     offsetOf("a1"),
+			wantNextOffset: end,
+			wantHasMore:    true,
+		},
+		{
+			name:           "tail of step by name",
+			offset:         -1,
+			before:         -1,
+			limit:          10,
+			step:           "Load",
+			want:           []string{"1:Executing step Load (1) ...", "1:a1", "1:a2"},
+			wantOffset:     offsetOf("Executing step Load"),
+			wantNextOffset: end,
+		},
+		{
+			name:           "from offset tags lines with the step started before it",
+			offset:         offsetOf("a2"),
+			before:         -1,
+			limit:          2,
+			want:           []string{"1:a2", "2:Executing step Train (2) ..."},
+			wantOffset:     offsetOf("a2"),
+			wantNextOffset: offsetOf("b1"),
+			wantHasMore:    true,
+		},
+		{
+			name:           "from offset of step",
+			offset:         offsetOf("a2"),
+			before:         -1,
+			limit:          10,
+			step:           "1",
+			want:           []string{"1:a2"},
+			wantOffset:     offsetOf("a2"),
+			wantNextOffset: end,
+		},
+		{
+			name:           "from end",
+			offset:         end,
+			before:         -1,
+			limit:          10,
+			want:           []string{},
+			wantOffset:     end,
+			wantNextOffset: end,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := ReadLog(path, test.offset, test.before, test.limit, test.step)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := logLines(page); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got lines %q, want %q", got, test.want)
+			}
+
+			if page.Offset != test.wantOffset || page.NextOffset != test.wantNextOffset {
+				t.Errorf("got offsets %d-%d, want %d-%d", page.Offset, page.NextOffset, test.wantOffset, test.wantNextOffset)
+			}
+
+			if page.HasMore != test.wantHasMore {
+				t.Errorf("got hasMore %v, want %v", page.HasMore, test.wantHasMore)
+			}
+
+			if page.Size != int64(len(testLog)) {
+				t.Errorf("got size %d, want %d", page.Size, len(testLog))
+			}
+		})
+	}
+}
+
+func TestReadLogAfter(t *testing.T) {
+	path := writeTestLog(t, testLog)
+
+	page, err := ReadLog(path, -1, -1, 1, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The step goes on in the lines appended after the page, the marker starting it is not read again
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteString(" done\nb4\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	file.Close()
+
+	page, err = ReadLogAfter(path, page, 10, "2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := logLines(page), []string{"2:partial done", "2:b4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+
+	page, err = ReadLogAfter(path, page, 10, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Lines) != 0 || page.NextOffset != page.Size {
+		t.Errorf("got lines %q at %d, want none at %d", logLines(page), page.NextOffset, page.Size)
+	}
+}
+
+func TestReadLogAcrossChunks(t *testing.T) {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf(StepLogMarker+"\n", "Train", 2))
+
+	// Long lines make the lines and the step marker span several chunks
+	padding := strings.Repeat("x", 1000)
+	lines := 3 * logReadChunkSize / len(padding)
+
+	for i := 0; i < lines; i++ {
+		content.WriteString(fmt.Sprintf("%d %s\n", i, padding))
+	}
+
+	path := writeTestLog(t, content.String())
+
+	page, err := ReadLog(path, -1, -1, 2, "2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{fmt.Sprintf("2:%d %s", lines-2, padding), fmt.Sprintf("2:%d %s", lines-1, padding)}
+
+	if got := logLines(page); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d lines, want the last 2 of step 2", len(got))
+	}
+
+	page, err = ReadLog(path, -1, -1, lines+1, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Lines) != lines+1 || page.Offset != 0 || page.HasMore {
+		t.Errorf("got %d lines from %d, want %d from 0", len(page.Lines), page.Offset, lines+1)
+	}
+
+	for i, line := range page.Lines[1:] {
+		if line.Text != fmt.Sprintf("%d %s", i, padding) || line.StepID != "2" {
+			t.Fatalf("got line %d %.10q of step %q", i, line.Text, line.StepID)
+		}
+	}
+}
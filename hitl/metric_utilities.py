@@ -0,0 +1,20 @@
+# Imports
+import json
+import math
+
+
+# Function: Report a metric to the pipeline engine, which plots its values per epoch
+def log_metric(name, value, epoch=None):
+
+    value = float(value)
+
+    # NaN and infinite values are not valid JSON numbers
+    if math.isnan(value) or math.isinf(value):
+        return
+
+    metric = {"name": name, "value": value}
+
+    if epoch is not None:
+        metric["epoch"] = int(epoch)
+
+    print(f"##metric {json.dumps(metric)}", flush=True)
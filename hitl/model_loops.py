@@ -15,6 +15,7 @@ import torchvision.transforms as transforms
 # Project Imports
 from xai_utilities import takeThird, GenerateDeepLiftAtts
 from ui_utilities import GetOracleFeedback
+from metric_utilities import log_metric
 
 
 
@@ -235,6 +236,8 @@ def active_train_model(model, model_name, train_loader, val_loader, history_dir,
         
         # Print Statistics
         print(f"Train Loss: {vanilla_avg_train_loss}\tTrain Accuracy: {train_acc}")
+        log_metric("train_loss", vanilla_avg_train_loss, epoch+1)
+        log_metric("train_accuracy", train_acc, epoch+1)
         
         # Append values to the arrays
         # Train Loss
@@ -335,6 +338,8 @@ def active_train_model(model, model_name, train_loader, val_loader, history_dir,
 
             # Print Statistics
             print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}")
+            log_metric("val_loss", avg_val_loss, epoch+1)
+            log_metric("val_accuracy", val_acc, epoch+1)
             # print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}\tValidation Recall: {val_recall}\tValidation Precision: {val_precision}\tValidation F1-Score: {val_f1}")
 
             # Append values to the arrays
@@ -481,6 +486,8 @@ def train_model(model, model_name, train_loader, val_loader, history_dir, weight
 
         # Print Statistics
         print(f"Train Loss: {avg_train_loss}\tTrain Accuracy: {train_acc}")
+        log_metric("train_loss", avg_train_loss, epoch+1)
+        log_metric("train_accuracy", train_acc, epoch+1)
         # print(f"Train Loss: {avg_train_loss}\tTrain Accuracy: {train_acc}\tTrain Recall: {train_recall}\tTrain Precision: {train_precision}\tTrain F1-Score: {train_f1}")
 
 
@@ -571,6 +578,8 @@ def train_model(model, model_name, train_loader, val_loader, history_dir, weight
 
             # Print Statistics
             print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}")
+            log_metric("val_loss", avg_val_loss, epoch+1)
+            log_metric("val_accuracy", val_acc, epoch+1)
             # print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}\tValidation Recall: {val_recall}\tValidation Precision: {val_precision}\tValidation F1-Score: {val_f1}")
 
             # Append values to the arrays
@@ -674,6 +683,8 @@ def test_model(model, test_loader, LOSS, DEVICE):
 
         # Print Statistics
         print(f"Test Loss: {avg_test_loss}\tTest Accuracy: {test_acc}")
+        log_metric("test_loss", avg_test_loss)
+        log_metric("test_accuracy", test_acc)
         # print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}\tValidation Recall: {val_recall}\tValidation Precision: {val_precision}\tValidation F1-Score: {val_f1}")
 
         # Append values to the arrays
@@ -15,6 +15,7 @@ import torchvision.transforms as transforms
 # Project Imports
 from xai_utilities import takeThird, GenerateDeepLiftAtts
 from ui_utilities import GetOracleFeedback, matchSelectedRects
+from metric_utilities import log_metric
 
 # Global variables and definitions
 ImageFile.LOAD_TRUNCATED_IMAGES = True
@@ -316,6 +317,8 @@ def staggered_active_train_model(
         
         # Print Statistics
         print(f"Train Loss: {vanilla_avg_train_loss}\tTrain Accuracy: {train_acc}")
+        log_metric("train_loss", vanilla_avg_train_loss, epoch+1)
+        log_metric("train_accuracy", train_acc, epoch+1)
         
         # Append values to the arrays
         # Train Loss
@@ -414,6 +417,8 @@ def staggered_active_train_model(
 
             # Print Statistics
             print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}")
+            log_metric("val_loss", avg_val_loss, epoch+1)
+            log_metric("val_accuracy", val_acc, epoch+1)
             # print(f"Validation Loss: {avg_val_loss}\tValidation Accuracy: {val_acc}\tValidation Recall: {val_recall}\tValidation Precision: {val_precision}\tValidation F1-Score: {val_f1}")
 
             # Append values to the arrays